@@ -0,0 +1,112 @@
+// buckets.go
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Period buckets (layout.bucket): entries are grouped by the year, quarter, month or ISO week
+// of their period. A bucket is one stop on the axis, labeled with the bucket; its entries
+// are merged into one entry (layout.bucket_merge) or stacked at that stop.
+
+// bucketModes lists the accepted layout.bucket values.
+var bucketModes = []string{"none", "year", "quarter", "month", "week"}
+
+const bucketStackGap = 10.0 // Space between the comment blocks stacked on one bucket
+
+// periodBucket returns the label of the mode bucket period falls into, such as "Q3 2024"
+// for the quarter of "2024-08-15". It reports false for periods that are not dates.
+func periodBucket(period, mode string) (string, bool) {
+	t, ok := parsePeriodTime(period)
+	if !ok {
+		return "", false
+	}
+	switch mode {
+	case "year":
+		return t.Format("2006"), true
+	case "quarter":
+		return fmt.Sprintf("Q%d %d", (int(t.Month())-1)/3+1, t.Year()), true
+	case "month":
+		return t.Format("Jan 2006"), true
+	case "week":
+		year, week := t.ISOWeek()
+		return fmt.Sprintf("%d-W%02d", year, week), true
+	}
+	return period, true
+}
+
+// bucketEntries groups entries by their mode bucket, in the order the buckets first appear,
+// and gives each entry its bucket label as period. With merge every bucket becomes one entry
+// (the first one's styles, all titles and comment texts); otherwise the entries are kept and
+// ranks holds each one's position within its bucket, 0 for the first. Entries are returned
+// unchanged (and ranks nil) if a period is not a date.
+func bucketEntries(diag *diagnosticLog, entries []TimelineEntry, mode string, merge bool) ([]TimelineEntry, []int) {
+	var labels []string
+	members := map[string][]TimelineEntry{}
+	for i, entry := range entries {
+		label, ok := periodBucket(entry.Period, mode)
+		if !ok {
+			diag.warnf("unparseable_date", "layout.bucket '%s' needs every period to be a date; entry %d (%s) is not, entries are not bucketed.", mode, i, entry.Period)
+			return entries, nil
+		}
+		if _, seen := members[label]; !seen {
+			labels = append(labels, label)
+		}
+		entry.Period, entry.PeriodEnd = label, "" // A range would no longer parse against the label
+		members[label] = append(members[label], entry)
+	}
+
+	bucketed := make([]TimelineEntry, 0, len(entries))
+	ranks := make([]int, 0, len(entries))
+	for _, label := range labels {
+		if merge {
+			bucketed = append(bucketed, mergeBucketEntries(members[label]))
+			ranks = append(ranks, 0)
+			continue
+		}
+		for rank, entry := range members[label] {
+			bucketed = append(bucketed, entry)
+			ranks = append(ranks, rank)
+		}
+	}
+	return bucketed, ranks
+}
+
+// mergeBucketEntries combines the entries of one bucket: the first entry with the titles
+// joined by ", " and the comment texts by line breaks.
+func mergeBucketEntries(entries []TimelineEntry) TimelineEntry {
+	merged := entries[0]
+	var titles, comments []string
+	for _, entry := range entries {
+		if entry.TitleText != "" {
+			titles = append(titles, entry.TitleText)
+		}
+		if entry.CommentText != "" {
+			comments = append(comments, entry.CommentText)
+		}
+	}
+	merged.Subtitle = ""
+	merged.TitleText = strings.Join(titles, ", ")
+	merged.CommentText = strings.Join(comments, "<br>")
+	return merged
+}
+
+// commentStackEdge returns how far from the axis point the comment block of layout reaches
+// on its side, 0 if the entry has no comment block.
+func commentStackEdge(layout EntryLayout, axis AxisPoint) float64 {
+	block := layout.Comment
+	if block == nil {
+		return 0
+	}
+	if layout.IsHorizontal {
+		if layout.CommentSide < 0 {
+			return axis.Y - block.blockY
+		}
+		return block.blockY + block.visualBlockHeight - axis.Y
+	}
+	if layout.CommentSide < 0 {
+		return axis.X - block.blockX
+	}
+	return block.blockX + block.visualBlockWidth - axis.X
+}
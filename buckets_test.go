@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestBucketEntriesByQuarter checks sample dates land in their quarters, in the order the
+// quarters first appear, either merged or stacked with their ranks.
+func TestBucketEntriesByQuarter(t *testing.T) {
+	entries := []TimelineEntry{
+		{Period: "2024-01-15", TitleText: "Kickoff", CommentText: "Plan"},
+		{Period: "2024-03-31", TitleText: "Beta"},
+		{Period: "2024-04-01", CommentText: "Launch"},
+		{Period: "2024-02", CommentText: "Hiring"},
+		{Period: "2023", CommentText: "Idea"},
+	}
+
+	stacked, ranks := bucketEntries(nil, entries, "quarter", false)
+	var periods []string
+	for _, entry := range stacked {
+		periods = append(periods, entry.Period)
+	}
+	if want := []string{"Q1 2024", "Q1 2024", "Q1 2024", "Q2 2024", "Q1 2023"}; !reflect.DeepEqual(periods, want) {
+		t.Errorf("Expected periods %v, got %v", want, periods)
+	}
+	if want := []int{0, 1, 2, 0, 0}; !reflect.DeepEqual(ranks, want) {
+		t.Errorf("Expected ranks %v, got %v", want, ranks)
+	}
+	if stacked[2].CommentText != "Hiring" {
+		t.Errorf("Expected the February entry to join the first quarter, got %q", stacked[2].CommentText)
+	}
+
+	merged, _ := bucketEntries(nil, entries, "quarter", true)
+	if len(merged) != 3 {
+		t.Fatalf("Expected 3 merged buckets, got %d", len(merged))
+	}
+	if merged[0].TitleText != "Kickoff, Beta" || merged[0].CommentText != "Plan<br>Hiring" {
+		t.Errorf("Expected the first quarter to combine its titles and comments, got %q / %q", merged[0].TitleText, merged[0].CommentText)
+	}
+
+	if label, _ := periodBucket("2021-01-03", "week"); label != "2020-W53" {
+		t.Errorf("Expected ISO week 2020-W53, got %s", label)
+	}
+	if unchanged, ranks := bucketEntries(nil, []TimelineEntry{{Period: "Antiquity"}}, "quarter", false); ranks != nil || unchanged[0].Period != "Antiquity" {
+		t.Errorf("Expected free text periods to be left unbucketed")
+	}
+}
+
+// TestBucketStackSharesAxisPoint checks the stacked entries of a bucket draw one marker and
+// label, and their comments one beyond the other on the same side.
+func TestBucketStackSharesAxisPoint(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.Bucket = "quarter"
+	entries := []TimelineEntry{{Period: "2024-01", CommentText: "A"}, {Period: "2024-02", CommentText: "B"}, {Period: "2024-05", CommentText: "C"}}
+
+	svg, layout, err := generateSVG(template, entries, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	if got := strings.Count(svg, ">Q1 2024<"); got != 1 {
+		t.Errorf("Expected the Q1 label once, got %d", got)
+	}
+	first, second := layout.entryLayouts[0], layout.entryLayouts[1]
+	if first.Comment == nil || second.Comment == nil {
+		t.Fatalf("Expected both Q1 entries to have comment blocks")
+	}
+	if second.CommentSide != first.CommentSide {
+		t.Errorf("Expected the stacked comment on the first comment's side")
+	}
+	if second.Comment.blockX != first.Comment.blockX || second.Comment.blockY < first.Comment.blockY+first.Comment.visualBlockHeight {
+		t.Errorf("Expected the stacked comment below the first one, got y %g after %g+%g",
+			second.Comment.blockY, first.Comment.blockY, first.Comment.visualBlockHeight)
+	}
+}
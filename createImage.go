@@ -5,78 +5,241 @@ import (
 	"bytes"
 	"context"
 	"encoding/base64"
+	"errors"
 	"fmt"
 
-	// "image" // No longer needed unless doing JPG conversion
+	"image"
 	"image/jpeg"
 	"image/png"
 	"io"
 	"log"
+	"sort"
 	"strings"
+	"time"
 
 	// "math" // No longer needed
 
+	"github.com/chromedp/cdproto/cdp"
+	"github.com/chromedp/cdproto/emulation"
 	"github.com/chromedp/chromedp"
 )
 
 // Removed const defaultImageWidth/Height - determined from SVG by browser now
 // Removed const defaultResolution - handled by screenshot
 
-// Update function signature - remove outputFilename
-func generateImage(template Template, entries []TimelineEntry, format string, outputWriter io.Writer) error {
+const renderTimeout = 60 * time.Second // Longest Chrome may take to load and screenshot the SVG
+
+// dataURIMimeTypes maps export formats to the MIME type used in data URIs.
+var dataURIMimeTypes = map[string]string{
+	"svg":      "image/svg+xml",
+	"html":     "text/html",
+	"svg-html": "text/html",
+	"png":      "image/png",
+	"jpg":      "image/jpeg",
+	"jpeg":     "image/jpeg",
+}
+
+// encodeDataURI wraps content in a base64 data URI with the MIME type for format.
+func encodeDataURI(content []byte, format string) (string, error) {
+	mimeType, ok := dataURIMimeTypes[format]
+	if !ok {
+		return "", fmt.Errorf("no data URI MIME type for format '%s'", format)
+	}
+	return "data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(content), nil
+}
+
+// imagePreset is a named output size for raster images, selectable with the -preset flag.
+// Sizes are applied through the layout canvas limits; Scale is the device pixel ratio of the screenshot.
+type imagePreset struct {
+	MaxWidth, MaxHeight float64 // Canvas ceiling, oversized timelines are scaled down (0 = unchanged)
+	MinWidth, MinHeight float64 // Canvas floor, smaller timelines are centered (0 = unchanged)
+	Scale               float64 // Screenshot scale factor (1 = one pixel per SVG unit)
+}
+
+var imagePresets = map[string]imagePreset{
+	"thumbnail": {MaxWidth: 400, Scale: 1},                                                  // At most 400px wide
+	"social":    {MaxWidth: 1200, MaxHeight: 630, MinWidth: 1200, MinHeight: 630, Scale: 1}, // Exactly 1200x630, letterboxed
+	"print":     {Scale: 3},                                                                 // 3x pixel density (~300 DPI at 100%)
+}
+
+// availablePresets lists the names of the image size presets.
+func availablePresets() []string {
+	names := make([]string, 0, len(imagePresets))
+	for name := range imagePresets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// applyImagePreset returns the template with the preset's canvas limits applied and the
+// screenshot scale to use. An empty name leaves the template unchanged.
+func applyImagePreset(template Template, presetName string) (Template, float64, error) {
+	if presetName == "" {
+		return template, 1, nil
+	}
+	preset, ok := imagePresets[presetName]
+	if !ok {
+		return template, 1, fmt.Errorf("unknown preset '%s' (available: %s)", presetName, strings.Join(availablePresets(), ", "))
+	}
+	if preset.MaxWidth > 0 {
+		template.Layout.MaxWidth = preset.MaxWidth
+	}
+	if preset.MaxHeight > 0 {
+		template.Layout.MaxHeight = preset.MaxHeight
+	}
+	if preset.MinWidth > 0 {
+		template.Layout.MinWidth = preset.MinWidth
+	}
+	if preset.MinHeight > 0 {
+		template.Layout.MinHeight = preset.MinHeight
+	}
+	return template, preset.Scale, nil
+}
+
+// generateImage builds the timeline SVG (with the size preset applied) and renders it to a
+// PNG/JPG. It returns the layout of the SVG the image was made from.
+func generateImage(template Template, entries []TimelineEntry, format string, presetName string, fontCSS string, outputWriter io.Writer) (timelineLayout, error) {
+	template, screenshotScale, err := applyImagePreset(template, presetName)
+	if err != nil {
+		return timelineLayout{}, err
+	}
+	if err := checkImageTransparency(template, format); err != nil {
+		return timelineLayout{}, err
+	}
+	template.Layout.AnimateConnectors = false // The screenshot would catch the lines mid-draw
+
 	// 1. Generate SVG string first
-	svgString, err := GenerateSVG(template, entries)
+	svgString, layout, err := generateSVG(template, entries, nil)
 	if err != nil {
-		return fmt.Errorf("failed to generate intermediate SVG: %w", err)
+		return timelineLayout{}, err // Already a GenerationError
+	}
+	if template.Layout.PreciseImageLayout {
+		preciseSVG, preciseLayout, errPrecise := preciseImageSVG(template, entries, svgString, fontCSS, format)
+		if errPrecise != nil {
+			return layout, errPrecise
+		}
+		svgString, layout = preciseSVG, preciseLayout
 	}
+	return layout, renderSVGToImage(embedFontFaces(svgString, fontCSS), format, screenshotScale, outputWriter)
+}
 
-	// --- Use chromedp to render SVG ---
+// commentBodyHeightsJS returns the rendered content height of every comment body, keyed by the
+// id of the entry group it belongs to. scrollHeight ignores transforms and the estimated
+// foreignObject height, so it is the height the body really needs.
+const commentBodyHeightsJS = `(() => {
+	const heights = {};
+	document.querySelectorAll('g[id] foreignObject').forEach(fo => {
+		const group = fo.closest('g[id]');
+		const content = fo.firstElementChild;
+		if (group && content) heights[group.id] = content.scrollHeight;
+	});
+	return heights;
+})()`
 
-	// 2. Create a base64 data URI for the SVG
-	// This allows loading the SVG directly without saving a temp file
-	svgBase64 := base64.StdEncoding.EncodeToString([]byte(svgString))
-	dataURI := "data:image/svg+xml;base64," + svgBase64
-	log.Println("Created data URI for SVG.")
+// preciseImageSVG re-generates svgString with the comment body heights measured in Chrome
+// (layout.precise_image_layout), so boxes fit their content exactly, and returns it with its
+// layout. It costs an extra render.
+func preciseImageSVG(template Template, entries []TimelineEntry, svgString, fontCSS, format string) (string, timelineLayout, error) {
+	heights, err := measureCommentBodyHeights(embedFontFaces(svgString, fontCSS))
+	if err != nil {
+		return "", timelineLayout{}, &GenerationError{Format: format, Err: err}
+	}
+	log.Printf("Measured %d comment bodies, regenerating SVG for precise layout.", len(heights))
+	return generateSVG(template, entries, heights)
+}
+
+// measureCommentBodyHeights loads svgString in headless Chrome and returns the rendered height
+// of each comment body by entry id.
+func measureCommentBodyHeights(svgString string) (map[string]float64, error) {
+	dataURI, err := encodeDataURI([]byte(svgString), "svg")
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := newHeadlessContext(context.Background())
+	defer cancel()
 
-	// 3. Setup chromedp
-	// Create allocator options
+	heights := map[string]float64{}
+	if err := chromedp.Run(ctx,
+		chromedp.Navigate(dataURI),
+		chromedp.WaitVisible(`svg`, chromedp.ByQuery),
+		chromedp.Evaluate(commentBodyHeightsJS, &heights),
+	); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w after %s", ErrRenderTimeout, renderTimeout)
+		}
+		return nil, fmt.Errorf("measuring comment bodies failed: %w", err)
+	}
+	return heights, nil
+}
+
+// newHeadlessContext starts a headless Chrome under parent and returns a context limited to
+// renderTimeout, with a cancel func that also shuts the browser down.
+func newHeadlessContext(parent context.Context) (context.Context, context.CancelFunc) {
 	opts := append(chromedp.DefaultExecAllocatorOptions[:],
 		// Add options here if needed, e.g.:
 		// chromedp.DisableGPU,
 		// chromedp.NoSandbox,
 		chromedp.Headless, // Ensure it runs headless
 	)
-	allocCtx, cancelAlloc := chromedp.NewExecAllocator(context.Background(), opts...)
-	defer cancelAlloc()
-
-	// Create a new context
+	allocCtx, cancelAlloc := chromedp.NewExecAllocator(parent, opts...)
 	ctx, cancelCtx := chromedp.NewContext(allocCtx)
-	defer cancelCtx()
-
-	// 4. Define tasks to navigate and screenshot the SVG element
-	var screenshotBuf []byte
+	ctx, cancelTimeout := context.WithTimeout(ctx, renderTimeout)
+	return ctx, func() {
+		cancelTimeout()
+		cancelCtx()
+		cancelAlloc()
+	}
+}
 
-	tasks := chromedp.Tasks{
-		// Navigate to the data URI
-		chromedp.Navigate(dataURI),
-		// Wait for the svg element to be present
-		chromedp.WaitVisible(`svg`, chromedp.ByQuery),
-		// Take a screenshot of the first SVG element found
-		chromedp.Screenshot(`svg`, &screenshotBuf, chromedp.ByQuery),
+// checkImageTransparency rejects a transparent layout rendered to a format without alpha:
+// a JPG would silently come out on white, so only PNG keeps layout.transparent.
+func checkImageTransparency(template Template, format string) error {
+	if template.Layout.Transparent && format != "png" {
+		return &GenerationError{Format: format, Err: fmt.Errorf("%w: layout.transparent needs png output, %s would be rendered on white", ErrNoAlphaChannel, strings.ToUpper(format))}
 	}
+	return nil
+}
 
-	// 5. Run the tasks
-	log.Println("Running chromedp tasks (navigate and screenshot)...")
-	if err := chromedp.Run(ctx, tasks); err != nil {
-		return fmt.Errorf("chromedp execution failed: %w", err)
+// RenderToImage generates the timeline and rasterizes it with headless Chrome, returning the
+// decoded image for callers that composite it further instead of writing encoded bytes.
+// scale is the device pixel ratio (values <= 0 mean 1). The background stays transparent
+// where the SVG has none, as in PNG output. ctx bounds the whole render on top of
+// renderTimeout; failures are returned as a GenerationError.
+func RenderToImage(ctx context.Context, template Template, entries []TimelineEntry, scale float64) (image.Image, error) {
+	template.Layout.AnimateConnectors = false // The screenshot would catch the lines mid-draw
+	svgString, err := GenerateSVG(template, entries)
+	if err != nil {
+		return nil, err // Already a GenerationError
 	}
-	log.Println("Chromedp tasks completed successfully.")
+	screenshot, err := screenshotSVG(ctx, svgString, scale, true)
+	if err != nil {
+		return nil, &GenerationError{Format: "png", Err: err}
+	}
+	img, err := png.Decode(bytes.NewReader(screenshot))
+	if err != nil {
+		return nil, &GenerationError{Format: "png", Err: fmt.Errorf("failed to decode PNG screenshot: %w", err)}
+	}
+	return img, nil
+}
 
-	if len(screenshotBuf) == 0 {
-		return fmt.Errorf("screenshot buffer is empty, screenshot failed")
+// renderSVGToImage rasterizes an already generated SVG with headless Chrome and writes it
+// as PNG or JPG. scale is the screenshot device pixel ratio (1 = one pixel per SVG unit,
+// values <= 0 mean 1). PNGs keep the SVG's own transparency; JPGs are rendered on white.
+// Failures are returned as a GenerationError; Chrome taking longer than renderTimeout gives
+// ErrRenderTimeout.
+func renderSVGToImage(svgString string, format string, screenshotScale float64, outputWriter io.Writer) error {
+	fail := func(err error) error { return &GenerationError{Format: format, Err: err} }
+	// Reject bad input before starting a browser
+	if format != "png" && format != "jpg" && format != "jpeg" {
+		return fail(fmt.Errorf("%w '%s' (supported: png, jpg/jpeg)", ErrUnsupportedFormat, format))
+	}
+	screenshotBuf, err := screenshotSVG(context.Background(), svgString, screenshotScale, format == "png")
+	if err != nil {
+		return fail(err)
 	}
 
-	// 6. Process output
+	// Process output
 	screenshotReader := bytes.NewReader(screenshotBuf)
 
 	switch format {
@@ -84,24 +247,84 @@ func generateImage(template Template, entries []TimelineEntry, format string, ou
 		// Screenshot is already PNG, just copy it
 		_, err = io.Copy(outputWriter, screenshotReader)
 		if err != nil {
-			return fmt.Errorf("failed to write PNG screenshot data: %w", err)
+			return fail(fmt.Errorf("failed to write PNG screenshot data: %w", err))
 		}
 	case "jpg", "jpeg":
 		// Decode the PNG screenshot
 		img, errPng := png.Decode(screenshotReader) // Use different error var name
 		if errPng != nil {
-			return fmt.Errorf("failed to decode PNG screenshot: %w", errPng)
+			return fail(fmt.Errorf("failed to decode PNG screenshot: %w", errPng))
 		}
 		// Re-encode as JPEG
 		opts := &jpeg.Options{Quality: 90} // Default JPEG quality
 		err = jpeg.Encode(outputWriter, img, opts)
 		if err != nil {
-			return fmt.Errorf("failed to encode JPEG: %w", err)
+			return fail(fmt.Errorf("failed to encode JPEG: %w", err))
 		}
 	default:
-		return fmt.Errorf("internal error: unsupported image format '%s' with chromedp", format)
+		return fail(fmt.Errorf("internal error: unsupported image format '%s' with chromedp", format))
 	}
 
 	log.Printf("Successfully encoded %s image using chromedp.", strings.ToUpper(format))
 	return nil
 }
+
+// screenshotSVG loads svgString in headless Chrome and returns a PNG screenshot of the SVG
+// element. With transparentBackground the browser's white page is cleared, so the PNG keeps
+// the SVG's alpha channel. ctx can cancel the render early; renderTimeout always applies.
+func screenshotSVG(ctx context.Context, svgString string, screenshotScale float64, transparentBackground bool) ([]byte, error) {
+	if !strings.Contains(svgString, "<svg") {
+		return nil, fmt.Errorf("input is not an SVG document")
+	}
+	if screenshotScale <= 0 {
+		screenshotScale = 1
+	}
+
+	// --- Use chromedp to render SVG ---
+
+	// 1. Create a base64 data URI for the SVG
+	// This allows loading the SVG directly without saving a temp file
+	dataURI, err := encodeDataURI([]byte(svgString), "svg")
+	if err != nil {
+		return nil, err
+	}
+	log.Println("Created data URI for SVG.")
+
+	// 2. Setup chromedp
+	ctx, cancel := newHeadlessContext(ctx)
+	defer cancel()
+
+	// 3. Define tasks to navigate and screenshot the SVG element
+	var screenshotBuf []byte
+
+	tasks := chromedp.Tasks{}
+	if transparentBackground {
+		// Clear the browser's default white page so the PNG keeps its alpha channel; SVGs with a
+		// background rect (the default) cover the whole screenshot and look the same either way
+		tasks = append(tasks, emulation.SetDefaultBackgroundColorOverride().
+			WithColor(&cdp.RGBA{R: 0, G: 0, B: 0, A: 0}))
+	}
+	tasks = append(tasks,
+		// Navigate to the data URI
+		chromedp.Navigate(dataURI),
+		// Wait for the svg element to be present
+		chromedp.WaitVisible(`svg`, chromedp.ByQuery),
+		// Take a screenshot of the first SVG element found
+		chromedp.ScreenshotScale(`svg`, screenshotScale, &screenshotBuf, chromedp.ByQuery),
+	)
+
+	// 4. Run the tasks
+	log.Println("Running chromedp tasks (navigate and screenshot)...")
+	if err := chromedp.Run(ctx, tasks); err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w after %s", ErrRenderTimeout, renderTimeout)
+		}
+		return nil, fmt.Errorf("chromedp execution failed: %w", err)
+	}
+	log.Println("Chromedp tasks completed successfully.")
+
+	if len(screenshotBuf) == 0 {
+		return nil, fmt.Errorf("screenshot buffer is empty, screenshot failed")
+	}
+	return screenshotBuf, nil
+}
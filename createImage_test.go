@@ -0,0 +1,140 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"image/png"
+	"os/exec"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDataURIPrefix(t *testing.T) {
+	svg := `<svg xmlns="http://www.w3.org/2000/svg"></svg>`
+	cases := map[string]string{
+		"svg":  "data:image/svg+xml;base64,",
+		"html": "data:text/html;base64,",
+		"png":  "data:image/png;base64,",
+		"jpg":  "data:image/jpeg;base64,",
+		"jpeg": "data:image/jpeg;base64,",
+	}
+	for format, prefix := range cases {
+		uri, err := encodeDataURI([]byte(svg), format)
+		if err != nil {
+			t.Fatalf("encodeDataURI(%q) returned error: %v", format, err)
+		}
+		if !strings.HasPrefix(uri, prefix) {
+			t.Errorf("encodeDataURI(%q) = %q, want prefix %q", format, uri, prefix)
+		}
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(uri, prefix))
+		if err != nil || string(decoded) != svg {
+			t.Errorf("encodeDataURI(%q) payload does not round-trip: %q (%v)", format, decoded, err)
+		}
+	}
+
+	if _, err := encodeDataURI([]byte(svg), "gif"); err == nil {
+		t.Error("encodeDataURI with unknown format should return an error")
+	}
+}
+
+func TestApplyImagePreset(t *testing.T) {
+	template, scale, err := applyImagePreset(Template{}, "social")
+	if err != nil {
+		t.Fatalf("applyImagePreset(social) returned error: %v", err)
+	}
+	if template.Layout.MinWidth != 1200 || template.Layout.MaxWidth != 1200 ||
+		template.Layout.MinHeight != 630 || template.Layout.MaxHeight != 630 || scale != 1 {
+		t.Errorf("social preset not applied as 1200x630: %+v (scale %.1f)", template.Layout, scale)
+	}
+
+	if _, scale, _ := applyImagePreset(Template{}, "print"); scale <= 1 {
+		t.Errorf("print preset should raise the screenshot scale, got %.1f", scale)
+	}
+	if _, _, err := applyImagePreset(Template{}, "poster"); err == nil {
+		t.Error("Expected an error for an unknown preset")
+	}
+}
+
+// TestRenderSVGToImageRejectsBadInput checks the validation that runs before Chrome is started.
+func TestRenderSVGToImageRejectsBadInput(t *testing.T) {
+	var out strings.Builder
+	if err := renderSVGToImage(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`, "gif", 1, &out); err == nil {
+		t.Errorf("Expected an error for an unsupported image format")
+	}
+	if err := renderSVGToImage("not svg", "png", 1, &out); err == nil {
+		t.Errorf("Expected an error for input that is not an SVG")
+	}
+	if out.Len() != 0 {
+		t.Errorf("Expected nothing written on invalid input, got %d bytes", out.Len())
+	}
+}
+
+// requireChrome skips the test when no Chrome/Chromium binary is installed.
+func requireChrome(t *testing.T) {
+	t.Helper()
+	for _, name := range []string{"google-chrome", "google-chrome-stable", "chromium", "chromium-browser", "headless-shell", "chrome"} {
+		if _, err := exec.LookPath(name); err == nil {
+			return
+		}
+	}
+	t.Skip("Chrome is not installed")
+}
+
+// TestRenderToImageReturnsDecodedImage checks the in-memory render gives an image of the
+// SVG's canvas size times the scale.
+func TestRenderToImageReturnsDecodedImage(t *testing.T) {
+	requireChrome(t)
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002"}}
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	var width, height float64
+	if _, err := fmt.Sscanf(svg, `<svg width="%g" height="%g"`, &width, &height); err != nil {
+		t.Fatalf("Could not parse the canvas size: %v", err)
+	}
+
+	img, err := RenderToImage(context.Background(), template, entries, 2)
+	if err != nil {
+		t.Fatalf("RenderToImage failed: %v", err)
+	}
+	size := img.Bounds().Size()
+	if size.X != int(width*2) || size.Y != int(height*2) {
+		t.Errorf("Expected a %.0fx%.0f image, got %dx%d", width*2, height*2, size.X, size.Y)
+	}
+}
+
+// TestTransparentPNGKeepsAlpha checks a transparent layout gives a PNG with see-through
+// pixels, and that JPG output refuses it before Chrome is started.
+func TestTransparentPNGKeepsAlpha(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.Transparent = true
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}}
+
+	var out bytes.Buffer
+	if _, err := generateImage(template, entries, "jpg", "", "", &out); !errors.Is(err, ErrNoAlphaChannel) {
+		t.Errorf("Expected ErrNoAlphaChannel for transparent JPG output, got %v", err)
+	}
+
+	requireChrome(t)
+	if _, err := generateImage(template, entries, "png", "", "", &out); err != nil {
+		t.Fatalf("generateImage failed: %v", err)
+	}
+	img, err := png.Decode(&out)
+	if err != nil {
+		t.Fatalf("Could not decode the PNG: %v", err)
+	}
+	bounds := img.Bounds()
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			if _, _, _, a := img.At(x, y).RGBA(); a < 0xffff {
+				return
+			}
+		}
+	}
+	t.Errorf("Expected at least one non-opaque pixel in the transparent PNG")
+}
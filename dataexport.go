@@ -0,0 +1,102 @@
+// dataexport.go
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// dataExportFormats lists the formats accepted by -export-data.
+var dataExportFormats = []string{"json", "csv"}
+
+// csvExportColumns are the header of -export-data csv: the entry fields holding plain values.
+var csvExportColumns = []string{
+	"id", "period", "period_end", "subtitle", "title_text", "comment_text", "comment_image", "image_alt",
+	"link", "comment_link", "section", "tags", "hidden", "focus", "comment_side", "year_side", "importance",
+}
+
+// csvTagSeparator joins an entry's tags in its single csv column.
+const csvTagSeparator = ";"
+
+// parseTimelineData parses a data file, either {"entries": [...]} or a bare [...] array.
+func parseTimelineData(diag *diagnosticLog, dataBytes []byte) (TimelineData, error) {
+	var timelineData TimelineData
+	// Attempt parsing as {"entries": [...]} first
+	err := json.Unmarshal(dataBytes, &timelineData)
+	if err == nil {
+		log.Println("Successfully parsed data JSON with 'entries' root key.")
+		return timelineData, nil
+	}
+	// Fallback: Try parsing directly as an array [...]
+	diag.warnf("data_format", "Failed to parse data as root object ('%v'), attempting direct array parsing.", err)
+	var entriesDirect []TimelineEntry
+	if errDirect := json.Unmarshal(dataBytes, &entriesDirect); errDirect != nil {
+		// Report the *original* error, as it's more likely the intended format failed
+		return TimelineData{}, fmt.Errorf("%v (also failed direct array parse: %v)", err, errDirect)
+	}
+	log.Println("Successfully parsed data JSON as a direct array.")
+	return TimelineData{Entries: entriesDirect}, nil
+}
+
+// exportTimelineData writes entries as normalized data (-export-data): json is the
+// {"entries": [...]} form with every field the structs know, which parses back to the same
+// entries; csv has one row per entry with csvExportColumns and warns about entries whose
+// style overrides and other nested fields it has to drop.
+func exportTimelineData(diag *diagnosticLog, entries []TimelineEntry, format string, w io.Writer) error {
+	switch format {
+	case "json":
+		data, err := json.MarshalIndent(TimelineData{Entries: entries}, "", "  ")
+		if err != nil {
+			return fmt.Errorf("could not encode data: %w", err)
+		}
+		_, err = w.Write(append(data, '\n'))
+		return err
+	case "csv":
+		writer := csv.NewWriter(w)
+		if err := writer.Write(csvExportColumns); err != nil {
+			return err
+		}
+		for i, entry := range entries {
+			if !reflect.DeepEqual(entry, csvRepresentable(entry)) {
+				diag.warnf("export_lossy", "entry %d (%s): csv export keeps only the %s columns, other fields are dropped.", i, entry.Period, strings.Join(csvExportColumns, ", "))
+			}
+			if err := writer.Write(csvRow(entry)); err != nil {
+				return err
+			}
+		}
+		writer.Flush()
+		return writer.Error()
+	default:
+		return fmt.Errorf("unsupported data export format '%s' (use %s)", format, strings.Join(dataExportFormats, " or "))
+	}
+}
+
+// csvRepresentable returns the part of entry that fits into csvExportColumns.
+func csvRepresentable(entry TimelineEntry) TimelineEntry {
+	return TimelineEntry{
+		ID: entry.ID, Period: entry.Period, PeriodEnd: entry.PeriodEnd, Subtitle: entry.Subtitle, TitleText: entry.TitleText,
+		CommentText: entry.CommentText, CommentImage: entry.CommentImage, ImageAlt: entry.ImageAlt,
+		Link: entry.Link, CommentLink: entry.CommentLink, Section: entry.Section, Tags: entry.Tags,
+		Hidden: entry.Hidden, Focus: entry.Focus, CommentSide: entry.CommentSide, YearSide: entry.YearSide,
+		Importance: entry.Importance,
+	}
+}
+
+// csvRow returns entry's values in csvExportColumns order.
+func csvRow(entry TimelineEntry) []string {
+	importance := ""
+	if entry.Importance != nil {
+		importance = strconv.FormatFloat(*entry.Importance, 'f', -1, 64)
+	}
+	return []string{
+		entry.ID, entry.Period, entry.PeriodEnd, entry.Subtitle, entry.TitleText, entry.CommentText, entry.CommentImage, entry.ImageAlt,
+		entry.Link, entry.CommentLink, entry.Section, strings.Join(entry.Tags, csvTagSeparator),
+		strconv.FormatBool(entry.Hidden), strconv.FormatBool(entry.Focus), entry.CommentSide, entry.YearSide, importance,
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestExportDataJSONRoundTrip checks that exported json parses back to the same entries.
+func TestExportDataJSONRoundTrip(t *testing.T) {
+	dataBytes, err := os.ReadFile("testdata/test1.data.json")
+	if err != nil {
+		t.Fatalf("Error reading data file: %v", err)
+	}
+	original, err := parseTimelineData(nil, dataBytes)
+	if err != nil {
+		t.Fatalf("Error parsing data file: %v", err)
+	}
+	var exported bytes.Buffer
+	if err := exportTimelineData(nil, original.Entries, "json", &exported); err != nil {
+		t.Fatalf("Error exporting data: %v", err)
+	}
+	roundTripped, err := parseTimelineData(nil, exported.Bytes())
+	if err != nil {
+		t.Fatalf("Error parsing exported data: %v\n%s", err, exported.String())
+	}
+	if !reflect.DeepEqual(original, roundTripped) {
+		t.Errorf("Round-tripped data differs from the original:\n%s", exported.String())
+	}
+}
+
+// TestExportDataCSVColumns checks the csv header and the encoding of tags and importance.
+func TestExportDataCSVColumns(t *testing.T) {
+	importance := 2.5
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Launch, v1", Tags: []string{"public", "press"}, Importance: &importance}}
+	var exported bytes.Buffer
+	if err := exportTimelineData(nil, entries, "csv", &exported); err != nil {
+		t.Fatalf("Error exporting data: %v", err)
+	}
+	records, err := csv.NewReader(&exported).ReadAll()
+	if err != nil {
+		t.Fatalf("Exported csv does not parse: %v", err)
+	}
+	if len(records) != 2 || !reflect.DeepEqual(records[0], csvExportColumns) {
+		t.Fatalf("Expected the header plus one row, got %q", records)
+	}
+	row := map[string]string{}
+	for i, column := range records[0] {
+		row[column] = records[1][i]
+	}
+	if row["title_text"] != "Launch, v1" || row["tags"] != "public;press" || row["importance"] != "2.5" || row["hidden"] != "false" {
+		t.Errorf("Unexpected csv row: %v", row)
+	}
+
+	if err := exportTimelineData(nil, entries, "yaml", &exported); err == nil || !strings.Contains(err.Error(), "unsupported") {
+		t.Errorf("Expected an unsupported format error, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+// diagnostics.go
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+)
+
+// Diagnostic is one warning raised while loading or rendering, in machine-readable form.
+type Diagnostic struct {
+	Code    string `json:"code"`    // Stable identifier such as "missing_image" or "overlap"
+	Message string `json:"message"` // The logged text, without the "Warning: " prefix
+}
+
+// diagnosticLog collects the warnings of one render, or of the whole run in main, for
+// -diagnostics-json. A nil log still logs its warnings, it just doesn't keep them.
+type diagnosticLog struct {
+	warnings []Diagnostic
+}
+
+// warnf logs a warning like log.Printf("Warning: ...") and records it under code.
+func (d *diagnosticLog) warnf(code, format string, args ...any) {
+	message := fmt.Sprintf(format, args...)
+	log.Printf("Warning: %s", message)
+	if d != nil {
+		d.warnings = append(d.warnings, Diagnostic{Code: code, Message: message})
+	}
+}
+
+// add records the warnings returned by a render, skipping ones an earlier render of the same
+// run already recorded (every output format renders the same timeline).
+func (d *diagnosticLog) add(warnings []Diagnostic) {
+	if d == nil {
+		return
+	}
+	for _, warning := range warnings {
+		if !slices.Contains(d.warnings, warning) {
+			d.warnings = append(d.warnings, warning)
+		}
+	}
+}
+
+// debugLogging enables debugf output (the -debug flag).
+var debugLogging bool
+
+// debugf logs layout details for troubleshooting placement, only when debugLogging is on.
+func debugf(format string, args ...any) {
+	if debugLogging {
+		log.Printf("DEBUG: "+format, args...)
+	}
+}
+
+// writeDiagnosticsJSON writes the collected warnings to path as {"warnings": [...]}.
+func writeDiagnosticsJSON(path string, collected []Diagnostic) error {
+	if collected == nil {
+		collected = []Diagnostic{} // Write an empty list rather than null
+	}
+	data, err := json.MarshalIndent(struct {
+		Warnings []Diagnostic `json:"warnings"`
+	}{collected}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("could not encode diagnostics: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("could not write diagnostics to '%s': %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestDiagnosticsCaptureMissingImage checks a missing comment image is returned with the
+// render and written as JSON.
+func TestDiagnosticsCaptureMissingImage(t *testing.T) {
+	dir := t.TempDir()
+	missing := filepath.Join(dir, "missing.png")
+
+	template := loadTestTemplate(t)
+	_, layout, err := generateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentImage: missing}}, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+
+	path := filepath.Join(dir, "diagnostics.json")
+	if err := writeDiagnosticsJSON(path, layout.warnings); err != nil {
+		t.Fatalf("writeDiagnosticsJSON failed: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Could not read diagnostics: %v", err)
+	}
+	var report struct {
+		Warnings []Diagnostic `json:"warnings"`
+	}
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("Diagnostics are not valid JSON: %v", err)
+	}
+	found := false
+	for _, warning := range report.Warnings {
+		if warning.Code == "missing_image" {
+			found = true
+		}
+	}
+	if !found {
+		t.Errorf("Expected a missing_image warning, got %+v", report.Warnings)
+	}
+}
+
+// TestDebugLoggingIsOffByDefault checks layout debug output only appears with -debug.
+func TestDebugLoggingIsOffByDefault(t *testing.T) {
+	var logged bytes.Buffer
+	log.SetOutput(&logged)
+	defer log.SetOutput(os.Stderr)
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}}
+
+	if _, err := GenerateSVG(template, entries); err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(logged.String(), "DEBUG") {
+		t.Fatalf("Expected no debug output by default, got %q", logged.String())
+	}
+
+	debugLogging = true
+	defer func() { debugLogging = false }()
+	if _, err := GenerateSVG(template, entries); err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(logged.String(), "DEBUG: assembleFinalSVG bounds: minX=") {
+		t.Errorf("Expected the bounds dump with -debug, got %q", logged.String())
+	}
+}
+
+// TestDebugBoxesOverlayOnlyWhenEnabled checks the -debug-boxes overlay draws one box per year
+// and comment plus the content area, and leaves the canvas size unchanged.
+func TestDebugBoxesOverlayOnlyWhenEnabled(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002"}}
+
+	plain, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(plain, "debug-box") {
+		t.Fatalf("Expected no debug boxes by default")
+	}
+
+	template.Layout.debugBoxes = true
+	overlaid, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	for kind, want := range map[string]int{"debug-year": 2, "debug-comment": 1, "debug-content": 1} {
+		if got := strings.Count(overlaid, kind+`"`); got != want {
+			t.Errorf("Expected %d %s boxes, got %d", want, kind, got)
+		}
+	}
+	if svgHeight(t, overlaid) != svgHeight(t, plain) {
+		t.Errorf("Expected the overlay to leave the canvas size unchanged")
+	}
+}
+
+// TestDiagnosticsArePerRender checks each render returns only its own warnings and that a
+// run records a warning repeated by several renders once.
+func TestDiagnosticsArePerRender(t *testing.T) {
+	template := loadTestTemplate(t)
+	missing := filepath.Join(t.TempDir(), "missing.png")
+	_, first, err := generateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentImage: missing}}, nil)
+	if err != nil || len(first.warnings) != 1 || first.warnings[0].Code != "missing_image" {
+		t.Fatalf("Expected one missing_image warning, got %+v (%v)", first.warnings, err)
+	}
+	_, second, err := generateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body"}}, nil)
+	if err != nil || len(second.warnings) != 0 {
+		t.Errorf("Expected a clean render to return no warnings, got %+v (%v)", second.warnings, err)
+	}
+
+	var run diagnosticLog
+	run.add(first.warnings)
+	run.add(first.warnings)
+	if len(run.warnings) != 1 {
+		t.Errorf("Expected the repeated warning recorded once, got %+v", run.warnings)
+	}
+}
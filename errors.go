@@ -0,0 +1,30 @@
+// errors.go
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Sentinel errors for the failure kinds callers may want to handle; check them with errors.Is.
+var (
+	ErrNoEntries         = errors.New("no timeline entries to generate")
+	ErrRenderTimeout     = errors.New("image rendering timed out")
+	ErrUnsupportedFormat = errors.New("unsupported format")
+	ErrMissingImages     = errors.New("missing image files")
+	ErrNoAlphaChannel    = errors.New("format has no alpha channel")
+	ErrLocalReference    = errors.New("local file reference in data from stdin or a URL")
+)
+
+// GenerationError reports a failed svg, html or image generation. Err is the cause, wrapping
+// one of the sentinel errors for the known failure kinds.
+type GenerationError struct {
+	Format string // Output being generated: "svg", "html", "png" or "jpg"
+	Err    error
+}
+
+func (e *GenerationError) Error() string {
+	return fmt.Sprintf("%s generation failed: %v", e.Format, e.Err)
+}
+
+func (e *GenerationError) Unwrap() error { return e.Err }
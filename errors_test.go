@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestGenerationErrorsMatchSentinels checks callers can tell failure kinds apart with errors.Is
+// and errors.As.
+func TestGenerationErrorsMatchSentinels(t *testing.T) {
+	template := loadTestTemplate(t)
+
+	_, err := GenerateSVG(template, nil)
+	if !errors.Is(err, ErrNoEntries) {
+		t.Errorf("Expected ErrNoEntries from GenerateSVG, got %v", err)
+	}
+	var genErr *GenerationError
+	if !errors.As(err, &genErr) || genErr.Format != "svg" {
+		t.Errorf("Expected a GenerationError for svg, got %#v", err)
+	}
+	if _, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", Hidden: true}}); !errors.Is(err, ErrNoEntries) {
+		t.Errorf("Expected ErrNoEntries from generateHTML with only hidden entries, got %v", err)
+	}
+	if _, err := RenderToImage(context.Background(), template, nil, 1); !errors.Is(err, ErrNoEntries) {
+		t.Errorf("Expected ErrNoEntries from RenderToImage before Chrome is started, got %v", err)
+	}
+
+	var out strings.Builder
+	if err := renderSVGToImage(`<svg xmlns="http://www.w3.org/2000/svg"></svg>`, "gif", 1, &out); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat from renderSVGToImage, got %v", err)
+	}
+	if _, err := parseFormats("svg,gif"); !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("Expected ErrUnsupportedFormat from parseFormats, got %v", err)
+	}
+	if errors.Is(err, ErrRenderTimeout) {
+		t.Errorf("Did not expect ErrRenderTimeout for a missing-entries error")
+	}
+}
+
+// TestValidateDataReportsMissingImagesTogether checks every missing local image is reported
+// in one ErrMissingImages error while existing files, URLs and data URIs pass.
+func TestValidateDataReportsMissingImagesTogether(t *testing.T) {
+	dir := t.TempDir()
+	existing := filepath.Join(dir, "present.png")
+	if err := os.WriteFile(existing, []byte("png"), 0o644); err != nil {
+		t.Fatalf("Error writing image: %v", err)
+	}
+	entries := []TimelineEntry{
+		{Period: "2001", CommentImage: existing},
+		{Period: "2002", CommentImage: filepath.Join(dir, "missing.png")},
+		{Period: "2003", CommentImage: "https://example.com/remote.png", ThumbnailImage: "data:image/png;base64,AAAA"},
+		{Period: "2004", ThumbnailImage: filepath.Join(dir, "face.jpg")},
+		{Period: "2005", CommentImage: filepath.Join(dir, "hidden.png"), Hidden: true},
+	}
+	err := ValidateData(entries)
+	if !errors.Is(err, ErrMissingImages) {
+		t.Fatalf("Expected ErrMissingImages, got %v", err)
+	}
+	for _, want := range []string{"missing.png", "thumbnail_image", "face.jpg"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("Expected the error to mention %q, got %v", want, err)
+		}
+	}
+	for _, unwanted := range []string{"present.png", "remote.png", "hidden.png"} {
+		if strings.Contains(err.Error(), unwanted) {
+			t.Errorf("Did not expect %q in the error, got %v", unwanted, err)
+		}
+	}
+	if err := ValidateData(entries[:1]); err != nil {
+		t.Errorf("Expected no error when every image exists, got %v", err)
+	}
+}
@@ -0,0 +1,209 @@
+// fonts.go
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// genericFontFamilies are CSS generic families, which always resolve to some font.
+var genericFontFamilies = map[string]bool{
+	"serif": true, "sans-serif": true, "monospace": true, "cursive": true,
+	"fantasy": true, "system-ui": true, "ui-serif": true, "ui-sans-serif": true,
+	"ui-monospace": true, "ui-rounded": true, "emoji": true, "math": true,
+}
+
+// MissingFonts returns the font families referenced in the template and the entries' style
+// overrides whose primary (first) family is not installed on this system, i.e. the ones
+// Chrome would silently replace when rendering PNG/JPG output. The error reports that the
+// installed fonts couldn't be listed.
+func MissingFonts(template Template, entries []TimelineEntry) ([]string, error) {
+	installed, err := installedFontFamilies()
+	if err != nil {
+		return nil, err
+	}
+	return missingFonts(template, entries, installed), nil
+}
+
+// missingFonts checks the fonts of the template and the entry overrides against a set of
+// installed families (lowercase keys).
+func missingFonts(template Template, entries []TimelineEntry, installed map[string]bool) []string {
+	families := []string{
+		template.PeriodDefaults.YearText.Font.FontFamily,
+		template.PeriodDefaults.YearText.SubtitleFont.FontFamily,
+		template.PeriodDefaults.CommentText.Font.FontFamily,
+		template.PeriodDefaults.CommentText.TitleFont.FontFamily,
+		template.Layout.CaptionFont.FontFamily,
+		template.Layout.NumberStyle.Font.FontFamily,
+	}
+	if template.GlobalFont != nil {
+		families = append(families, template.GlobalFont.FontFamily)
+	}
+	for _, entry := range entries {
+		if override := entry.YearTextOverride; override != nil {
+			families = append(families, overrideFontFamily(override.Font), overrideFontFamily(override.SubtitleFont))
+		}
+		if override := entry.CommentTextOverride; override != nil {
+			families = append(families, overrideFontFamily(override.Font), overrideFontFamily(override.TitleFont))
+		}
+	}
+
+	seen := make(map[string]bool)
+	var missing []string
+	for _, fontFamily := range families {
+		family := primaryFontFamily(fontFamily)
+		key := strings.ToLower(family)
+		if family == "" || seen[key] || genericFontFamilies[key] {
+			continue
+		}
+		seen[key] = true
+		if !installed[key] {
+			missing = append(missing, family)
+		}
+	}
+	sort.Strings(missing)
+	return missing
+}
+
+// overrideFontFamily returns the font_family a font override sets, "" when it keeps the default.
+func overrideFontFamily(override *FontStyleOverride) string {
+	if override == nil || override.FontFamily == nil {
+		return ""
+	}
+	return *override.FontFamily
+}
+
+// primaryFontFamily returns the first family of a CSS font-family list, without quotes.
+func primaryFontFamily(fontFamily string) string {
+	first, _, _ := strings.Cut(fontFamily, ",")
+	return strings.Trim(strings.TrimSpace(first), `"'`)
+}
+
+// cssFontIdentifierRegex matches family names that are safe to write unquoted.
+var cssFontIdentifierRegex = regexp.MustCompile(`^-?[A-Za-z_][A-Za-z0-9_-]*$`)
+
+// cssFontFamily normalizes a comma separated font stack such as `Open Sans, "Noto Sans", sans-serif`
+// for both SVG font-family attributes and CSS: names that aren't a single identifier are single
+// quoted (safe inside double quoted attributes), generic families stay bare and characters that
+// could break out of the attribute or declaration are dropped.
+func cssFontFamily(fontFamily string) string {
+	var families []string
+	for _, family := range strings.Split(fontFamily, ",") {
+		family = strings.Map(func(r rune) rune {
+			if strings.ContainsRune(`"'\;<>{}&`, r) {
+				return -1
+			}
+			return r
+		}, family)
+		family = strings.Join(strings.Fields(family), " ")
+		if family == "" {
+			continue
+		}
+		if !genericFontFamilies[strings.ToLower(family)] && !cssFontIdentifierRegex.MatchString(family) {
+			family = "'" + family + "'"
+		}
+		families = append(families, family)
+	}
+	return strings.Join(families, ", ")
+}
+
+// fontFileFormats maps font file extensions to their data URI MIME type and CSS format() hint.
+var fontFileFormats = map[string][2]string{
+	".ttf":   {"font/ttf", "truetype"},
+	".otf":   {"font/otf", "opentype"},
+	".woff":  {"font/woff", "woff"},
+	".woff2": {"font/woff2", "woff2"},
+}
+
+// fontFaceCSS builds @font-face rules embedding the given fonts as data URIs. Each spec is
+// "Family=path", or just a path whose file name (without extension) is used as the family;
+// a directory path embeds every font file in it.
+func fontFaceCSS(specs []string) (string, error) {
+	var css strings.Builder
+	for _, spec := range specs {
+		family, path, named := strings.Cut(strings.TrimSpace(spec), "=")
+		if !named {
+			path, family = family, ""
+		}
+		paths := []string{path}
+		if info, err := os.Stat(path); err != nil {
+			return "", fmt.Errorf("could not read font '%s': %w", path, err)
+		} else if info.IsDir() {
+			entries, err := os.ReadDir(path)
+			if err != nil {
+				return "", fmt.Errorf("could not list fonts in '%s': %w", path, err)
+			}
+			paths = paths[:0]
+			for _, entry := range entries {
+				if _, ok := fontFileFormats[strings.ToLower(filepath.Ext(entry.Name()))]; ok && !entry.IsDir() {
+					paths = append(paths, filepath.Join(path, entry.Name()))
+				}
+			}
+			if len(paths) == 0 {
+				return "", fmt.Errorf("no font files (.ttf, .otf, .woff, .woff2) found in '%s'", path)
+			}
+		}
+
+		for _, fontPath := range paths {
+			ext := strings.ToLower(filepath.Ext(fontPath))
+			format, ok := fontFileFormats[ext]
+			if !ok {
+				return "", fmt.Errorf("unsupported font file '%s' (use .ttf, .otf, .woff or .woff2)", fontPath)
+			}
+			data, err := os.ReadFile(fontPath)
+			if err != nil {
+				return "", fmt.Errorf("could not read font '%s': %w", fontPath, err)
+			}
+			fontFamily := family
+			if fontFamily == "" {
+				fontFamily = strings.TrimSuffix(filepath.Base(fontPath), filepath.Ext(fontPath))
+			}
+			fmt.Fprintf(&css, "@font-face { font-family: %s; src: url(data:%s;base64,%s) format('%s'); }\n",
+				cssFontFamily("'"+fontFamily+"'"), format[0], base64.StdEncoding.EncodeToString(data), format[1])
+		}
+	}
+	return css.String(), nil
+}
+
+// embedFontFaces inserts the @font-face rules as a <style> element right after the
+// opening <svg> tag, so the fonts are available before any text is drawn.
+func embedFontFaces(svg, fontCSS string) string {
+	if fontCSS == "" {
+		return svg
+	}
+	start := strings.Index(svg, "<svg")
+	if start < 0 {
+		return svg
+	}
+	end := strings.Index(svg[start:], ">")
+	if end < 0 {
+		return svg
+	}
+	end += start + 1
+	return svg[:end] + "\n  <style>\n" + fontCSS + "  </style>" + svg[end:]
+}
+
+// installedFontFamilies lists the font families known to fontconfig, which is what
+// headless Chrome resolves fonts against. Keys are lowercase.
+func installedFontFamilies() (map[string]bool, error) {
+	output, err := exec.Command("fc-list", ":", "family").Output()
+	if err != nil {
+		return nil, fmt.Errorf("could not list installed fonts with fc-list: %w", err)
+	}
+	families := make(map[string]bool)
+	for _, line := range strings.Split(string(output), "\n") {
+		// Fonts with localized names list them comma separated: "DejaVu Sans,DejaVu Sans Light"
+		for _, family := range strings.Split(line, ",") {
+			if family = strings.TrimSpace(family); family != "" {
+				families[strings.ToLower(family)] = true
+			}
+		}
+	}
+	return families, nil
+}
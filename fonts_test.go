@@ -0,0 +1,93 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// TestMissingFontsChecksPrimaryFamily checks only the first family of each stack is reported, once.
+func TestMissingFontsChecksPrimaryFamily(t *testing.T) {
+	var template Template
+	template.GlobalFont = &FontStyle{FontFamily: "Arial, sans-serif"}
+	template.PeriodDefaults.YearText.Font.FontFamily = `"Fancy Display", Arial`
+	template.PeriodDefaults.CommentText.Font.FontFamily = "serif"
+	template.PeriodDefaults.CommentText.TitleFont.FontFamily = "fancy display"
+
+	installed := map[string]bool{"arial": true}
+	got := missingFonts(template, nil, installed)
+	if want := []string{"Fancy Display"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingFonts = %v, want %v", got, want)
+	}
+
+	// Fonts set by entry overrides are checked too
+	yearFamily, titleFamily := "Year Serif", "arial"
+	entries := []TimelineEntry{
+		{Period: "2001", YearTextOverride: &YearTextStyleOverride{Font: &FontStyleOverride{FontFamily: &yearFamily}}},
+		{Period: "2002", CommentTextOverride: &CommentTextStyleOverride{TitleFont: &FontStyleOverride{FontFamily: &titleFamily}}},
+	}
+	got = missingFonts(template, entries, installed)
+	if want := []string{"Fancy Display", "Year Serif"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingFonts with overrides = %v, want %v", got, want)
+	}
+
+	// So are the subtitle, caption and number badge fonts
+	template.PeriodDefaults.YearText.SubtitleFont.FontFamily = "Subtitle Sans"
+	template.Layout.CaptionFont.FontFamily = "Caption Serif"
+	template.Layout.NumberStyle.Font.FontFamily = "Badge Mono"
+	got = missingFonts(template, nil, installed)
+	if want := []string{"Badge Mono", "Caption Serif", "Fancy Display", "Subtitle Sans"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("missingFonts with layout fonts = %v, want %v", got, want)
+	}
+}
+
+// TestCSSFontFamilyQuotesStacks checks multi-word names are quoted once and generic families stay bare.
+func TestCSSFontFamilyQuotesStacks(t *testing.T) {
+	tests := map[string]string{
+		"Arial, Helvetica, sans-serif":    "Arial, Helvetica, sans-serif",
+		`Open Sans, "Noto Sans",  serif`:  "'Open Sans', 'Noto Sans', serif",
+		"'Times New Roman',Georgia":       "'Times New Roman', Georgia",
+		`Evil"><script>, monospace`:       "Evilscript, monospace",
+		"":                                "",
+		"Source Han Sans SC, , System-UI": "'Source Han Sans SC', System-UI",
+		"3Dumb":                           "'3Dumb'",
+	}
+	for input, want := range tests {
+		if got := cssFontFamily(input); got != want {
+			t.Errorf("cssFontFamily(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+// TestEmbedFontFacesAddsDataURIStyle checks a font file becomes an @font-face rule inside the SVG.
+func TestEmbedFontFacesAddsDataURIStyle(t *testing.T) {
+	dir := t.TempDir()
+	fontPath := filepath.Join(dir, "Fancy Display.woff2")
+	if err := os.WriteFile(fontPath, []byte("font"), 0o644); err != nil {
+		t.Fatalf("Could not write font: %v", err)
+	}
+
+	css, err := fontFaceCSS([]string{dir, "Brand=" + fontPath})
+	if err != nil {
+		t.Fatalf("fontFaceCSS failed: %v", err)
+	}
+	for _, want := range []string{
+		"font-family: 'Fancy Display'; src: url(data:font/woff2;base64,Zm9udA==) format('woff2');",
+		"font-family: Brand;",
+	} {
+		if !strings.Contains(css, want) {
+			t.Errorf("Expected %q in font CSS, got:\n%s", want, css)
+		}
+	}
+
+	svg := embedFontFaces(`<svg width="10" height="10"><text>x</text></svg>`, css)
+	if !strings.HasPrefix(svg, `<svg width="10" height="10">`+"\n  <style>\n@font-face") {
+		t.Errorf("Expected the style right after the opening svg tag, got:\n%s", svg)
+	}
+
+	if _, err := fontFaceCSS([]string{filepath.Join(dir, "missing.ttf")}); err == nil {
+		t.Errorf("Expected an error for a missing font file")
+	}
+}
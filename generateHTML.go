@@ -3,23 +3,31 @@ package main
 
 import (
 	"fmt"
-	"log"
+	"html"
+	"math"
+	"strconv"
 	"strings"
-	// "math" // No longer needed here after CSS changes
 )
 
-// generateHTML creates a basic HTML representation of the timeline.
-func generateHTML(template Template, entries []TimelineEntry) (string, error) { // NOSONAR
+// generateHTML creates a basic HTML representation of the timeline, returned with the
+// warnings raised while building it.
+func generateHTML(template Template, entries []TimelineEntry) (string, []Diagnostic, error) { // NOSONAR
 	var htmlBuilder strings.Builder
+	entries = visibleEntries(entries) // Hidden entries are skipped entirely
+	if len(entries) == 0 {
+		return "", nil, &GenerationError{Format: "html", Err: ErrNoEntries}
+	}
+	colorMap := normalizeColorMap(template.Layout.ColorMap)
+	diag := &diagnosticLog{}
 
 	// --- Basic HTML Structure ---
-	htmlBuilder.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<title>Timeline</title>\n")
+	htmlBuilder.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<title>" + htmlPageTitle(template) + "</title>\n")
 	htmlBuilder.WriteString("<style>\n")
 
 	// --- Global Font Styles ---
 	globalStyle := getEffectiveFontStyle(nil, *template.GlobalFont, nil)
 	htmlBuilder.WriteString(fmt.Sprintf("body { margin: 0; padding: 40px; font-family: %s; font-size: %dpx; font-weight: %s; font-style: %s; }\n",
-		escapeCSS(globalStyle.FontFamily), globalStyle.FontSize, escapeCSS(globalStyle.FontWeight), escapeCSS(globalStyle.FontStyle)))
+		cssFontFamily(globalStyle.FontFamily), globalStyle.FontSize, escapeCSS(globalStyle.FontWeight), escapeCSS(globalStyle.FontStyle)))
 
 	htmlBuilder.WriteString(".timeline-container { position: relative; margin: 20px auto; border: 1px solid #eee; /* Debug border */ }\n")
 
@@ -28,6 +36,7 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 	if lineColor == "" {
 		lineColor = "#000"
 	}
+	lineColor = mapColor(colorMap, lineColor)
 	lineWidth := template.CenterLine.Width
 	if lineWidth <= 0 {
 		lineWidth = 2
@@ -41,20 +50,13 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 	// --- Estimate Container Size & Define Line ---
 	containerHeight := 600.0   // Default height
 	containerWidthCSS := "90%" // Default width (can be overridden below)
-	// Calculate estimated total length along the main axis for container sizing
-	totalAxisLength := template.Layout.Padding * 2 // Start with padding
-	currentPosForLength := template.Layout.Padding
-	for _, entry := range entries {
-		spacing := template.Layout.EntrySpacing
-		if entry.EntrySpacingOverride != nil {
-			spacing = *entry.EntrySpacingOverride
-		}
-		if spacing <= 0 {
-			spacing = template.Layout.EntrySpacing
-		} // Fallback
-		currentPosForLength += spacing
+	// Entries grouped into collapsible sections, each drawn as its own timeline
+	var sectionStarts map[int]htmlSection
+	if template.Layout.AccordionSections {
+		entries, sectionStarts = groupEntriesBySection(entries)
 	}
-	totalAxisLength = currentPosForLength // Total length is end position after last spacing
+	// Calculate estimated total length along the main axis for container sizing
+	totalAxisLength := htmlAxisLength(template, entries)
 
 	if isHorizontal {
 		containerHeight = 400                                      // Fixed height for horizontal example
@@ -105,18 +107,55 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
             z-index: 5; /* Below year text if they overlap slightly */
         }
          .comment-box img { max-width: 100%; height: auto; display: block; margin: 5px auto; }
+        .timeline-connector {
+            position: absolute;
+            height: 0;
+            transform-origin: 0 50%;
+            z-index: 1; /* Above the center line, below years and comments */
+        }
+        .timeline-connector-dot {
+            position: absolute;
+            z-index: 2;
+        }
         a { color: inherit; text-decoration: none; }
         a:hover { text-decoration: underline; }
     `)
 	htmlBuilder.WriteString("\n")
+	if template.Layout.Interactive {
+		htmlBuilder.WriteString(htmlInteractiveCSS)
+	}
+	if template.Layout.AccordionSections {
+		htmlBuilder.WriteString(htmlAccordionCSS)
+	}
 	htmlBuilder.WriteString("</style>\n</head>\n<body>\n")
-	htmlBuilder.WriteString("<div class=\"timeline-container\">\n")
-	htmlBuilder.WriteString("  <div class=\"center-line\"></div>\n")
+	if template.Layout.Interactive {
+		htmlBuilder.WriteString(htmlSearchBox)
+	}
+	if sectionStarts == nil {
+		htmlBuilder.WriteString("<div class=\"timeline-container\">\n")
+		htmlBuilder.WriteString("  <div class=\"center-line\"></div>\n")
+	}
+	entryIDs := buildEntryIDs(entries) // Same ids as the SVG entry groups
 
 	// --- Loop Through Entries ---
 	currentPos := template.Layout.Padding // Start position from padding edge
 
 	for i, entry := range entries {
+		// --- Section start (accordion_sections): close the previous one, restart the axis ---
+		if section, starts := sectionStarts[i]; starts {
+			if i > 0 {
+				htmlBuilder.WriteString("</div>\n</details>\n")
+			}
+			sizeStyle := fmt.Sprintf("width: %.0fpx;", htmlAxisLength(template, section.Entries))
+			if !isHorizontal {
+				sizeStyle = fmt.Sprintf("height: %.0fpx;", htmlAxisLength(template, section.Entries))
+			}
+			htmlBuilder.WriteString(fmt.Sprintf("<details class=\"timeline-section\" open>\n<summary>%s</summary>\n", escapeHTML(section.Name)))
+			htmlBuilder.WriteString(fmt.Sprintf("<div class=\"timeline-container\" style=\"%s\">\n", sizeStyle))
+			htmlBuilder.WriteString("  <div class=\"center-line\"></div>\n")
+			currentPos = template.Layout.Padding
+		}
+
 		// --- Calculate Segment Details ---
 		spacing := template.Layout.EntrySpacing
 		if entry.EntrySpacingOverride != nil {
@@ -132,8 +171,9 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 		commentStyle := getEffectiveCommentTextStyle(template.GlobalFont, template.PeriodDefaults.CommentText, entry.CommentTextOverride)
 
 		// --- Calculate Positioning Targets ---
-		yearCrossAxisDir := getCrossAxisDirection(yearStyle.Position, i, isHorizontal)
-		commentCrossAxisDir := getCrossAxisDirection(commentStyle.Position, i, isHorizontal)
+		yearCrossAxisDir := getCrossAxisDirection(yearStyle.Position, i+template.Layout.entryOffset, isHorizontal)
+		commentCrossAxisDir := getCrossAxisDirection(commentStyle.Position, i+template.Layout.entryOffset, isHorizontal)
+		commentCrossAxisDir, yearCrossAxisDir = applyEntrySides(diag, entry, commentCrossAxisDir, yearCrossAxisDir)
 
 		baseConnectorLength := template.Layout.ConnectorLength
 		yearTargetX, yearTargetY := 0.0, 0.0       // Target coords for year element's anchor
@@ -141,24 +181,38 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 
 		if isHorizontal {
 			// Year position
-			yearTargetX = entryCenterPos // Remove + yearStyle.MainAxisOffset
+			yearTargetX = entryCenterPos + yearStyle.MainAxisOffset // Shifted along the axis like the SVG element
 			yearTargetY = (containerHeight / 2.0) + (yearCrossAxisDir * (baseConnectorLength /* + yearStyle.CrossAxisOffset - Use yearStyle.Offset here if needed */))
 
 			// Comment position
-			commentTargetX = entryCenterPos // Remove + commentStyle.MainAxisOffset
+			commentTargetX = entryCenterPos + commentStyle.MainAxisOffset // Staggers overlapping boxes along the axis
 			commentTargetY = (containerHeight / 2.0) + (commentCrossAxisDir * (baseConnectorLength /* + commentStyle.CrossAxisOffset - Comment doesn't have simple offset */))
 		} else { // Vertical
 			// Year position - Use percentage for X-axis (left: 50%) and adjust with transform
-			yearTargetY = entryCenterPos // Remove + yearStyle.MainAxisOffset // Y position along the axis
+			yearTargetY = entryCenterPos + yearStyle.MainAxisOffset // Y position along the axis
 			// X target represents the offset from the center line
 			yearTargetX = yearCrossAxisDir * (baseConnectorLength /* + yearStyle.CrossAxisOffset - Use yearStyle.Offset here if needed */)
 
 			// Comment position - Use percentage for X-axis
-			commentTargetY = entryCenterPos // Remove + commentStyle.MainAxisOffset // Y position along the axis
+			commentTargetY = entryCenterPos + commentStyle.MainAxisOffset // Y position along the axis
 			// X target represents the offset from the center line
 			commentTargetX = commentCrossAxisDir * (baseConnectorLength /* + commentStyle.CrossAxisOffset - Comment doesn't have simple offset */)
 		}
 
+		// --- Connectors (straight, from the axis point to each element's anchor) ---
+		connStyle := getEffectiveConnectorStyle(template.PeriodDefaults.Connector, entry.ConnectorOverride)
+		axisX, axisY := entryCenterPos, containerHeight/2.0
+		if !isHorizontal {
+			axisX, axisY = 0, entryCenterPos // Vertical: x is relative to the centered axis
+		}
+		if connStyle.DrawToPeriod == nil || *connStyle.DrawToPeriod {
+			htmlBuilder.WriteString(htmlConnector(colorMap, axisX, axisY, yearTargetX, yearTargetY, !isHorizontal, connStyle, lineColor))
+		}
+		hasHTMLComment := entry.CommentText != "" || entry.CommentImage != ""
+		if hasHTMLComment && (connStyle.DrawToComment == nil || *connStyle.DrawToComment) {
+			htmlBuilder.WriteString(htmlConnector(colorMap, axisX, axisY, commentTargetX, commentTargetY, !isHorizontal, connStyle, lineColor))
+		}
+
 		// --- Year Text Element ---
 		yearFont := yearStyle.Font
 		yearColor := yearStyle.TextColor
@@ -167,7 +221,10 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 		}
 
 		yearInlineStyle := fmt.Sprintf("color:%s; font-family:%s; font-size:%dpx; font-weight:%s; font-style:%s;",
-			escapeCSS(yearColor), escapeCSS(yearFont.FontFamily), yearFont.FontSize, escapeCSS(yearFont.FontWeight), escapeCSS(yearFont.FontStyle))
+			escapeCSS(mapColor(colorMap, yearColor)), cssFontFamily(yearFont.FontFamily), yearFont.FontSize, escapeCSS(yearFont.FontWeight), escapeCSS(yearFont.FontStyle))
+		if yearStyle.LineHeight != nil && *yearStyle.LineHeight > 0 {
+			yearInlineStyle += fmt.Sprintf(" line-height:%.2f;", *yearStyle.LineHeight)
+		}
 
 		// CSS positioning styles
 		yearPosStyle := ""
@@ -195,47 +252,15 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 			linkOpenTag = fmt.Sprintf(`<a href="%s" target="_blank">`, escapeHTML(entry.Link))
 			linkCloseTag = `</a>`
 		}
-		htmlBuilder.WriteString(fmt.Sprintf("  <div class=\"timeline-element year-text-container\" style=\"%s\">\n", yearPosStyle)) // Apply positioning
-		htmlBuilder.WriteString(fmt.Sprintf("    %s<div class=\"year-text\" style=\"%s\">%s</div>%s\n", linkOpenTag, yearInlineStyle, escapeHTML(entry.Period), linkCloseTag))
+		htmlBuilder.WriteString(fmt.Sprintf("  <div id=\"%s\" class=\"timeline-element year-text-container\" data-entry=\"%s\" style=\"%s\">\n",
+			escapeHTML(entryIDs[i]), escapeHTML(entryIDs[i]), yearPosStyle)) // Apply positioning
+		htmlBuilder.WriteString(fmt.Sprintf("    %s<div class=\"year-text\" style=\"%s\">%s</div>%s\n", linkOpenTag, yearInlineStyle, strings.ReplaceAll(escapeHTML(entry.Period), "\n", "<br />"), linkCloseTag))
 		htmlBuilder.WriteString("  </div>\n") // Close year-text-container
 
 		// --- Comment Element (if exists) ---
-		if entry.CommentText != "" || entry.CommentImage != "" {
-			commentFont := commentStyle.Font
-			commentTextColor := commentStyle.TextColor
-			if commentTextColor == "" {
-				commentTextColor = "inherit"
-			}
-
-			// Base style for the comment box div content
-			commentBoxStyle := fmt.Sprintf("color:%s; font-family:%s; font-size:%dpx; font-weight:%s; font-style:%s;",
-				escapeCSS(commentTextColor), escapeCSS(commentFont.FontFamily), commentFont.FontSize, escapeCSS(commentFont.FontWeight), escapeCSS(commentFont.FontStyle))
-
-			// Add shape styling
-			if commentStyle.Shape == "rectangle" {
-				bgColor := commentStyle.FillColor
-				if bgColor != "" {
-					commentBoxStyle += fmt.Sprintf(" background-color:%s;", escapeCSS(bgColor))
-				} else {
-					commentBoxStyle += " background-color: transparent;"
-				}
-				borderColor := commentStyle.BorderColor
-				borderWidth := commentStyle.BorderWidth
-				if borderColor != "" && borderWidth > 0 {
-					borderStyle := commentStyle.BorderStyle
-					if borderStyle == "" {
-						borderStyle = "solid"
-					}
-					commentBoxStyle += fmt.Sprintf(" border: %dpx %s %s;", borderWidth, escapeCSS(borderStyle), escapeCSS(borderColor))
-				} else {
-					commentBoxStyle += " border: none;"
-				}
-				// Parse padding string and apply
-				padTop, padRight, padBottom, padLeft := parsePadding(commentStyle.Padding)
-				commentBoxStyle += fmt.Sprintf(" padding: %.0fpx %.0fpx %.0fpx %.0fpx;", padTop, padRight, padBottom, padLeft)
-			} else { // shape == "none"
-				commentBoxStyle += " background-color: transparent; border: none; padding: 0;"
-			}
+		if hasHTMLComment {
+			// Base style for the comment box div content (template colors win over the .comment-box fallbacks)
+			commentBoxStyle := htmlCommentBoxStyle(diag, colorMap, commentStyle, connStyle.Color)
 
 			// CSS positioning styles for the comment container
 			commentPosStyle := ""
@@ -247,22 +272,48 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 				commentPosStyle = fmt.Sprintf("top: %.0fpx; left: 50%%; transform: translate(%s, -50%%);", // Center vertically
 					commentTargetY,
 					ternary(commentCrossAxisDir < 0, fmt.Sprintf("calc(-100%% + %.0fpx)", commentTargetX), fmt.Sprintf("%.0fpx", commentTargetX))) // Offset from center
-				// Adjust text alignment based on side
-				if commentCrossAxisDir < 0 {
-					commentBoxStyle += " text-align: right;" // Align text inside box
-				} else {
-					commentBoxStyle += " text-align: left;"
-				}
 			}
+			// Align text inside the box; unset behaves like "auto" here, mirroring by side on vertical timelines
+			commentTextAlign := commentStyle.TextAlign
+			if commentTextAlign == "" {
+				commentTextAlign = "auto"
+			}
+			commentBoxStyle += fmt.Sprintf(" text-align: %s;", escapeCSS(resolveCommentTextAlign(commentTextAlign, isHorizontal, commentCrossAxisDir)))
 
 			imageTag := ""
+			imagePosition := resolveCommentImagePosition(diag, commentStyle.ImagePosition)
 			if entry.CommentImage != "" {
-				imageTag = fmt.Sprintf(`<img src="%s" alt="Timeline image"/>`, escapeHTML(entry.CommentImage))
+				imgAlt := entry.ImageAlt
+				if imgAlt == "" {
+					imgAlt = defaultImageAlt
+				}
+				imageStyle := ""
+				if imagePosition != "top" {
+					imageStyle = fmt.Sprintf(` style="%s"`, commentImageCSS(imagePosition))
+				}
+				imageTag = fmt.Sprintf(`<img src="%s"%s alt="%s"/>`, escapeHTML(entry.CommentImage), imageStyle, escapeHTML(imgAlt))
 			}
 			commentContent := entry.CommentText // Allow HTML
 
-			htmlBuilder.WriteString(fmt.Sprintf("  <div class=\"timeline-element comment-box-container\" style=\"%s\">\n", commentPosStyle)) // Apply positioning
-			htmlBuilder.WriteString(fmt.Sprintf("    <div class=\"comment-box\" style=\"%s\">%s%s</div>\n", commentBoxStyle, imageTag, commentContent))
+			commentLinkOpen, commentLinkClose := "", ""
+			if entry.CommentLink != "" {
+				if containsLink(commentContent) { // Nested anchors are invalid HTML
+					diag.warnf("comment_link_ignored", "comment_link \"%s\" ignored because the comment body contains links.", entry.CommentLink)
+				} else {
+					commentLinkOpen = fmt.Sprintf(`<a href="%s" target="_blank">`, escapeHTML(entry.CommentLink))
+					commentLinkClose = `</a>`
+				}
+			}
+
+			htmlBuilder.WriteString(fmt.Sprintf("  <div class=\"timeline-element comment-box-container\" data-entry=\"%s\" style=\"%s\">\n",
+				escapeHTML(entryIDs[i]), commentPosStyle)) // Apply positioning
+			bodyContent := imageTag + commentContent
+			if imagePosition == "bottom" {
+				bodyContent = commentContent + imageTag
+			} else if imageTag != "" && imagePosition != "top" {
+				bodyContent += `<div style="clear: both;"></div>` // Contain the floated image
+			}
+			htmlBuilder.WriteString(fmt.Sprintf("    %s<div class=\"comment-box\" style=\"%s\">%s</div>%s\n", commentLinkOpen, commentBoxStyle, bodyContent, commentLinkClose))
 			htmlBuilder.WriteString("  </div>\n") // Close comment-box-container
 		}
 
@@ -271,10 +322,240 @@ func generateHTML(template Template, entries []TimelineEntry) (string, error) {
 	}
 
 	htmlBuilder.WriteString("</div>\n") // Close timeline-container
+	if sectionStarts != nil {
+		htmlBuilder.WriteString("</details>\n")
+	}
+	if template.Layout.Caption != "" {
+		htmlBuilder.WriteString(htmlCaption(colorMap, template))
+	}
+	if template.Layout.Interactive {
+		htmlBuilder.WriteString(htmlSearchScript)
+	}
 	htmlBuilder.WriteString("</body>\n</html>")
 
-	log.Println("Warning: HTML output is simplified. Connectors, dots, and precise layout/overlap avoidance are not fully implemented.")
-	return htmlBuilder.String(), nil
+	diag.warnf("html_simplified", "HTML output is simplified. Connectors are drawn straight (no doglegs) and precise layout/overlap avoidance is not implemented.")
+	return htmlBuilder.String(), diag.warnings, nil
+}
+
+// defaultSectionName labels the accordion group of entries without a section.
+const defaultSectionName = "Other"
+
+// htmlSection is one accordion group of entries (layout.accordion_sections).
+type htmlSection struct {
+	Name    string
+	Entries []TimelineEntry
+}
+
+// groupEntriesBySection orders entries by section, in the order sections first appear and
+// keeping the entry order within each, and returns them with the section starting at each
+// index. Entries without a section form the defaultSectionName group.
+func groupEntriesBySection(entries []TimelineEntry) ([]TimelineEntry, map[int]htmlSection) {
+	var sections []htmlSection
+	indexByName := map[string]int{}
+	for _, entry := range entries {
+		name := strings.TrimSpace(entry.Section)
+		if name == "" {
+			name = defaultSectionName
+		}
+		index, ok := indexByName[name]
+		if !ok {
+			index = len(sections)
+			indexByName[name] = index
+			sections = append(sections, htmlSection{Name: name})
+		}
+		sections[index].Entries = append(sections[index].Entries, entry)
+	}
+	grouped := make([]TimelineEntry, 0, len(entries))
+	starts := make(map[int]htmlSection, len(sections))
+	for _, section := range sections {
+		starts[len(grouped)] = section
+		grouped = append(grouped, section.Entries...)
+	}
+	return grouped, starts
+}
+
+// htmlAxisLength estimates the main axis length of an HTML timeline: the padding plus every
+// entry's spacing.
+func htmlAxisLength(template Template, entries []TimelineEntry) float64 {
+	length := template.Layout.Padding
+	for _, entry := range entries {
+		spacing := template.Layout.EntrySpacing
+		if entry.EntrySpacingOverride != nil {
+			spacing = *entry.EntrySpacingOverride
+		}
+		if spacing <= 0 {
+			spacing = template.Layout.EntrySpacing
+		} // Fallback
+		length += spacing
+	}
+	return length
+}
+
+// htmlCommentBoxStyle builds the inline style for a comment box from the effective comment style,
+// mirroring the SVG renderer: text falls back to the connector color, and the configured fill,
+// border and padding are applied for boxed shapes. Anything not configured is left to the
+// hardcoded .comment-box class rules, which act as fallbacks only.
+func htmlCommentBoxStyle(diag *diagnosticLog, colorMap map[string]string, commentStyle CommentTextStyle, fallbackTextColor string) string {
+	commentFont := commentStyle.Font
+	commentTextColor := commentStyle.TextColor
+	if commentTextColor == "" {
+		commentTextColor = fallbackTextColor // Same fallback as drawComment
+	}
+	if commentTextColor == "" {
+		commentTextColor = "inherit"
+	}
+
+	style := fmt.Sprintf("color:%s; font-family:%s; font-size:%dpx; font-weight:%s; font-style:%s;",
+		escapeCSS(mapColor(colorMap, commentTextColor)), cssFontFamily(commentFont.FontFamily), commentFont.FontSize, escapeCSS(commentFont.FontWeight), escapeCSS(commentFont.FontStyle))
+
+	if commentStyle.LineHeight != nil && *commentStyle.LineHeight > 0 {
+		style += fmt.Sprintf(" line-height:%.2f;", *commentStyle.LineHeight)
+	}
+
+	if commentStyle.Shape == "none" { // No box at all, like the SVG output
+		return style + " background-color: transparent; border: none; padding: 0;"
+	}
+
+	if commentStyle.FillColor != "" && !strings.HasPrefix(commentStyle.FillColor, patternFillPrefix) && !isURLReference(commentStyle.FillColor) { // Patterns and defs are SVG-only
+		background := mapColor(colorMap, commentStyle.FillColor)
+		if opacity, ok := fillOpacity(diag, commentStyle.FillOpacity); ok {
+			background = cssColorWithOpacity(background, opacity) // Only the background fades, not the text
+		}
+		style += fmt.Sprintf(" background-color:%s;", escapeCSS(background))
+	}
+	if commentStyle.BorderColor != "" {
+		borderStyle := commentStyle.BorderStyle
+		if borderStyle == "" {
+			borderStyle = "solid"
+		}
+		if commentStyle.BorderWidth > 0 {
+			style += fmt.Sprintf(" border: %dpx %s %s;", commentStyle.BorderWidth, escapeCSS(borderStyle), escapeCSS(mapColor(colorMap, commentStyle.BorderColor)))
+		} else {
+			style += " border: none;" // Zero width explicitly disables the border
+		}
+	}
+	if commentStyle.Padding != "" {
+		padTop, padRight, padBottom, padLeft := parsePadding(diag, commentStyle.Padding)
+		style += fmt.Sprintf(" padding: %.0fpx %.0fpx %.0fpx %.0fpx;", padTop, padRight, padBottom, padLeft)
+	}
+	return style
+}
+
+// htmlAccordionCSS styles the collapsible sections of layout.accordion_sections.
+const htmlAccordionCSS = `        .timeline-section { margin: 0 auto 20px; }
+        .timeline-section > summary { cursor: pointer; font-weight: bold; font-size: 1.1em; padding: 4px 0; }
+`
+
+// Markup for layout.interactive: a search box that highlights the entries (year and comment
+// elements sharing a data-entry id) whose text matches and dims the rest. No external deps.
+const htmlInteractiveCSS = `        .timeline-search { text-align: center; margin: 0 auto 10px; }
+        .timeline-search input { font: inherit; padding: 4px 8px; min-width: 240px; }
+        [data-entry] { transition: opacity 0.2s; }
+        .timeline-dimmed { opacity: 0.2; }
+        .timeline-match .year-text, .timeline-match .comment-box { outline: 2px solid #FFC107; outline-offset: 2px; }
+`
+
+const htmlSearchBox = `<div class="timeline-search"><input type="search" id="timeline-search-input" placeholder="Search entries..." aria-label="Search timeline entries"></div>
+`
+
+const htmlSearchScript = `<script>
+(function () {
+    var input = document.getElementById("timeline-search-input");
+    var elements = document.querySelectorAll("[data-entry]");
+    var texts = {}; // Entry id -> lowercase text of all its elements
+    elements.forEach(function (el) {
+        var id = el.getAttribute("data-entry");
+        texts[id] = (texts[id] || "") + " " + el.textContent.toLowerCase();
+    });
+    input.addEventListener("input", function () {
+        var query = input.value.trim().toLowerCase();
+        elements.forEach(function (el) {
+            var match = query !== "" && texts[el.getAttribute("data-entry")].indexOf(query) !== -1;
+            el.classList.toggle("timeline-match", match);
+            el.classList.toggle("timeline-dimmed", query !== "" && !match);
+        });
+    });
+})();
+</script>
+`
+
+// htmlCaption renders the layout caption as a centered footer below the timeline container.
+func htmlCaption(colorMap map[string]string, template Template) string {
+	font := getEffectiveCaptionFont(template)
+	style := fmt.Sprintf("text-align:center; color:%s; font-family:%s; font-size:%dpx; font-weight:%s; font-style:%s;",
+		escapeCSS(mapColor(colorMap, defaultCaptionColor)), cssFontFamily(font.FontFamily), font.FontSize, escapeCSS(font.FontWeight), escapeCSS(font.FontStyle))
+	content := markdownLinkRegex.ReplaceAllString(escapeHTML(template.Layout.Caption), `<a href="$2" target="_blank">$1</a>`)
+	content = strings.ReplaceAll(content, "\n", "<br />")
+	return fmt.Sprintf("<div class=\"timeline-caption\" style=\"%s\">%s</div>\n", style, content)
+}
+
+// htmlConnector draws a straight connector from the axis point to an element's anchor as a
+// rotated div, followed by the connector dot (if visible), mirroring the SVG drawConnector.
+// With centerRelative, x coordinates are offsets from the container's horizontal center.
+func htmlConnector(colorMap map[string]string, axisX, axisY, targetX, targetY float64, centerRelative bool, connStyle ConnectorStyle, fallbackColor string) string {
+	length := math.Hypot(targetX-axisX, targetY-axisY)
+	if length < 0.001 {
+		return ""
+	}
+	ux, uy := (targetX-axisX)/length, (targetY-axisY)/length // Axis -> element
+	nx, ny := -uy, ux
+
+	color := mapColor(colorMap, connStyle.Color)
+	if color == "" {
+		color = fallbackColor // Already mapped by the caller
+	}
+	width := float64(connStyle.Width)
+	if width <= 0 {
+		width = 1
+	}
+	lineStyle := "solid"
+	if connStyle.LineType == "dotted" || connStyle.LineType == "dashed" {
+		lineStyle = connStyle.LineType
+	}
+	left := func(x float64) string {
+		if centerRelative {
+			return fmt.Sprintf("calc(50%% + %.0fpx)", x)
+		}
+		return fmt.Sprintf("%.0fpx", x)
+	}
+
+	dot := connStyle.Dot
+	dotVisible := dot.Visible && dot.Shape != "none" && dot.Size > 0
+	dotX := axisX + ux*float64(dot.OffsetMain) + nx*float64(dot.OffsetCross)
+	dotY := axisY + uy*float64(dot.OffsetMain) + ny*float64(dot.OffsetCross)
+
+	// The line runs element -> dot when it stops at a visible dot, element -> axis otherwise
+	startX, startY := axisX, axisY
+	if dotVisible && dot.StopAtDot {
+		startX, startY = dotX, dotY
+	}
+	lineLength := math.Hypot(targetX-startX, targetY-startY)
+	angle := math.Atan2(targetY-startY, targetX-startX) * 180.0 / math.Pi
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("  <div class=\"timeline-connector\" style=\"left: %s; top: %.1fpx; width: %.1fpx; border-top: %.0fpx %s %s; margin-top: -%.1fpx; transform: rotate(%.2fdeg);\"></div>\n",
+		left(startX), startY, lineLength, width, lineStyle, escapeCSS(color), width/2.0, angle))
+
+	if dotVisible {
+		dotColor := dot.Color
+		if dotColor == "" {
+			dotColor = color // Default to connector color
+		}
+		dotColor = mapColor(colorMap, dotColor)
+		size := float64(dot.Size)
+		shapeStyle := ""
+		switch dot.Shape {
+		case "circle":
+			shapeStyle = " border-radius: 50%;"
+		case "arrow":
+			// Triangle pointing back towards the axis, like the SVG arrow
+			arrowAngle := math.Atan2(-uy, -ux) * 180.0 / math.Pi
+			shapeStyle = fmt.Sprintf(" clip-path: polygon(0 0, 100%% 50%%, 0 100%%); transform: rotate(%.2fdeg);", arrowAngle)
+		}
+		sb.WriteString(fmt.Sprintf("  <div class=\"timeline-connector-dot\" style=\"left: %s; top: %.1fpx; width: %.0fpx; height: %.0fpx; margin: -%.1fpx 0 0 -%.1fpx; background-color: %s;%s\"></div>\n",
+			left(dotX), dotY, size, size, size/2.0, size/2.0, escapeCSS(dotColor), shapeStyle))
+	}
+	return sb.String()
 }
 
 // Simple CSS Escaping (basic)
@@ -284,6 +565,41 @@ func escapeCSS(s string) string {
 	return s
 }
 
+// htmlPageTitle returns the escaped layout.title, or "Timeline" when it is unset.
+func htmlPageTitle(template Template) string {
+	if strings.TrimSpace(template.Layout.Title) == "" {
+		return "Timeline"
+	}
+	return html.EscapeString(template.Layout.Title)
+}
+
+// svgPageHTML embeds an already generated SVG inline in a minimal, centered HTML page.
+// Unlike generateHTML the timeline renders exactly as the SVG output does.
+func svgPageHTML(template Template, svgContent string) string {
+	var page strings.Builder
+	page.WriteString("<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n")
+	page.WriteString("<meta name=\"viewport\" content=\"width=device-width, initial-scale=1\">\n")
+	page.WriteString("<title>" + htmlPageTitle(template) + "</title>\n")
+	page.WriteString("<style>\n")
+	page.WriteString("body { margin: 0; padding: 24px; display: flex; flex-direction: column; align-items: center; font-family: sans-serif; }\n")
+	page.WriteString("h1 { font-size: 20px; font-weight: normal; margin: 0 0 16px; }\n")
+	page.WriteString(".timeline-svg svg { display: block; max-width: 100%; height: auto; }\n")
+	page.WriteString("</style>\n</head>\n<body>\n")
+	if strings.TrimSpace(template.Layout.Title) != "" {
+		page.WriteString("<h1>" + htmlPageTitle(template) + "</h1>\n")
+	}
+	page.WriteString("<div class=\"timeline-svg\">\n")
+	svgContent = strings.TrimSpace(svgContent)
+	if strings.HasPrefix(svgContent, "<?xml") { // The XML declaration is not allowed inline
+		if end := strings.Index(svgContent, "?>"); end >= 0 {
+			svgContent = strings.TrimSpace(svgContent[end+2:])
+		}
+	}
+	page.WriteString(svgContent)
+	page.WriteString("\n</div>\n</body>\n</html>\n")
+	return page.String()
+}
+
 // Simple ternary helper for inline conditions
 func ternary(condition bool, trueVal, falseVal string) string {
 	if condition {
@@ -291,3 +607,18 @@ func ternary(condition bool, trueVal, falseVal string) string {
 	}
 	return falseVal
 }
+
+// cssColorWithOpacity returns color with the given alpha: rgba() for hex colors, color-mix()
+// with transparent for any other CSS color.
+func cssColorWithOpacity(color string, opacity float64) string {
+	alpha := strconv.FormatFloat(opacity, 'f', -1, 64)
+	if hex, ok := strings.CutPrefix(strings.TrimSpace(color), "#"); ok {
+		if len(hex) == 3 { // #RGB shorthand
+			hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+		}
+		if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil && len(hex) == 6 {
+			return fmt.Sprintf("rgba(%d, %d, %d, %s)", rgb>>16, rgb>>8&0xFF, rgb&0xFF, alpha)
+		}
+	}
+	return fmt.Sprintf("color-mix(in srgb, %s %s%%, transparent)", color, strconv.FormatFloat(opacity*100, 'f', -1, 64))
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestHTMLCommentBoxHonorsTemplateColors verifies the HTML comment box uses the configured colors.
+func TestHTMLCommentBoxHonorsTemplateColors(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.CommentText.FillColor = "#123456"
+	template.PeriodDefaults.CommentText.BorderColor = "#abcdef"
+	template.PeriodDefaults.CommentText.BorderWidth = 2
+	template.PeriodDefaults.CommentText.TextColor = "#654321"
+
+	html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", CommentText: "body"}})
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	for _, want := range []string{"color:#654321;", "background-color:#123456;", "border: 2px solid #abcdef;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+}
+
+// TestHTMLCommentFillOpacityFadesBackgroundOnly checks fill_opacity turns the comment
+// background into a translucent color instead of fading the whole box.
+func TestHTMLCommentFillOpacityFadesBackgroundOnly(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.CommentText.FillColor = "#123456"
+	opacity := 0.4
+	template.PeriodDefaults.CommentText.FillOpacity = &opacity
+
+	html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", CommentText: "body"}})
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if !strings.Contains(html, "background-color:rgba(18, 52, 86, 0.4);") {
+		t.Errorf("Expected an rgba background with alpha 0.4")
+	}
+	if got := cssColorWithOpacity("teal", 0.4); got != "color-mix(in srgb, teal 40%, transparent)" {
+		t.Errorf("Expected named colors to be mixed with transparent, got %s", got)
+	}
+}
+
+// TestHTMLMainAxisOffsetShiftsElements checks main_axis_offset moves the HTML comment and
+// year containers along the axis, as it does in the SVG.
+func TestHTMLMainAxisOffsetShiftsElements(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "body"}}
+	leftOf := func(html, class string) float64 {
+		t.Helper()
+		m := regexp.MustCompile(`class="timeline-element ` + class + `"[^>]*style="left: (-?[0-9.]+)px`).FindStringSubmatch(html)
+		if m == nil {
+			t.Fatalf("No positioned %s in HTML", class)
+		}
+		left, _ := strconv.ParseFloat(m[1], 64)
+		return left
+	}
+
+	html, _, err := generateHTML(template, entries)
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	commentLeft, yearLeft := leftOf(html, "comment-box-container"), leftOf(html, "year-text-container")
+
+	template.PeriodDefaults.CommentText.MainAxisOffset = 30
+	template.PeriodDefaults.YearText.MainAxisOffset = -20
+	html, _, err = generateHTML(template, entries)
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if got := leftOf(html, "comment-box-container") - commentLeft; got != 30 {
+		t.Errorf("Expected the comment box to move 30px along the axis, moved %g", got)
+	}
+	if got := leftOf(html, "year-text-container") - yearLeft; got != -20 {
+		t.Errorf("Expected the year to move -20px along the axis, moved %g", got)
+	}
+}
+
+func TestHTMLDrawsConnectorsAndDots(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.Connector.Dot = DotStyle{Size: 6, Color: "#ff0000", Shape: "circle", Visible: true}
+	drawToComment := true
+	template.PeriodDefaults.Connector.DrawToComment = &drawToComment
+
+	html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", CommentText: "body"}})
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if got := strings.Count(html, `<div class="timeline-connector" `); got != 2 {
+		t.Errorf("Expected 2 connector divs (year and comment), got %d", got)
+	}
+	for _, want := range []string{"transform: rotate(", `class="timeline-connector-dot"`, "background-color: #ff0000; border-radius: 50%;"} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+}
+
+func TestHTMLCaptionRendersLinks(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.Caption = "Data: [Archive](https://example.org)"
+
+	html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001"}})
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	want := `Data: <a href="https://example.org" target="_blank">Archive</a></div>`
+	if !strings.Contains(html, want) {
+		t.Errorf("Expected HTML caption %q", want)
+	}
+}
+
+func TestHTMLInteractiveAddsSearch(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "body"}}
+
+	html, _, err := generateHTML(template, entries)
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if strings.Contains(html, "<script>") || strings.Contains(html, "timeline-search") {
+		t.Errorf("Expected no search markup unless layout.interactive is set")
+	}
+
+	template.Layout.Interactive = true
+	html, _, err = generateHTML(template, entries)
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	for _, want := range []string{
+		`<div class="timeline-search"><input type="search" id="timeline-search-input"`,
+		`<div id="entry-2001" class="timeline-element year-text-container" data-entry="entry-2001"`,
+		`<div class="timeline-element comment-box-container" data-entry="entry-2001"`,
+		`document.querySelectorAll("[data-entry]")`,
+	} {
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected HTML to contain %q", want)
+		}
+	}
+	if strings.Index(html, "<script>") < strings.Index(html, `class="timeline-container"`) {
+		t.Errorf("Expected the script after the timeline markup")
+	}
+}
+
+// TestSVGPageHTMLEmbedsSVGInline checks the svg-html page wraps the exact SVG with the layout title.
+func TestSVGPageHTMLEmbedsSVGInline(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.Title = "History <of> things"
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+
+	page := svgPageHTML(template, `<?xml version="1.0" encoding="UTF-8"?>`+"\n"+svg)
+	if !strings.Contains(page, "<title>History &lt;of&gt; things</title>") {
+		t.Errorf("Expected the escaped layout title as page title")
+	}
+	if !strings.Contains(page, strings.TrimSpace(svg)) {
+		t.Errorf("Expected the SVG embedded unchanged")
+	}
+	if strings.Contains(page, "<?xml") {
+		t.Errorf("Expected the XML declaration stripped from the inline SVG")
+	}
+
+	template.Layout.Title = ""
+	if page := svgPageHTML(template, svg); !strings.Contains(page, "<title>Timeline</title>") || strings.Contains(page, "<h1>") {
+		t.Errorf("Expected the default title and no heading without layout.title")
+	}
+}
+
+// TestHTMLAccordionSectionsGroupEntries checks accordion_sections wraps each section, in the
+// order sections first appear, in an open <details> with its own timeline container.
+func TestHTMLAccordionSectionsGroupEntries(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.AccordionSections = true
+	entries := []TimelineEntry{
+		{Period: "2001", Section: "Early years"},
+		{Period: "2002"},
+		{Period: "2003", Section: "Early years"},
+		{Period: "2010", Section: "Growth"},
+	}
+	html, _, err := generateHTML(template, entries)
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if n := strings.Count(html, `<details class="timeline-section" open>`); n != 3 || strings.Count(html, "</details>") != 3 {
+		t.Fatalf("Expected 3 open <details> sections, got %d", n)
+	}
+	early := strings.Index(html, "<summary>Early years</summary>")
+	other := strings.Index(html, "<summary>"+defaultSectionName+"</summary>")
+	growth := strings.Index(html, "<summary>Growth</summary>")
+	if early < 0 || other < early || growth < other {
+		t.Fatalf("Expected sections Early years, %s, Growth in first-appearance order", defaultSectionName)
+	}
+	earlyPart := html[early:other]
+	if !strings.Contains(earlyPart, `id="entry-2001"`) || !strings.Contains(earlyPart, `id="entry-2003"`) || strings.Count(earlyPart, `class="timeline-container"`) != 1 {
+		t.Errorf("Expected 2001 and 2003 together in one container in the first section")
+	}
+	if !strings.Contains(html[other:growth], `id="entry-2002"`) {
+		t.Errorf("Expected the entry without a section in the default group")
+	}
+
+	template.Layout.AccordionSections = false
+	if plain, _, _ := generateHTML(template, entries); strings.Contains(plain, "<details") {
+		t.Errorf("Expected no sections unless accordion_sections is set")
+	}
+}
+
+// TestHTMLCommentLinkIsEscaped checks a comment_link with a query string and quotes stays
+// one well-formed attribute value.
+func TestHTMLCommentLinkIsEscaped(t *testing.T) {
+	template := loadTestTemplate(t)
+	html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentLink: `https://e.com/?a=1&b="2"`}})
+	if err != nil {
+		t.Fatalf("generateHTML failed: %v", err)
+	}
+	if want := `href="https://e.com/?a=1&amp;b=&#34;2&#34;"`; !strings.Contains(html, want) {
+		t.Errorf("Expected the escaped link %s in the HTML", want)
+	}
+}
+
+// TestHTMLImageAltIsEscaped checks quotes, < and & in image_alt can't break the <img> element.
+func TestHTMLImageAltIsEscaped(t *testing.T) {
+	template := loadTestTemplate(t)
+	for alt, want := range map[string]string{`say "hi"`: `alt="say &#34;hi&#34;"`, "a < b & c": `alt="a &lt; b &amp; c"`} {
+		html, _, err := generateHTML(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentImage: "https://example.com/a.png", ImageAlt: alt}})
+		if err != nil {
+			t.Fatalf("generateHTML failed: %v", err)
+		}
+		if !strings.Contains(html, want) {
+			t.Errorf("Expected %s in the HTML for alt %q", want, alt)
+		}
+	}
+}
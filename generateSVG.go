@@ -1,7 +1,6 @@
 package main
 
 import (
-	"bytes"
 	"encoding/base64"
 	"fmt"
 	"log"
@@ -10,6 +9,8 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -18,6 +19,36 @@ const defaultFontSize = 12.0
 const defaultFont = "Arial, sans-serif"
 const imagePlaceholderHeight = 50.0       // Default height for images if not specified/calculable
 const foreignObjectHeightEstimate = 100.0 // Default height for foreignObject (adjust as needed) - VERY ROUGH
+const defaultImageAlt = "Timeline image"  // Alt text used when an entry doesn't provide image_alt
+const periodBarWidthFactor = 2.5          // Date range bars are this many times thicker than the center line
+const sideImageTextGrowth = 1.2           // Body text beside a left/right image wraps into this many times more lines
+const defaultImportanceScale = 0.25       // Marker growth per importance point when layout.importance_scale is unset
+const defaultCommentTailSize = 10.0       // Comment tail length when tail_size is unset
+const defaultThumbnailSize = 32.0         // Entry thumbnail diameter when thumbnail_size is unset
+const subtitleFontScale = 0.7             // Entry subtitle font size relative to the year font when subtitle_font sets none
+const subtitleGap = 2.0                   // Space between the period text and the entry subtitle
+const yearShapeAutoPadding = 4.0          // Space between the year text and an auto-sized (or subtitle-grown) shape
+const commentFitGap = 10.0                // Space kept between neighboring comment blocks with layout.fit_comments
+const commentFitMinWidth = 60.0           // layout.fit_comments never narrows a comment block below this
+const defaultFocusColor = "#FF9800"       // Focus ring color when layout.focus_color is unset
+const defaultNumberBadgeSize = 18.0       // Number badge height when number_style.size is unset
+const defaultNumberFontSize = 10          // Number badge font size when number_style.font sets none
+const focusRingPadding = 6.0              // Gap between an entry's elements and its focus ring
+const captionGap = 15.0                   // Space between the timeline content and the caption
+const defaultCaptionFontSize = 11         // Caption font size when neither caption_font nor global_font sets one
+const defaultCaptionColor = "#555555"     // Caption text color
+const defaultArrowRatio = 1.2             // Arrow dot tip length per half dot size when arrow_ratio is unset
+const minArrowRatio = 0.1                 // Smallest arrow_ratio; lower values would draw a flat arrow
+const minAutoFitFontSize = 6              // year_text.auto_fit_text never shrinks the period font below this
+const connectorAnimationDuration = 0.8    // Seconds a connector takes to draw itself in (layout.animate_connectors)
+const connectorAnimationStagger = 0.15    // Seconds between the animation starts of consecutive entries
+const defaultLayoutPadding = 50.0         // Canvas padding when layout.padding is unset
+const defaultLayoutEntrySpacing = 150.0   // Distance between entries when layout.entry_spacing is unset
+const defaultLayoutConnectorLength = 50.0 // Connector length when layout.connector_length is unset
+const defaultCenterLineColor = "#000000"  // Center line color when center_line.color is unset
+
+// Basic markdown link support: [text](url)
+var markdownLinkRegex = regexp.MustCompile(`\[([^\]]+)\]\(([^\)]+)\)`) // Escaped brackets
 
 // Structure to hold calculated bounds
 type bounds struct {
@@ -39,6 +70,14 @@ func (b *bounds) updatePoint(x, y float64) {
 	}
 }
 
+// Grow bounds to include other (ignored when other is empty)
+func (b *bounds) merge(other bounds) {
+	if other.isSet {
+		b.updatePoint(other.minX, other.minY)
+		b.updatePoint(other.maxX, other.maxY)
+	}
+}
+
 // Update bounds considering a rectangle
 func (b *bounds) updateRect(x, y, width, height float64) {
 	if width > 0 && height > 0 {
@@ -47,6 +86,20 @@ func (b *bounds) updateRect(x, y, width, height float64) {
 	}
 }
 
+// rotated returns the bounds of b after rotating it by angleDeg degrees around (cx, cy)
+func (b bounds) rotated(cx, cy, angleDeg float64) bounds {
+	if !b.isSet {
+		return b
+	}
+	sin, cos := math.Sincos(angleDeg * math.Pi / 180.0)
+	var out bounds
+	for _, corner := range [4][2]float64{{b.minX, b.minY}, {b.maxX, b.minY}, {b.maxX, b.maxY}, {b.minX, b.maxY}} {
+		dx, dy := corner[0]-cx, corner[1]-cy
+		out.updatePoint(cx+dx*cos-dy*sin, cy+dx*sin+dy*cos)
+	}
+	return out
+}
+
 // Parameter structs for functions with too many parameters
 type ElementCenterParams struct {
 	AxisX        float64
@@ -56,6 +109,8 @@ type ElementCenterParams struct {
 	ConnectorLen float64
 	CrossDir     float64
 	IsHorizontal bool
+	AxisAngle    *float64 // Effective axis angle in degrees at the entry (nil = not angled)
+	Inline       bool     // Center the element on the axis, ignoring the cross-axis distance
 }
 
 type JunctionMarkerParams struct {
@@ -64,7 +119,42 @@ type JunctionMarkerParams struct {
 	CenterY         float64
 	MarkerColor     string
 	IsHorizontal    bool
+	AxisAngle       *float64 // Effective axis angle in degrees at the marker (nil = not angled)
 	CenterLineWidth float64
+	StrokeScale     float64 // Multiplier for the outline width (Layout.StrokeScale)
+}
+
+type DividerParams struct {
+	Style        DividerStyle
+	CenterX      float64
+	CenterY      float64
+	StartReach   float64 // Distance covered on the start (top/left) side of the axis
+	EndReach     float64 // Distance covered on the end (bottom/right) side of the axis
+	Color        string  // Used when Style.Color is empty
+	IsHorizontal bool
+	AxisAngle    *float64 // Effective axis angle in degrees at the junction (nil = not angled)
+	StrokeScale  float64
+}
+
+type ThumbnailParams struct {
+	Image       string // Path, URL or data URI
+	Size        float64
+	BorderColor string
+	BorderWidth float64
+	CenterX     float64
+	CenterY     float64
+	ClipID      string // id of the circular clipPath
+	StrokeScale float64
+}
+
+type NumberBadgeParams struct {
+	Number         int // 1-based entry index
+	Style          NumberBadgeStyle
+	FillColor      string
+	CenterX        float64
+	CenterY        float64
+	StrokeScale    float64
+	BaselineCompat bool
 }
 
 type CommentParams struct {
@@ -78,6 +168,15 @@ type CommentParams struct {
 	TitleText    string
 	BodyText     string
 	ImageURL     string
+	ImageAlt     string   // Alt text for the image (defaults to defaultImageAlt)
+	Link         string   // Optional URL wrapping the whole comment block
+	EntryID      string   // Unique id of the owning entry, used to derive ids of per-comment defs
+	AxisAngle    *float64 // Effective axis angle in degrees at the entry (nil = not angled)
+	StrokeScale  float64  // Stroke width multiplier for the border and title line (Layout.StrokeScale)
+	Inline       bool     // Center the block on the anchor instead of placing it beside it
+	BodyHeight   float64  // Measured body height replacing the estimate (0 = estimate)
+	FitWidth     float64  // Block width ceiling from layout.fit_comments, wrapping the body (0 = none)
+	TextBody     bool     // Draw the body as wrapped SVG text, not a foreignObject (layout.no_foreign_object)
 }
 
 // Add a new parameter struct for drawConnector
@@ -92,6 +191,46 @@ type ConnectorParams struct {
 	CrossAxisDir       float64
 	LineIsVisible      bool
 	ElementCrossOffset float64 // Offset of the connected element (year/comment)
+	StrokeScale        float64 // Stroke width multiplier (Layout.StrokeScale)
+	Animate            bool    // Draw the line in with a stroke-dashoffset animation (Layout.AnimateConnectors)
+	EntryIndex         int     // Staggers the animation start
+	GradientID         string  // id of the connector.gradient_to_element gradient
+	LineJoin           string  // stroke-linejoin of orthogonal connectors (Layout.LineJoin)
+	ElementColor       string  // Color the gradient fades to at the element end ("" = the line color)
+}
+
+// fitYearFontSize returns the largest font size, from the year font's own down to
+// minAutoFitFontSize, at which every line of the period fits the inner width of a fixed-size
+// year shape (year_text.auto_fit_text). Auto-sized and missing shapes keep the font size.
+func fitYearFontSize(lines []string, yearStyle YearTextStyle) int {
+	shapeType, shapeParams, err := parseShapeString(yearStyle.Shape)
+	if err != nil {
+		return yearStyle.Font.FontSize // drawYearElement reports the bad shape
+	}
+	innerWidth := 0.0
+	switch shapeType {
+	case "rectangle":
+		innerWidth = shapeParams["w"] - 2*yearShapeAutoPadding
+	case "circle":
+		innerWidth = 2*shapeParams["r"] - 2*yearShapeAutoPadding // r=auto (-1) grows with the text instead
+	}
+	if innerWidth <= 0 {
+		return yearStyle.Font.FontSize
+	}
+	font := yearStyle.Font
+	for ; font.FontSize > minAutoFitFontSize; font.FontSize-- {
+		fits := true
+		for _, line := range lines {
+			if estimateTextSVGWidth(line, font) > innerWidth {
+				fits = false
+				break
+			}
+		}
+		if fits {
+			break
+		}
+	}
+	return font.FontSize
 }
 
 // Add a new parameter struct for drawYearShape
@@ -102,7 +241,9 @@ type YearShapeParams struct {
 	CenterY     float64
 	TextWidth   float64
 	TextHeight  float64
+	FitText     bool // Grow a fixed-size shape to hold the text (a period with a subtitle)
 	YearStyle   YearTextStyle
+	Area        *bounds // Grown by the shape's extent, for the year's link area
 }
 
 // Add a parameter struct for drawConnectorDot
@@ -118,7 +259,7 @@ type ConnectorDotParams struct {
 
 // Add a parameter struct for drawCenterLineSegment
 type DrawCenterLineSegmentParams struct {
-	SVG         *bytes.Buffer
+	SVG         *svgBuffer
 	Bounds      *bounds
 	X1, Y1      float64
 	X2, Y2      float64
@@ -126,11 +267,48 @@ type DrawCenterLineSegmentParams struct {
 	Width       float64
 	LineType    string
 	RoundedCaps bool
+	DashOffset  float64 // Axis distance before the segment, so dashes continue across junctions
+}
+
+// AxisPoint is a point on the center line
+type AxisPoint struct {
+	X, Y float64
+}
+
+// Add a parameter struct for drawSmoothCenterLine
+type SmoothCenterLineParams struct {
+	SVG         *svgBuffer
+	Bounds      *bounds
+	Points      []AxisPoint // Axis start followed by each entry's axis point
+	Colors      []string    // Color of the segment ending at Points[i+1]
+	Widths      []float64   // Width of the segment ending at Points[i+1]
+	LineType    string
+	RoundedCaps bool
+	LineJoin    string // stroke-linejoin of the path (Layout.LineJoin)
+}
+
+type GradientCenterLineParams struct {
+	SVG         *svgBuffer
+	Bounds      *bounds
+	Points      []AxisPoint // Axis start followed by each entry's axis point
+	Width       float64
+	LineType    string
+	RoundedCaps bool
+	LineJoin    string // stroke-linejoin of the path (Layout.LineJoin)
+}
+
+// Add a parameter struct for drawPeriodBar
+type PeriodBarParams struct {
+	SVG    *svgBuffer
+	Bounds *bounds
+	Points []AxisPoint // Axis path from the range start to its end
+	Color  string
+	Width  float64
 }
 
 // Add a parameter struct for drawAndAdvanceAxisSegment
 type DrawAndAdvanceAxisSegmentParams struct {
-	SVG                *bytes.Buffer
+	SVG                *svgBuffer
 	Bounds             *bounds
 	CurrentX, CurrentY float64
 	StartIndex         int // -1 for initial segment, 0 to len(entries)-2 for others
@@ -179,11 +357,68 @@ func calculateAxisGeometry(x1, y1, length float64, orientation string, globalAng
 // LayoutConfig holds the configuration for timeline layout
 type LayoutConfig struct {
 	layoutPadding          float64
+	paddingTop             float64 // Per-side canvas padding (default: layoutPadding)
+	paddingRight           float64
+	paddingBottom          float64
+	paddingLeft            float64
 	defaultEntrySpacing    float64
 	defaultConnectorLength float64
 	centerLineBaseColor    string
 	centerLineWidth        float64
 	centerLineIsRounded    bool
+	minWidth, minHeight    float64  // Canvas size floor (0 = unset)
+	maxWidth, maxHeight    float64  // Canvas size ceiling (0 = unset)
+	axisLength             float64  // Fixed total axis length (0 = derive from spacing)
+	globalAxisAngle        *float64 // Global center line angle in degrees (nil = orientation only)
+	warnOverlap            bool     // Report overlapping comment boxes after layout
+	transparent            bool     // Skip the background rect
+	strokeScale            float64  // Multiplier applied to stroke widths at draw time
+	precision              int      // Decimals written by coord
+	jitter                 float64  // Max hand-drawn offset of line ends and boxes (0 = off)
+	seed                   int64    // Jitter seed
+	lineJoin               string   // stroke-linejoin of multi-segment lines ("" = SVG default miter)
+	elementOrder           []string // Draw order of an entry's marker, year and comment parts
+	animateConnectors      bool     // Connectors draw themselves in (CSS stroke-dashoffset animation)
+	bucket                 string   // Period bucket entries are grouped by ("" = none)
+	bucketMerge            bool     // Merge the entries of a bucket instead of stacking them
+	bucketRanks            []int    // Position of each entry within its bucket stack (nil = not bucketed)
+	caption                string   // Footer caption (empty = none)
+	captionFont            FontStyle
+	colorMap               map[string]string  // Color remapping, keys lowercased
+	importanceScale        float64            // Size growth per entry importance point
+	importanceScalesYear   bool               // Scale year fonts by importance too
+	customDefs             string             // Raw markup injected into <defs>
+	baselineCompat         bool               // Center year text by shifting y instead of dominant-baseline
+	spacingScale           string             // "" (even), "linear" or "log" date-based spacing
+	focusColor             string             // Focus ring color
+	numberEntries          bool               // Draw numbered badges on the junction markers
+	numberStyle            NumberBadgeStyle   // Resolved badge style
+	minimalMode            bool               // Skip comment blocks and their connectors
+	fitComments            bool               // Narrow comment blocks to the space beside their entry
+	noForeignObject        bool               // Comment bodies as SVG text and images, without foreignObject
+	bodyHeights            map[string]float64 // Measured comment body heights by entry id (precise_image_layout)
+	centerLineGradientDef  string             // <linearGradient> for center_line.gradient, written into <defs>
+	shapeRendering         string             // Root shape-rendering hint ("" = omit)
+	textRendering          string             // Root text-rendering hint ("" = omit)
+	entryOffset            int                // Index of the first entry in the full timeline (-entry)
+	debugBoxes             bool               // Overlay the element bounding boxes (-debug-boxes)
+	diagnostics            *diagnosticLog     // Warnings of the render
+}
+
+// svgRenderingHints lists the values SVG allows for each rendering hint attribute.
+var svgRenderingHints = map[string][]string{
+	"shape-rendering": {"auto", "optimizeSpeed", "crispEdges", "geometricPrecision"},
+	"text-rendering":  {"auto", "optimizeSpeed", "optimizeLegibility", "geometricPrecision"},
+}
+
+// validRenderingHint returns value when SVG allows it for the hint attribute, warning and
+// returning "" (attribute omitted) otherwise.
+func validRenderingHint(diag *diagnosticLog, attribute, value string) string {
+	if value == "" || slices.Contains(svgRenderingHints[attribute], value) {
+		return value
+	}
+	diag.warnf("invalid_option", "unknown %s '%s' (use %s), omitting it.", attribute, value, strings.Join(svgRenderingHints[attribute], ", "))
+	return ""
 }
 
 // TimelinePositionData holds pre-calculated data for timeline entries
@@ -191,10 +426,12 @@ type TimelinePositionData struct {
 	entryPoints     []float64
 	junctionPoints  []float64
 	segmentColors   []string
+	segmentWidths   []float64 // Width of the segment leading to each entry, before stroke_scale
 	markerStyles    []JunctionMarkerStyle
 	connectorStyles []ConnectorStyle
 	yearStyles      []YearTextStyle
 	commentStyles   []CommentTextStyle
+	entryIDs        []string // Unique id of each entry's group element
 }
 
 // CommentBlockLayout holds layout information for comment blocks
@@ -207,6 +444,9 @@ type CommentBlockLayout struct {
 	titleLineAbsY      float64
 	bodyAbsX, bodyAbsY float64 // Top-left corner of the foreignObject
 	foHeight           float64 // Estimated height of content *within* foreignObject
+	isFixedHeight      bool    // True when BlockHeight is set and the body must be clipped
+	isRotated          bool    // True when placed perpendicular to an angled (non axis-aligned) axis
+	anchorX, anchorY   float64 // Anchor point the block was positioned from
 	// Parsed padding values
 	padTop, padRight, padBottom, padLeft float64
 	contentWidth                         float64 // Width available for content inside padding (FO width)
@@ -214,26 +454,27 @@ type CommentBlockLayout struct {
 
 // Initialize layout configuration from template
 func initializeLayoutConfig(template Template) LayoutConfig {
-	config := LayoutConfig{}
+	config := LayoutConfig{diagnostics: &diagnosticLog{}}
 
 	config.layoutPadding = template.Layout.Padding
 	if config.layoutPadding <= 0 {
-		config.layoutPadding = 50.0
+		config.layoutPadding = defaultLayoutPadding
 	}
+	config.paddingTop, config.paddingRight, config.paddingBottom, config.paddingLeft = resolveCanvasPadding(config.diagnostics, template.Layout, config.layoutPadding)
 
 	config.defaultEntrySpacing = template.Layout.EntrySpacing
 	if config.defaultEntrySpacing <= 0 {
-		config.defaultEntrySpacing = 150.0
+		config.defaultEntrySpacing = defaultLayoutEntrySpacing
 	}
 
 	config.defaultConnectorLength = template.Layout.ConnectorLength
-	if config.defaultConnectorLength <= 0 {
-		config.defaultConnectorLength = 50.0
+	if config.defaultConnectorLength == 0 { // Negative lengths are kept: they flip elements to the other side
+		config.defaultConnectorLength = defaultLayoutConnectorLength
 	}
 
 	config.centerLineBaseColor = template.CenterLine.Color
 	if config.centerLineBaseColor == "" {
-		config.centerLineBaseColor = "#000000"
+		config.centerLineBaseColor = defaultCenterLineColor
 	}
 
 	config.centerLineWidth = float64(template.CenterLine.Width)
@@ -243,6 +484,90 @@ func initializeLayoutConfig(template Template) LayoutConfig {
 
 	config.centerLineIsRounded = template.CenterLine.RoundedCaps
 
+	config.minWidth = math.Max(template.Layout.MinWidth, 0)
+	config.minHeight = math.Max(template.Layout.MinHeight, 0)
+	config.maxWidth = math.Max(template.Layout.MaxWidth, 0)
+	config.maxHeight = math.Max(template.Layout.MaxHeight, 0)
+	config.axisLength = math.Max(template.Layout.AxisLength, 0)
+	config.globalAxisAngle = template.CenterLine.Angle
+	config.warnOverlap = template.Layout.WarnOverlap
+	config.transparent = template.Layout.Transparent
+	config.strokeScale = template.Layout.StrokeScale
+	if config.strokeScale <= 0 {
+		config.strokeScale = 1.0
+	}
+	config.precision = defaultCoordPrecision
+	if template.Layout.Precision != nil {
+		if *template.Layout.Precision >= 0 && *template.Layout.Precision <= maxCoordPrecision {
+			config.precision = *template.Layout.Precision
+		} else {
+			config.diagnostics.warnf("invalid_option", "layout.precision %d is out of range (0-%d), using %d.", *template.Layout.Precision, maxCoordPrecision, defaultCoordPrecision)
+		}
+	}
+	config.jitter = template.Layout.Jitter
+	if config.jitter < 0 {
+		config.diagnostics.warnf("invalid_option", "layout.jitter %g is negative, using 0.", config.jitter)
+		config.jitter = 0
+	}
+	config.seed = template.Layout.Seed
+	switch template.Layout.LineJoin {
+	case "", "miter":
+	case "round", "bevel":
+		config.lineJoin = template.Layout.LineJoin
+	default:
+		config.diagnostics.warnf("invalid_option", "layout.line_join '%s' is not miter, round or bevel, using miter.", template.Layout.LineJoin)
+	}
+	config.elementOrder = defaultElementOrder
+	if template.Layout.ElementOrder != "" {
+		if order, ok := parseElementOrder(template.Layout.ElementOrder); ok {
+			config.elementOrder = order
+		} else {
+			config.diagnostics.warnf("invalid_option", "layout.element_order '%s' is not an order of %s, using the default.", template.Layout.ElementOrder, strings.Join(defaultElementOrder, ","))
+		}
+	}
+	config.animateConnectors = template.Layout.AnimateConnectors
+	config.caption = template.Layout.Caption
+	config.captionFont = getEffectiveCaptionFont(template)
+	config.colorMap = normalizeColorMap(template.Layout.ColorMap)
+	config.importanceScale = template.Layout.ImportanceScale
+	if config.importanceScale <= 0 {
+		config.importanceScale = defaultImportanceScale
+	}
+	config.importanceScalesYear = template.Layout.ImportanceScalesYear
+	config.customDefs = template.Layout.CustomDefs
+	config.baselineCompat = template.Layout.BaselineCompat
+	config.numberEntries = template.Layout.NumberEntries
+	config.entryOffset = template.Layout.entryOffset
+	config.debugBoxes = template.Layout.debugBoxes
+	config.numberStyle = getEffectiveNumberStyle(template)
+	config.minimalMode = template.Layout.MinimalMode
+	config.fitComments = template.Layout.FitComments
+	config.noForeignObject = template.Layout.NoForeignObject
+	config.focusColor = template.Layout.FocusColor
+	if config.focusColor == "" {
+		config.focusColor = defaultFocusColor
+	}
+	config.shapeRendering = validRenderingHint(config.diagnostics, "shape-rendering", template.Layout.ShapeRendering)
+	config.textRendering = validRenderingHint(config.diagnostics, "text-rendering", template.Layout.TextRendering)
+	switch template.Layout.SpacingScale {
+	case "", "linear", "log":
+		config.spacingScale = template.Layout.SpacingScale
+	default:
+		config.diagnostics.warnf("invalid_option", "unknown layout.spacing_scale '%s' (use 'linear' or 'log'), using even spacing.", template.Layout.SpacingScale)
+	}
+	if template.Layout.Bucket != "" && template.Layout.Bucket != "none" {
+		if slices.Contains(bucketModes, template.Layout.Bucket) {
+			config.bucket = template.Layout.Bucket
+			config.bucketMerge = template.Layout.BucketMerge
+		} else {
+			config.diagnostics.warnf("invalid_option", "unknown layout.bucket '%s' (use %s), entries are not bucketed.", template.Layout.Bucket, strings.Join(bucketModes, ", "))
+		}
+	}
+	if config.bucket != "" && config.spacingScale != "" {
+		config.diagnostics.warnf("ignored_override", "layout.spacing_scale is ignored with layout.bucket, the buckets are evenly spaced.")
+		config.spacingScale = ""
+	}
+
 	return config
 }
 
@@ -252,23 +577,54 @@ func calculateTimelinePositionsAndStyles(entries []TimelineEntry, template Templ
 		entryPoints:     make([]float64, len(entries)),
 		junctionPoints:  make([]float64, len(entries)+1),
 		segmentColors:   make([]string, len(entries)),
+		segmentWidths:   make([]float64, len(entries)),
 		markerStyles:    make([]JunctionMarkerStyle, len(entries)),
 		connectorStyles: make([]ConnectorStyle, len(entries)),
 		yearStyles:      make([]YearTextStyle, len(entries)),
 		commentStyles:   make([]CommentTextStyle, len(entries)),
+		entryIDs:        buildEntryIDs(entries),
 	}
 
 	currentPos := 0.0
 
+	// A fixed axis length distributes entries evenly, taking precedence over spacing
+	fixedSpacing := 0.0
+	if config.axisLength > 0 && len(entries) > 0 {
+		fixedSpacing = config.axisLength / float64(len(entries))
+	}
+
+	// Date-based spacing: gaps follow the time between entries (linear or log scaled)
+	var scaledSpacings []float64
+	if config.spacingScale != "" {
+		baseSpacing := config.defaultEntrySpacing
+		if fixedSpacing > 0 {
+			baseSpacing = fixedSpacing
+		}
+		var ok bool
+		if scaledSpacings, ok = dateSpacings(config.diagnostics, entries, config.spacingScale, baseSpacing); !ok {
+			config.diagnostics.warnf("unparseable_date", "layout.spacing_scale '%s' needs every period to be a date or year; using even spacing.", config.spacingScale)
+		}
+	}
+
 	for i, entry := range entries {
 		// Spacing
 		spacing := config.defaultEntrySpacing
-		if entry.EntrySpacingOverride != nil {
+		if scaledSpacings != nil && entry.EntrySpacingOverride == nil {
+			spacing = scaledSpacings[i]
+		} else if fixedSpacing > 0 {
+			spacing = fixedSpacing
+			if entry.EntrySpacingOverride != nil {
+				config.diagnostics.warnf("ignored_override", "entry_spacing_override on entry %d (%s) is ignored because layout.axis_length is set.", i, entry.Period)
+			}
+		} else if entry.EntrySpacingOverride != nil {
 			spacing = *entry.EntrySpacingOverride
 		}
 		if spacing <= 0 {
 			spacing = config.defaultEntrySpacing
 		}
+		if config.bucketRanks != nil && i+1 < len(entries) && config.bucketRanks[i+1] > 0 {
+			spacing = 0 // The next entry stacks on this one's bucket
+		}
 
 		// Positions
 		data.junctionPoints[i] = currentPos
@@ -281,12 +637,28 @@ func calculateTimelinePositionsAndStyles(entries []TimelineEntry, template Templ
 		if data.segmentColors[i] == "" {
 			data.segmentColors[i] = config.centerLineBaseColor
 		}
+		data.segmentWidths[i] = config.centerLineWidth
+		if projStyle.Width != nil && *projStyle.Width > 0 {
+			data.segmentWidths[i] = *projStyle.Width
+		}
 
 		data.markerStyles[i] = getEffectiveJunctionMarkerStyle(template.PeriodDefaults.JunctionMarker, entry.JunctionMarkerOverride)
 		data.connectorStyles[i] = getEffectiveConnectorStyle(template.PeriodDefaults.Connector, entry.ConnectorOverride)
 		data.yearStyles[i] = getEffectiveYearTextStyle(template.GlobalFont, template.PeriodDefaults.YearText, entry.YearTextOverride)
 		data.commentStyles[i] = getEffectiveCommentTextStyle(template.GlobalFont, template.PeriodDefaults.CommentText, entry.CommentTextOverride)
 	}
+
+	// segment_color_after colors the segment leading to the next entry, winning over that entry's own projection color
+	for i, entry := range entries {
+		if entry.SegmentColorAfter == nil {
+			continue
+		}
+		if i+1 >= len(entries) {
+			config.diagnostics.warnf("ignored_override", "segment_color_after on entry %d (%s) is ignored because no segment follows the last entry.", i, entry.Period)
+			continue
+		}
+		data.segmentColors[i+1] = *entry.SegmentColorAfter
+	}
 	data.junctionPoints[len(entries)] = currentPos
 
 	return data
@@ -301,35 +673,30 @@ type TimelineEntryParams struct {
 	EntryAxisY   float64 // Y coordinate of the entry on the potentially angled axis
 	IsHorizontal bool    // True if base orientation is horizontal (for annotation direction)
 	Config       LayoutConfig
+	// Widest comment block that fits beside the entry with layout.fit_comments (0 = no limit)
+	CommentFitWidth float64
+	// Stacked on the previous entry's bucket (layout.bucket): only the comment is drawn, on
+	// StackSide (0 = its own side) beyond StackEdge, the far edge of the comment below it
+	Stacked              bool
+	StackSide, StackEdge float64
 }
 
-// Update the drawTimelineEntry function to handle connectors correctly based on config
-func drawTimelineEntry(svg *bytes.Buffer, bounds *bounds, params TimelineEntryParams) {
-	i := params.Index
-	entry := params.Entry
-	timelineData := params.Data
-	entryAxisX := params.EntryAxisX // Use the passed exact coordinates
-	entryAxisY := params.EntryAxisY // Use the passed exact coordinates
-	// Determine effective orientation for *this specific entry*
-	effectiveIsHorizontal := params.IsHorizontal // Start with global orientation
+// entryOrientation returns whether entry is laid out horizontally, applying its orientation override.
+func entryOrientation(entry TimelineEntry, isHorizontal bool) bool {
 	if entry.OrientationOverride != nil {
 		if *entry.OrientationOverride == "horizontal" {
-			effectiveIsHorizontal = true
+			return true
 		} else if *entry.OrientationOverride == "vertical" {
-			effectiveIsHorizontal = false
+			return false
 		}
 		// Ignore invalid override values, keep global default
 	}
-	config := params.Config
-
-	// --- Get Styles for this entry ---
-	connStyle := timelineData.connectorStyles[i]
-	commentStyle := timelineData.commentStyles[i]
-	yearStyle := timelineData.yearStyles[i]
-	markerStyle := timelineData.markerStyles[i]
-	segmentColor := timelineData.segmentColors[i] // Color of segment LEADING to this entry
+	return isHorizontal
+}
 
-	// Determine cross-axis direction based on *effective* orientation
+// entryCrossSides returns the cross-axis directions of the i-th entry's comment and year,
+// before a negative offset flips them.
+func entryCrossSides(diag *diagnosticLog, i int, entry TimelineEntry, connStyle ConnectorStyle, isHorizontal bool) (float64, float64) {
 	commentCrossAxisDir := 1.0
 	yearCrossAxisDir := -1.0
 	if i%2 != 0 { // Alternate sides
@@ -338,62 +705,169 @@ func drawTimelineEntry(svg *bytes.Buffer, bounds *bounds, params TimelineEntryPa
 	}
 	// Allow override for connector side, checking against *effective* orientation
 	if connStyle.Side != "" {
-		if (effectiveIsHorizontal && connStyle.Side == "top") || (!effectiveIsHorizontal && connStyle.Side == "left") {
+		if (isHorizontal && connStyle.Side == "top") || (!isHorizontal && connStyle.Side == "left") {
 			commentCrossAxisDir = -1.0
 			yearCrossAxisDir = 1.0 // Year goes opposite comment
-		} else if (effectiveIsHorizontal && connStyle.Side == "bottom") || (!effectiveIsHorizontal && connStyle.Side == "right") {
+		} else if (isHorizontal && connStyle.Side == "bottom") || (!isHorizontal && connStyle.Side == "right") {
 			commentCrossAxisDir = 1.0
 			yearCrossAxisDir = -1.0 // Year goes opposite comment
 		}
 	}
+	// Explicit per-entry sides win over alternation and the connector side
+	return applyEntrySides(diag, entry, commentCrossAxisDir, yearCrossAxisDir)
+}
 
-	// --- Junction Marker ---
-	markerColor := determineMarkerColor(markerStyle, segmentColor, connStyle)
-	drawJunctionMarker(svg, bounds, JunctionMarkerParams{
-		Style:           markerStyle,
-		CenterX:         entryAxisX,
-		CenterY:         entryAxisY,
-		MarkerColor:     markerColor,
-		IsHorizontal:    effectiveIsHorizontal, // Use effective orientation
-		CenterLineWidth: config.centerLineWidth,
-	})
+// EntryLayout holds the layout information computed while drawing a single entry
+type EntryLayout struct {
+	Comment      *CommentBlockLayout // nil if the entry has no comment block
+	CommentSide  float64             // Cross-axis direction of the comment block
+	IsHorizontal bool                // Effective orientation of the entry
+	Year         bounds              // Extent of the year text and shape (its link area)
+	Bounds       bounds              // Extent of everything drawn for the entry
+}
 
-	// --- Year Element ---
-	// Calculate center based on axis point and *effective* orientation
-	yearCenterX, yearCenterY := calculateElementCenter(ElementCenterParams{
-		AxisX:        entryAxisX,
-		AxisY:        entryAxisY,
-		MainOffset:   yearStyle.MainAxisOffset,
-		CrossOffset:  yearStyle.CrossAxisOffset,
-		ConnectorLen: config.defaultConnectorLength,
-		CrossDir:     yearCrossAxisDir,
-		IsHorizontal: effectiveIsHorizontal,
-	})
+// Update the drawTimelineEntry function to handle connectors correctly based on config
+// bounds collects only this entry's elements; the caller merges it into the timeline bounds.
+func drawTimelineEntry(svg *svgBuffer, bounds *bounds, params TimelineEntryParams) (entryLayout EntryLayout) {
+	i := params.Index
+	entry := params.Entry
+	timelineData := params.Data
+	entryAxisX := params.EntryAxisX // Use the passed exact coordinates
+	entryAxisY := params.EntryAxisY // Use the passed exact coordinates
+	// Determine effective orientation for *this specific entry*
+	effectiveIsHorizontal := entryOrientation(entry, params.IsHorizontal)
+	config := params.Config
+	entryLayout = EntryLayout{IsHorizontal: effectiveIsHorizontal}
+	// Effective angle of the axis segment this entry belongs to; elements sit perpendicular to it
+	entryAxisAngle := entry.AngleOverride
+	if entryAxisAngle == nil {
+		entryAxisAngle = config.globalAxisAngle
+	}
 
-	// --- Draw Connector to Year Element (Restored Logic) ---
-	drawPeriodLine := connStyle.DrawToPeriod == nil || *connStyle.DrawToPeriod
-	if drawPeriodLine {
-		drawConnector(svg, bounds, ConnectorParams{
-			X1:                 yearCenterX,
-			Y1:                 yearCenterY,
-			X2:                 entryAxisX,
-			Y2:                 entryAxisY,
-			Style:              connStyle,
-			SegmentColor:       segmentColor,
-			IsHorizontal:       effectiveIsHorizontal,
-			CrossAxisDir:       yearCrossAxisDir,
-			LineIsVisible:      drawPeriodLine,
-			ElementCrossOffset: yearStyle.CrossAxisOffset,
-		})
+	// --- Get Styles for this entry ---
+	connStyle := timelineData.connectorStyles[i]
+	commentStyle := timelineData.commentStyles[i]
+	yearStyle := timelineData.yearStyles[i]
+	markerStyle := timelineData.markerStyles[i]
+	segmentColor := timelineData.segmentColors[i] // Color of segment LEADING to this entry
+
+	// Important entries get bigger markers (and optionally bigger years)
+	if entry.Importance != nil {
+		factor := importanceFactor(*entry.Importance, config.importanceScale)
+		markerStyle.Size *= factor
+		if config.importanceScalesYear && yearStyle.Font.FontSize > 0 {
+			yearStyle.Font.FontSize = int(math.Round(float64(yearStyle.Font.FontSize) * factor))
+		}
+	}
+
+	// Determine cross-axis direction based on *effective* orientation
+	commentCrossAxisDir, yearCrossAxisDir := entryCrossSides(svg.warnings(), i+config.entryOffset, entry, connStyle, effectiveIsHorizontal)
+	if params.Stacked {
+		// A bucket stack grows outward on the side of its first comment
+		if params.StackSide != 0 {
+			commentCrossAxisDir, yearCrossAxisDir = params.StackSide, -params.StackSide
+		}
+		if params.StackEdge > 0 {
+			commentStyle.CrossAxisOffset = params.StackEdge + bucketStackGap - config.defaultConnectorLength
+		}
 	}
 
-	// --- Draw Year Element itself ---
-	drawYearElement(svg, bounds, entry, yearStyle, yearCenterX, yearCenterY)
+	// --- Entry Group (fragment target, e.g. timeline.svg#entry-2017) ---
+	fmt.Fprintf(svg, `  <g id="%s">`, escapeXML(timelineData.entryIDs[i]))
+	svg.WriteString("\n")
+	defer svg.WriteString("  </g>\n")
 
-	// --- Comment Element and Connector ---
-	if entry.CommentText != "" || entry.TitleText != "" || entry.CommentImage != "" {
+	// --- Junction Marker ---
+	markerColor := determineMarkerColor(markerStyle, segmentColor, connStyle)
+	if entry.Divider {
+		// Drawn last, once the entry's extent is known, but inserted first so it stays behind the entry
+		groupStart := svg.Len()
+		defer func() {
+			drawn := append([]byte(nil), svg.Bytes()[groupStart:]...)
+			svg.Truncate(groupStart)
+			var dividerStyle DividerStyle
+			if entry.DividerStyle != nil {
+				dividerStyle = *entry.DividerStyle
+			}
+			startReach, endReach := dividerStyle.Extent, dividerStyle.Extent
+			if dividerStyle.Extent <= 0 {
+				// Cover the comment and year (with its shape), at least the connector length
+				extent := *bounds
+				extent.merge(entryLayout.Year)
+				startReach, endReach = dividerReach(extent, entryAxisX, entryAxisY, effectiveIsHorizontal, entryAxisAngle, config.defaultConnectorLength)
+			}
+			drawDivider(svg, bounds, DividerParams{
+				Style:        dividerStyle,
+				CenterX:      entryAxisX,
+				CenterY:      entryAxisY,
+				StartReach:   startReach,
+				EndReach:     endReach,
+				Color:        markerColor,
+				IsHorizontal: effectiveIsHorizontal,
+				AxisAngle:    entryAxisAngle,
+				StrokeScale:  config.strokeScale,
+			})
+			svg.Write(drawn)
+			entryLayout.Bounds = *bounds
+		}()
+		if entry.DividerStyle != nil && entry.DividerStyle.ReplaceMarker {
+			markerStyle.Shape = "none"
+		}
+	}
+	// The marker, year and comment parts (each with its connector) are drawn in layout.element_order
+	drawMarkerPart := func() {
+		drawJunctionMarker(svg, bounds, JunctionMarkerParams{
+			Style:           markerStyle,
+			CenterX:         entryAxisX,
+			CenterY:         entryAxisY,
+			MarkerColor:     markerColor,
+			IsHorizontal:    effectiveIsHorizontal, // Use effective orientation
+			AxisAngle:       entryAxisAngle,
+			CenterLineWidth: config.centerLineWidth,
+			StrokeScale:     config.strokeScale,
+		})
+		if entry.ThumbnailImage != "" {
+			thumbnailSize := entry.ThumbnailSize
+			if thumbnailSize <= 0 {
+				thumbnailSize = defaultThumbnailSize
+			}
+			drawThumbnail(svg, bounds, ThumbnailParams{
+				Image:       entry.ThumbnailImage,
+				Size:        thumbnailSize,
+				BorderColor: ternary(entry.ThumbnailBorderColor != "", entry.ThumbnailBorderColor, markerColor),
+				BorderWidth: entry.ThumbnailBorderWidth,
+				CenterX:     entryAxisX,
+				CenterY:     entryAxisY,
+				ClipID:      timelineData.entryIDs[i] + "-thumbnail-clip",
+				StrokeScale: config.strokeScale,
+			})
+		}
+		if config.numberEntries {
+			drawNumberBadge(svg, bounds, NumberBadgeParams{
+				Number:         i + 1 + config.entryOffset,
+				Style:          config.numberStyle,
+				FillColor:      ternary(config.numberStyle.FillColor != "", config.numberStyle.FillColor, markerColor),
+				CenterX:        entryAxisX,
+				CenterY:        entryAxisY,
+				StrokeScale:    config.strokeScale,
+				BaselineCompat: config.baselineCompat,
+			})
+		}
+	}
+
+	// --- Comment Layout (computed up front, an inline block pushes the year aside) ---
+	hasComment := !config.minimalMode && (entry.CommentText != "" || entry.TitleText != "" || entry.CommentImage != "")
+	commentInline := commentStyle.Position == "inline"
+	var commentAnchorX, commentAnchorY float64
+	var blockLayout CommentBlockLayout
+	if hasComment {
+		if !commentInline {
+			// A negative distance puts the box on the other side; the layout, edge point and
+			// connector below all work from the resolved side
+			commentCrossAxisDir = resolveCrossSide(commentCrossAxisDir, config.defaultConnectorLength+commentStyle.CrossAxisOffset)
+		}
 		// Calculate Comment Anchor Point using *effective* orientation
-		commentAnchorX, commentAnchorY := calculateElementCenter(ElementCenterParams{
+		commentAnchorX, commentAnchorY = calculateElementCenter(ElementCenterParams{
 			AxisX:        entryAxisX,
 			AxisY:        entryAxisY,
 			MainOffset:   commentStyle.MainAxisOffset,
@@ -401,10 +875,12 @@ func drawTimelineEntry(svg *bytes.Buffer, bounds *bounds, params TimelineEntryPa
 			ConnectorLen: config.defaultConnectorLength,
 			CrossDir:     commentCrossAxisDir,
 			IsHorizontal: effectiveIsHorizontal,
+			AxisAngle:    entryAxisAngle,
+			Inline:       commentInline,
 		})
 
 		// Calculate comment block layout based on the anchor point and *effective* orientation
-		blockLayout := calculateCommentBlockLayout(CommentParams{
+		blockLayout = calculateCommentBlockLayout(svg.warnings(), CommentParams{
 			Style:        commentStyle,
 			AnchorX:      commentAnchorX,
 			AnchorY:      commentAnchorY,
@@ -415,56 +891,380 @@ func drawTimelineEntry(svg *bytes.Buffer, bounds *bounds, params TimelineEntryPa
 			TitleText:    entry.TitleText,
 			BodyText:     entry.CommentText,
 			ImageURL:     entry.CommentImage,
+			ImageAlt:     entry.ImageAlt,
+			AxisAngle:    entryAxisAngle,
+			Inline:       commentInline,
+			BodyHeight:   config.bodyHeights[timelineData.entryIDs[i]],
+			FitWidth:     params.CommentFitWidth,
+			TextBody:     config.noForeignObject,
 		})
+	}
+	yearConnectorLen := config.defaultConnectorLength
+	yearCrossAxisDir = resolveCrossSide(yearCrossAxisDir, yearConnectorLen+yearStyle.CrossAxisOffset)
+	if hasComment && commentInline {
+		// The block covers the axis point, so the year starts beyond its cross-axis half extent
+		if effectiveIsHorizontal {
+			yearConnectorLen += blockLayout.visualBlockHeight / 2.0
+		} else {
+			yearConnectorLen += blockLayout.visualBlockWidth / 2.0
+		}
+	}
+
+	// --- Year Element ---
+	// Calculate center based on axis point and *effective* orientation
+	yearCenterX, yearCenterY := calculateElementCenter(ElementCenterParams{
+		AxisX:        entryAxisX,
+		AxisY:        entryAxisY,
+		MainOffset:   yearStyle.MainAxisOffset,
+		CrossOffset:  yearStyle.CrossAxisOffset,
+		ConnectorLen: yearConnectorLen,
+		CrossDir:     yearCrossAxisDir,
+		IsHorizontal: effectiveIsHorizontal,
+		AxisAngle:    entryAxisAngle,
+	})
+
+	// --- Draw Connector to Year Element (Restored Logic) ---
+	drawYearPart := func() {
+		drawPeriodLine := connStyle.DrawToPeriod == nil || *connStyle.DrawToPeriod
+		if drawPeriodLine {
+			yearElementColor := yearStyle.TextColor // An unshaped period only has its text
+			if yearStyle.Shape != "" && yearStyle.Shape != "none" {
+				yearElementColor = connectorElementColor(yearStyle.BorderColor, yearStyle.BorderWidth, yearStyle.FillColor)
+			}
+			drawConnector(svg, bounds, ConnectorParams{
+				X1:                 yearCenterX,
+				Y1:                 yearCenterY,
+				X2:                 entryAxisX,
+				Y2:                 entryAxisY,
+				Style:              connStyle,
+				SegmentColor:       segmentColor,
+				IsHorizontal:       effectiveIsHorizontal,
+				CrossAxisDir:       yearCrossAxisDir,
+				LineIsVisible:      drawPeriodLine,
+				ElementCrossOffset: yearStyle.CrossAxisOffset,
+				StrokeScale:        config.strokeScale,
+				Animate:            config.animateConnectors,
+				EntryIndex:         i,
+				GradientID:         timelineData.entryIDs[i] + "-year-connector-gradient",
+				LineJoin:           config.lineJoin,
+				ElementColor:       yearElementColor,
+			})
+		}
+
+		// --- Draw Year Element itself ---
+		yearStyle.BorderWidth *= config.strokeScale // Draw-time only, the year layout ignores the border
+		entryLayout.Year = drawYearElement(svg, bounds, entry, yearStyle, yearCenterX, yearCenterY, config.baselineCompat)
+	}
+
+	// --- Comment Element and Connector ---
+	drawCommentPart := func() {
+		if hasComment {
+			// --- Draw Connector to comment using *effective* orientation (inline blocks sit on the axis, no connector)
+			if !commentInline && !params.Stacked { // A stacked block's connector would cross the blocks below it
+				// Determine comment edge point based on *effective* orientation
+				commentEdgeX, commentEdgeY := calculateCommentEdgePoint(blockLayout, commentCrossAxisDir, effectiveIsHorizontal, resolveEdgeAnchor(commentStyle.EdgeAnchor))
+				drawCommentLine := connStyle.DrawToComment == nil || *connStyle.DrawToComment
+				drawConnector(svg, bounds, ConnectorParams{
+					X1:                 commentEdgeX,
+					Y1:                 commentEdgeY,
+					X2:                 entryAxisX,
+					Y2:                 entryAxisY,
+					Style:              connStyle,
+					SegmentColor:       segmentColor,
+					IsHorizontal:       effectiveIsHorizontal,
+					CrossAxisDir:       commentCrossAxisDir,
+					LineIsVisible:      drawCommentLine,
+					ElementCrossOffset: commentStyle.CrossAxisOffset,
+					StrokeScale:        config.strokeScale,
+					Animate:            config.animateConnectors,
+					EntryIndex:         i,
+					GradientID:         timelineData.entryIDs[i] + "-comment-connector-gradient",
+					LineJoin:           config.lineJoin,
+					ElementColor:       connectorElementColor(commentStyle.BorderColor, float64(commentStyle.BorderWidth), commentStyle.FillColor),
+				})
+			}
+
+			// --- Draw Comment Block ---
+			drawComment(svg, bounds, CommentParams{
+				Style:        commentStyle,
+				AnchorX:      commentAnchorX,
+				AnchorY:      commentAnchorY,
+				CrossAxisDir: commentCrossAxisDir,
+				IsHorizontal: effectiveIsHorizontal,
+				SegmentWidth: config.defaultEntrySpacing,
+				DefaultColor: connStyle.Color,
+				TitleText:    entry.TitleText,
+				BodyText:     entry.CommentText,
+				ImageURL:     entry.CommentImage,
+				ImageAlt:     entry.ImageAlt,
+				Link:         entry.CommentLink,
+				EntryID:      timelineData.entryIDs[i],
+				AxisAngle:    entryAxisAngle,
+				StrokeScale:  config.strokeScale,
+				Inline:       commentInline,
+				BodyHeight:   config.bodyHeights[timelineData.entryIDs[i]],
+				FitWidth:     params.CommentFitWidth,
+				TextBody:     config.noForeignObject,
+			})
+			entryLayout.Comment = &blockLayout
+			entryLayout.CommentSide = commentCrossAxisDir
+			if commentInline {
+				entryLayout.CommentSide = 0 // On the axis: compared against the other inline blocks
+			}
+		}
+	}
+
+	for _, element := range config.elementOrder {
+		if params.Stacked && element != "comment" {
+			continue // The bucket's first entry drew the marker and the bucket label
+		}
+		switch element {
+		case "marker":
+			drawMarkerPart()
+		case "year":
+			drawYearPart()
+		case "comment":
+			drawCommentPart()
+		}
+	}
+
+	// --- Focus ring around the whole entry ---
+	if entry.Focus {
+		drawFocusRing(svg, bounds, config.focusColor, config.strokeScale)
+	}
+	entryLayout.Bounds = *bounds
+	return entryLayout
+}
+
+// dividerReach returns how far a divider through the axis point must reach on the start and
+// end side to cover extent (projected onto the cross axis), and at least minReach.
+func dividerReach(extent bounds, axisX, axisY float64, isHorizontal bool, axisAngle *float64, minReach float64) (float64, float64) {
+	startReach, endReach := minReach, minReach
+	if !extent.isSet {
+		return startReach, endReach
+	}
+	_, _, crossX, crossY := axisFrame(isHorizontal, axisAngle)
+	for _, corner := range [][2]float64{{extent.minX, extent.minY}, {extent.maxX, extent.minY}, {extent.minX, extent.maxY}, {extent.maxX, extent.maxY}} {
+		projection := (corner[0]-axisX)*crossX + (corner[1]-axisY)*crossY
+		startReach = math.Max(startReach, -projection)
+		endReach = math.Max(endReach, projection)
+	}
+	return startReach, endReach
+}
+
+// Add a parameter struct for drawTimelineEntries
+type TimelineEntriesParams struct {
+	SVG          *svgBuffer
+	Bounds       *bounds // Timeline bounds, grown by every entry's own bounds
+	Entries      []TimelineEntry
+	Data         TimelinePositionData
+	AxisPoints   []AxisPoint // Pre-calculated axis point of each entry
+	IsHorizontal bool
+	Config       LayoutConfig
+}
 
-		// Determine comment edge point based on *effective* orientation
-		commentEdgeX, commentEdgeY := calculateCommentEdgePoint(blockLayout, commentCrossAxisDir, effectiveIsHorizontal)
-
-		// --- Draw Connector to comment using *effective* orientation
-		drawCommentLine := connStyle.DrawToComment == nil || *connStyle.DrawToComment
-		drawConnector(svg, bounds, ConnectorParams{
-			X1:                 commentEdgeX,
-			Y1:                 commentEdgeY,
-			X2:                 entryAxisX,
-			Y2:                 entryAxisY,
-			Style:              connStyle,
-			SegmentColor:       segmentColor,
-			IsHorizontal:       effectiveIsHorizontal,
-			CrossAxisDir:       commentCrossAxisDir,
-			LineIsVisible:      drawCommentLine,
-			ElementCrossOffset: commentStyle.CrossAxisOffset,
+// drawTimelineEntries draws every entry with its own bounds, merges them into the timeline
+// bounds and returns the entries' layouts, each holding its bounding box.
+func drawTimelineEntries(params TimelineEntriesParams) []EntryLayout {
+	entryLayouts := make([]EntryLayout, len(params.Entries))
+	fitWidths := make([]float64, len(params.Entries))
+	if params.Config.fitComments {
+		fitWidths = commentFitWidths(params)
+	}
+	stackSide, stackEdge := 0.0, 0.0 // Comment side and reach of the current bucket stack
+	for i, entry := range params.Entries {
+		var entryBounds bounds
+		stacked := params.Config.bucketRanks != nil && params.Config.bucketRanks[i] > 0
+		entryLayouts[i] = drawTimelineEntry(params.SVG, &entryBounds, TimelineEntryParams{
+			Index:           i,
+			Entry:           entry,
+			Data:            params.Data,
+			EntryAxisX:      params.AxisPoints[i].X,
+			EntryAxisY:      params.AxisPoints[i].Y,
+			IsHorizontal:    params.IsHorizontal,
+			Config:          params.Config,
+			CommentFitWidth: fitWidths[i],
+			Stacked:         stacked,
+			StackSide:       stackSide,
+			StackEdge:       stackEdge,
 		})
+		params.Bounds.merge(entryBounds)
+		if !stacked {
+			stackSide, stackEdge = 0, 0
+		}
+		if edge := commentStackEdge(entryLayouts[i], params.AxisPoints[i]); edge > 0 {
+			stackSide, stackEdge = entryLayouts[i].CommentSide, edge
+		}
+	}
+	return entryLayouts
+}
 
-		// --- Draw Comment Block ---
-		drawComment(svg, bounds, CommentParams{
-			Style:        commentStyle,
-			AnchorX:      commentAnchorX,
-			AnchorY:      commentAnchorY,
-			CrossAxisDir: commentCrossAxisDir,
-			IsHorizontal: effectiveIsHorizontal,
-			SegmentWidth: config.defaultEntrySpacing,
-			DefaultColor: connStyle.Color,
-			TitleText:    entry.TitleText,
-			BodyText:     entry.CommentText,
-			ImageURL:     entry.CommentImage,
+// commentFitWidths returns, per entry, the widest comment block that fits into the space
+// beside it (layout.fit_comments), 0 where there is no limit. It is a first pass over the
+// comment anchors: along a horizontal axis a block may reach halfway to the nearest comment
+// on the same side, so a crowded side gets narrower boxes than an open one; beside a
+// vertical axis it may reach the max_width canvas edge, assuming the axis is centered.
+// Angled axes and inline comments are not limited.
+func commentFitWidths(params TimelineEntriesParams) []float64 {
+	type fitAnchor struct {
+		ok           bool
+		x            float64
+		side         float64
+		isHorizontal bool
+	}
+	config := params.Config
+	anchors := make([]fitAnchor, len(params.Entries))
+	for i, entry := range params.Entries {
+		commentStyle := params.Data.commentStyles[i]
+		hasComment := !config.minimalMode && (entry.CommentText != "" || entry.TitleText != "" || entry.CommentImage != "")
+		angle := entry.AngleOverride
+		if angle == nil {
+			angle = config.globalAxisAngle
+		}
+		if !hasComment || commentStyle.Position == "inline" || isAngledAxis(angle) {
+			continue
+		}
+		isHorizontal := entryOrientation(entry, params.IsHorizontal)
+		side, _ := entryCrossSides(config.diagnostics, i+config.entryOffset, entry, params.Data.connectorStyles[i], isHorizontal)
+		side = resolveCrossSide(side, config.defaultConnectorLength+commentStyle.CrossAxisOffset)
+		x, _ := calculateElementCenter(ElementCenterParams{
+			AxisX:        params.AxisPoints[i].X,
+			AxisY:        params.AxisPoints[i].Y,
+			MainOffset:   commentStyle.MainAxisOffset,
+			CrossOffset:  commentStyle.CrossAxisOffset,
+			ConnectorLen: config.defaultConnectorLength,
+			CrossDir:     side,
+			IsHorizontal: isHorizontal,
 		})
+		anchors[i] = fitAnchor{ok: true, x: x, side: side, isHorizontal: isHorizontal}
+	}
+
+	widths := make([]float64, len(params.Entries))
+	for i, anchor := range anchors {
+		if !anchor.ok {
+			continue
+		}
+		if !anchor.isHorizontal {
+			if config.maxWidth > 0 {
+				edgePadding := config.paddingLeft
+				if anchor.side > 0 {
+					edgePadding = config.paddingRight
+				}
+				widths[i] = math.Max(config.maxWidth/2-edgePadding-math.Abs(anchor.x-params.AxisPoints[i].X), commentFitMinWidth)
+			}
+			continue
+		}
+		nearest := math.Inf(1)
+		for j, other := range anchors {
+			if j != i && other.ok && other.isHorizontal && other.side == anchor.side {
+				nearest = math.Min(nearest, math.Abs(other.x-anchor.x))
+			}
+		}
+		if !math.IsInf(nearest, 1) {
+			widths[i] = math.Max(nearest-commentFitGap, commentFitMinWidth)
+		}
+	}
+	return widths
+}
+
+// drawFocusRing draws a dashed rounded rectangle around the area in bounds and grows
+// bounds to include it.
+func drawFocusRing(svg *svgBuffer, bounds *bounds, color string, strokeScale float64) {
+	if !bounds.isSet {
+		return
+	}
+	strokeWidth := 2.0 * strokeScale
+	x, y := bounds.minX-focusRingPadding, bounds.minY-focusRingPadding
+	width := bounds.maxX - bounds.minX + 2*focusRingPadding
+	height := bounds.maxY - bounds.minY + 2*focusRingPadding
+	fmt.Fprintf(svg, `    <rect class="timeline-focus" x="%s" y="%s" width="%s" height="%s" rx="8" ry="8" fill="none" stroke="%s" stroke-width="%s" stroke-dasharray="6,4"/>`,
+		svg.coord(x), svg.coord(y), svg.coord(width), svg.coord(height), svg.mapColor(color), svg.coord(strokeWidth))
+	svg.WriteString("\n")
+	bounds.updateRect(x-strokeWidth/2, y-strokeWidth/2, width+strokeWidth, height+strokeWidth)
+}
+
+// Debug overlay colors, one per kind of box
+const (
+	debugYearBoxColor    = "#1E88E5"
+	debugCommentBoxColor = "#E91E63"
+	debugContentBoxColor = "#43A047"
+)
+
+// drawDebugBoxes overlays a thin rectangle around every year element and comment block and
+// around the whole content area. It runs after layout and leaves the bounds untouched, so
+// the canvas is the same as without the overlay.
+func drawDebugBoxes(svg *svgBuffer, layouts []EntryLayout, content bounds) {
+	box := func(kind, color string, x, y, width, height float64) {
+		fmt.Fprintf(svg, `  <rect class="debug-box debug-%s" x="%s" y="%s" width="%s" height="%s" fill="none" stroke="%s" stroke-width="0.5"/>`+"\n",
+			kind, svg.coord(x), svg.coord(y), svg.coord(width), svg.coord(height), color)
+	}
+	svg.WriteString("  <g class=\"debug-boxes\" pointer-events=\"none\">\n")
+	for _, layout := range layouts {
+		if year := layout.Year; year.isSet {
+			box("year", debugYearBoxColor, year.minX, year.minY, year.maxX-year.minX, year.maxY-year.minY)
+		}
+		if comment := layout.Comment; comment != nil {
+			box("comment", debugCommentBoxColor, comment.blockX, comment.blockY, comment.visualBlockWidth, comment.visualBlockHeight)
+		}
+	}
+	if content.isSet {
+		box("content", debugContentBoxColor, content.minX, content.minY, content.maxX-content.minX, content.maxY-content.minY)
+	}
+	svg.WriteString("  </g>\n")
+}
+
+// warnOverlappingComments logs a warning for every pair of comment boxes on the same side
+// of the axis whose rectangles intersect, including the overlap area.
+func warnOverlappingComments(diag *diagnosticLog, layouts []EntryLayout) {
+	for i := range layouts {
+		a := layouts[i].Comment
+		if a == nil {
+			continue
+		}
+		for j := i + 1; j < len(layouts); j++ {
+			b := layouts[j].Comment
+			if b == nil || layouts[i].CommentSide != layouts[j].CommentSide || layouts[i].IsHorizontal != layouts[j].IsHorizontal {
+				continue
+			}
+			overlapW := math.Min(a.blockX+a.visualBlockWidth, b.blockX+b.visualBlockWidth) - math.Max(a.blockX, b.blockX)
+			overlapH := math.Min(a.blockY+a.visualBlockHeight, b.blockY+b.visualBlockHeight) - math.Max(a.blockY, b.blockY)
+			if overlapW > 0 && overlapH > 0 {
+				diag.warnf("overlap", "Comment boxes of entries %d and %d overlap (%.0f px²).", i, j, overlapW*overlapH)
+			}
+		}
+	}
+}
+
+// resolveEdgeAnchor returns the effective edge_anchor: 0.5 (edge center) when unset, clamped to 0-1.
+func resolveEdgeAnchor(edgeAnchor *float64) float64 {
+	if edgeAnchor == nil {
+		return 0.5
 	}
+	return math.Min(math.Max(*edgeAnchor, 0), 1)
 }
 
 // --- Helper to find the edge point of the comment box ---
-func calculateCommentEdgePoint(layout CommentBlockLayout, crossAxisDir float64, isHorizontal bool) (float64, float64) {
-	// Calculate the center of the edge facing the timeline axis
+// edgeAnchor is the fraction along the edge facing the axis, from its left (horizontal) or
+// top (vertical) end; 0.5 is the edge center.
+func calculateCommentEdgePoint(layout CommentBlockLayout, crossAxisDir float64, isHorizontal bool, edgeAnchor float64) (float64, float64) {
+	if layout.isRotated {
+		// Angled axis: connect at the point of the box closest to the anchor
+		edgeX := math.Min(math.Max(layout.anchorX, layout.blockX), layout.blockX+layout.visualBlockWidth)
+		edgeY := math.Min(math.Max(layout.anchorY, layout.blockY), layout.blockY+layout.visualBlockHeight)
+		return edgeX, edgeY
+	}
+	// Calculate the anchor point on the edge facing the timeline axis
 		if isHorizontal {
-		if crossAxisDir < 0 { // Top edge center
-			return layout.blockX + layout.visualBlockWidth/2.0, layout.blockY
-		} else { // Bottom edge center
-			return layout.blockX + layout.visualBlockWidth/2.0, layout.blockY + layout.visualBlockHeight
+		if crossAxisDir < 0 { // Block above the axis: bottom edge
+			return layout.blockX + layout.visualBlockWidth*edgeAnchor, layout.blockY + layout.visualBlockHeight
+		} else { // Block below the axis: top edge
+			return layout.blockX + layout.visualBlockWidth*edgeAnchor, layout.blockY
 		}
 	} else { // Vertical
-		if crossAxisDir < 0 { // Left edge center
-			return layout.blockX, layout.blockY + layout.visualBlockHeight/2.0
-		} else { // Right edge center
-			return layout.blockX + layout.visualBlockWidth, layout.blockY + layout.visualBlockHeight/2.0
+		if crossAxisDir < 0 { // Block left of the axis: right edge
+			return layout.blockX + layout.visualBlockWidth, layout.blockY + layout.visualBlockHeight*edgeAnchor
+		} else { // Block right of the axis: left edge
+			return layout.blockX, layout.blockY + layout.visualBlockHeight*edgeAnchor
 		}
 	}
 }
@@ -484,7 +1284,7 @@ func determineMarkerColor(markerStyle JunctionMarkerStyle, segmentColor string,
 }
 
 // --- Helper function to determine connector line style attributes ---
-func calculateConnectorStyleAttributes(style ConnectorStyle, segmentColor string) (string, float64, string) {
+func calculateConnectorStyleAttributes(style ConnectorStyle, segmentColor string, strokeScale float64) (string, float64, string) {
 	connDrawColor := style.Color
 		if connDrawColor == "" {
 			connDrawColor = segmentColor
@@ -493,6 +1293,7 @@ func calculateConnectorStyleAttributes(style ConnectorStyle, segmentColor string
 		if connDrawWidth <= 0 {
 			connDrawWidth = 1
 		}
+	connDrawWidth *= strokeScale
 	connDashArray := getStrokeDashArray(style.LineType, int(connDrawWidth))
 	return connDrawColor, connDrawWidth, connDashArray
 }
@@ -526,7 +1327,7 @@ func calculateConnectorDotPosition(axisX, axisY, ux, uy, nx, ny float64, dotStyl
 
 // --- Parameter struct for drawConnectorLineSegments ---
 type ConnectorLineSegmentsParams struct {
-	SVG            *bytes.Buffer
+	SVG            *svgBuffer
 	Bounds         *bounds
 	ConnParams     ConnectorParams // Original ConnectorParams for context
 	DotX, DotY     float64         // Calculated dot position
@@ -544,15 +1345,30 @@ func drawConnectorLineSegments(params ConnectorLineSegmentsParams) {
 	}
 
 	dotStyle := params.ConnParams.Style.Dot
+	if params.ConnParams.Style.GradientToElement && params.ConnParams.GradientID != "" {
+		writeConnectorGradient(params.SVG, params.ConnParams, params.DrawColor)
+		params.DrawColor = "url(#" + params.ConnParams.GradientID + ")"
+	}
 
-	if !dotStyle.StopAtDot {
+	switch routing := params.ConnParams.Style.Routing; routing {
+	case "orthogonal":
+		drawOrthogonalConnector(params)
+		return
+	case "", "direct":
+	default:
+		params.SVG.warnf("invalid_option", "connector.routing '%s' is not direct or orthogonal, using direct.", routing)
+	}
+
+	startX, startY := params.SVG.jitterPoint(params.ConnParams.X1, params.ConnParams.Y1)
+	if !dotStyle.StopAtDot {
 		// Case 1: Line does NOT stop at dot - Draw straight line from element (X1,Y1) to axis point (X2,Y2)
-		fmt.Fprintf(params.SVG, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"%s />`,
-			params.ConnParams.X1, params.ConnParams.Y1, params.ConnParams.X2, params.ConnParams.Y2,
-			params.DrawColor, params.DrawWidth, params.DashArray)
+		endX, endY := params.SVG.jitterPoint(params.ConnParams.X2, params.ConnParams.Y2)
+		fmt.Fprintf(params.SVG, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s%s />`,
+			params.SVG.coord(startX), params.SVG.coord(startY), params.SVG.coord(endX), params.SVG.coord(endY),
+			params.SVG.mapColor(params.DrawColor), params.SVG.coord(params.DrawWidth), params.DashArray, connectorAnimationAttrs(params, math.Hypot(endX-startX, endY-startY)))
 		params.SVG.WriteString("\n")
-		params.Bounds.updatePoint(params.ConnParams.X1, params.ConnParams.Y1)
-		params.Bounds.updatePoint(params.ConnParams.X2, params.ConnParams.Y2)
+		params.Bounds.updatePoint(startX, startY)
+		params.Bounds.updatePoint(endX, endY)
 
 	} else { // Case 2: Line STOPS at dot
 		// Determine if a dogleg is needed based on dot OR element offset
@@ -564,32 +1380,38 @@ func drawConnectorLineSegments(params ConnectorLineSegmentsParams) {
 		if isDogleg {
 			// Subcase 2a: Dogleg line Element(X1,Y1) -> midPoint -> Dot(DotX, DotY)
 
-			// Calculate midpoint (elbow) based on orientation:
+			// Calculate midpoint (elbow) based on orientation. By default the leg from the element
+			// runs across the axis and the bend is at the dot's level; elbow_at "axis" swaps the
+			// legs, so the leg from the dot runs across the axis and the bend is at the element's level.
 			var midPointX, midPointY float64
-			if params.ConnParams.IsHorizontal {
-				// Horizontal: Midpoint aligns vertically with Dot, horizontally with Element
+			elbowAtElement := dotStyle.ElbowAt != "axis"
+			if params.ConnParams.IsHorizontal == elbowAtElement {
+				// Midpoint aligns vertically with Dot, horizontally with Element
 				midPointX = params.ConnParams.X1 // Same X as element
 				midPointY = params.DotY          // Same Y as dot
 			} else {
-				// Vertical: Midpoint aligns horizontally with Dot, vertically with Element
+				// Midpoint aligns horizontally with Dot, vertically with Element
 				midPointX = params.DotX          // Same X as dot
 				midPointY = params.ConnParams.Y1 // Same Y as element
 			}
 
+			midPointX, midPointY = params.SVG.jitterPoint(midPointX, midPointY)
+			dotX, dotY := params.SVG.jitterPoint(params.DotX, params.DotY)
+
 			// Draw segment 1: Element (X1, Y1) to Midpoint
-			fmt.Fprintf(params.SVG, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"%s />`,
-				params.ConnParams.X1, params.ConnParams.Y1, midPointX, midPointY,
-				params.DrawColor, params.DrawWidth, params.DashArray)
+			fmt.Fprintf(params.SVG, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s%s />`,
+				params.SVG.coord(startX), params.SVG.coord(startY), params.SVG.coord(midPointX), params.SVG.coord(midPointY),
+				params.SVG.mapColor(params.DrawColor), params.SVG.coord(params.DrawWidth), params.DashArray, connectorAnimationAttrs(params, math.Hypot(midPointX-startX, midPointY-startY)))
 			params.SVG.WriteString("\n")
 			// Draw segment 2: Midpoint to Dot
-			fmt.Fprintf(params.SVG, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"%s />`,
-				midPointX, midPointY, params.DotX, params.DotY,
-				params.DrawColor, params.DrawWidth, params.DashArray)
+			fmt.Fprintf(params.SVG, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s%s />`,
+				params.SVG.coord(midPointX), params.SVG.coord(midPointY), params.SVG.coord(dotX), params.SVG.coord(dotY),
+				params.SVG.mapColor(params.DrawColor), params.SVG.coord(params.DrawWidth), params.DashArray, connectorAnimationAttrs(params, math.Hypot(dotX-midPointX, dotY-midPointY)))
 			params.SVG.WriteString("\n")
 
-			params.Bounds.updatePoint(params.ConnParams.X1, params.ConnParams.Y1)
+			params.Bounds.updatePoint(startX, startY)
 			params.Bounds.updatePoint(midPointX, midPointY)
-			params.Bounds.updatePoint(params.DotX, params.DotY)
+			params.Bounds.updatePoint(dotX, dotY)
 
 		} else {
 			// Subcase 2b: No dogleg, draw single line Element(X1, Y1) -> Dot(DotX, DotY)
@@ -604,24 +1426,111 @@ func drawConnectorLineSegments(params ConnectorLineSegmentsParams) {
 			}
 
 			// Draw the single line segment
-			fmt.Fprintf(params.SVG, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"%s />`,
-				params.ConnParams.X1, params.ConnParams.Y1, finalEndX, finalEndY,
-				params.DrawColor, params.DrawWidth, params.DashArray)
+			finalEndX, finalEndY = params.SVG.jitterPoint(finalEndX, finalEndY)
+			fmt.Fprintf(params.SVG, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s%s />`,
+				params.SVG.coord(startX), params.SVG.coord(startY), params.SVG.coord(finalEndX), params.SVG.coord(finalEndY),
+				params.SVG.mapColor(params.DrawColor), params.SVG.coord(params.DrawWidth), params.DashArray, connectorAnimationAttrs(params, math.Hypot(finalEndX-startX, finalEndY-startY)))
 			params.SVG.WriteString("\n")
-			params.Bounds.updatePoint(params.ConnParams.X1, params.ConnParams.Y1)
+			params.Bounds.updatePoint(startX, startY)
 			params.Bounds.updatePoint(finalEndX, finalEndY)
 		}
 	}
 }
 
+// connectorElementColor returns the color a connector.gradient_to_element line fades to: the
+// element's border when it has one, otherwise its fill ("" when it has neither).
+func connectorElementColor(borderColor string, borderWidth float64, fillColor string) string {
+	if borderColor != "" && borderColor != "none" && borderWidth > 0 {
+		return borderColor
+	}
+	if fillColor == "none" || fillColor == "transparent" {
+		return ""
+	}
+	return fillColor
+}
+
+// writeConnectorGradient writes the <linearGradient> of a gradient_to_element connector, in
+// content coordinates from the axis point (segment color) to the element (its color), so it
+// also works on perfectly horizontal or vertical lines, where a bounding box gradient can't.
+// lineColor stands in for a missing segment or element color.
+func writeConnectorGradient(svg *svgBuffer, params ConnectorParams, lineColor string) {
+	axisColor, elementColor := params.SegmentColor, params.ElementColor
+	if axisColor == "" {
+		axisColor = lineColor
+	}
+	if elementColor == "" {
+		elementColor = lineColor
+	}
+	fmt.Fprintf(svg, `  <defs><linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s"><stop offset="0%%" stop-color="%s"/><stop offset="100%%" stop-color="%s"/></linearGradient></defs>`,
+		escapeXML(params.GradientID), svg.coord(params.X2), svg.coord(params.Y2), svg.coord(params.X1), svg.coord(params.Y1),
+		escapeXML(svg.mapColor(axisColor)), escapeXML(svg.mapColor(elementColor)))
+	svg.WriteString("\n")
+}
+
+// connectorAnimationAttrs returns the attributes that make a connector line of the given length
+// draw itself in (layout.animate_connectors), starting later for every entry; "" when the
+// connector isn't animated. Dashed lines need their dasharray for the pattern and stay static.
+func connectorAnimationAttrs(params ConnectorLineSegmentsParams, length float64) string {
+	if !params.ConnParams.Animate || params.DashArray != "" || length < 0.001 {
+		return ""
+	}
+	return fmt.Sprintf(` class="timeline-connector-draw" stroke-dasharray="%s" stroke-dashoffset="%s" style="animation-delay: %.2fs"`,
+		params.SVG.coord(length), params.SVG.coord(length), float64(params.ConnParams.EntryIndex)*connectorAnimationStagger)
+}
+
+// --- Helper function to draw an orthogonal (horizontal/vertical segments only) connector ---
+// The path runs from the element (X1,Y1) to the dot (or the axis point when the line
+// doesn't stop at the dot). If the end points aren't aligned, a Z-shaped path is drawn
+// whose middle leg runs parallel to the axis, halfway between element and end point.
+func drawOrthogonalConnector(params ConnectorLineSegmentsParams) {
+	startX, startY := params.ConnParams.X1, params.ConnParams.Y1
+	endX, endY := params.ConnParams.X2, params.ConnParams.Y2
+	if params.ConnParams.Style.Dot.StopAtDot {
+		endX, endY = params.DotX, params.DotY
+	}
+
+	points := [][2]float64{{startX, startY}}
+	isAligned := math.Abs(startX-endX) < 0.001 || math.Abs(startY-endY) < 0.001
+	if !isAligned {
+		if params.ConnParams.IsHorizontal {
+			// Leave the element vertically, cross over horizontally, reach the end vertically
+			midY := (startY + endY) / 2.0
+			points = append(points, [2]float64{startX, midY}, [2]float64{endX, midY})
+		} else {
+			// Leave the element horizontally, cross over vertically, reach the end horizontally
+			midX := (startX + endX) / 2.0
+			points = append(points, [2]float64{midX, startY}, [2]float64{midX, endY})
+		}
+	}
+	points = append(points, [2]float64{endX, endY})
+
+	pointStrs := make([]string, len(points))
+	length, prevX, prevY := 0.0, 0.0, 0.0
+	for i, p := range points {
+		x, y := params.SVG.jitterPoint(p[0], p[1])
+		pointStrs[i] = fmt.Sprintf("%s,%s", params.SVG.coord(x), params.SVG.coord(y))
+		params.Bounds.updatePoint(x, y)
+		if i > 0 {
+			length += math.Hypot(x-prevX, y-prevY)
+		}
+		prevX, prevY = x, y
+	}
+	fmt.Fprintf(params.SVG, `  <polyline points="%s" fill="none" stroke="%s" stroke-width="%s"%s%s%s />`,
+		strings.Join(pointStrs, " "), params.SVG.mapColor(params.DrawColor), params.SVG.coord(params.DrawWidth), params.DashArray, lineJoinAttr(params.ConnParams.LineJoin), connectorAnimationAttrs(params, length))
+	params.SVG.WriteString("\n")
+}
+
 // --- Refactored drawConnector function ---
 // Orchestrates drawing the connector by calling helper functions.
-func drawConnector(svg *bytes.Buffer, bounds *bounds, params ConnectorParams) {
+func drawConnector(svg *svgBuffer, bounds *bounds, params ConnectorParams) {
 	// 1. Calculate Style Attributes
-	connDrawColor, connDrawWidth, connDashArray := calculateConnectorStyleAttributes(params.Style, params.SegmentColor)
+	connDrawColor, connDrawWidth, connDashArray := calculateConnectorStyleAttributes(params.Style, params.SegmentColor, params.StrokeScale)
 
 	// 2. Calculate Direction Vectors (from axis X2,Y2 towards comment X1,Y1)
-	ux, uy, nx, ny, _ := calculateConnectorVectors(params.X1, params.Y1, params.X2, params.Y2)
+	ux, uy, nx, ny, lineLen := calculateConnectorVectors(params.X1, params.Y1, params.X2, params.Y2)
+	if lineLen < 0.001 {
+		return // Element sits on the axis point: no zero-length line or stray dot
+	}
 
 	// 3. Calculate Dot Position (relative to axis X2,Y2)
 	dotStyle := params.Style.Dot
@@ -659,7 +1568,7 @@ func drawConnector(svg *bytes.Buffer, bounds *bounds, params ConnectorParams) {
 
 // --- Helper function to draw the connector dot ---
 // Update parameters: Pass calculated dot center (dotX, dotY) and reference points for arrow logic (params includes P1x/y, P2x/y)
-func drawConnectorDot(svg *bytes.Buffer, bounds *bounds, params ConnectorDotParams, dotX, dotY float64) {
+func drawConnectorDot(svg *svgBuffer, bounds *bounds, params ConnectorDotParams, dotX, dotY float64) {
 	// Check if the dot style itself is visible/valid (Line visibility checked before calling drawConnector)
 	if !params.DotStyle.Visible || params.DotStyle.Shape == "none" || params.DotStyle.Size <= 0 {
 		return
@@ -672,6 +1581,8 @@ func drawConnectorDot(svg *bytes.Buffer, bounds *bounds, params ConnectorDotPara
 		dotColor = params.DefaultColor // Default to connector color
 	}
 
+	dotColor = svg.mapColor(dotColor)
+
 	// Dot position (dotX, dotY) is pre-calculated
 
 	// Arrow logic uses reference points from params (P1x/y, P2x/y)
@@ -688,14 +1599,21 @@ func drawConnectorDot(svg *bytes.Buffer, bounds *bounds, params ConnectorDotPara
 
 	switch params.DotStyle.Shape {
 	case "circle":
-		fmt.Fprintf(svg, `  <circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s"/>\n`,
-			dotX, dotY, halfDotSize, dotColor)
+		fmt.Fprintf(svg, `  <circle cx="%s" cy="%s" r="%s" fill="%s"/>\n`,
+			svg.coord(dotX), svg.coord(dotY), svg.coord(halfDotSize), dotColor)
 	case "square":
 		rectX := dotX - halfDotSize
 		rectY := dotY - halfDotSize
-		fmt.Fprintf(svg, `  <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s"/>\n`,
-			rectX, rectY, dotSize, dotSize, dotColor)
+		fmt.Fprintf(svg, `  <rect x="%s" y="%s" width="%s" height="%s" fill="%s"/>\n`,
+			svg.coord(rectX), svg.coord(rectY), svg.coord(dotSize), svg.coord(dotSize), dotColor)
 	case "arrow":
+		arrowRatio := params.DotStyle.ArrowRatio
+		if arrowRatio == 0 {
+			arrowRatio = defaultArrowRatio
+		} else if arrowRatio < minArrowRatio {
+			svg.warnf("invalid_option", "dot arrow_ratio %.2f is too small, using %.2f", arrowRatio, minArrowRatio)
+			arrowRatio = minArrowRatio
+		}
 		var p1xArrow, p1yArrow, p2xArrow, p2yArrow, tipX, tipY float64
 		// Arrow points towards the axis (determined by CrossAxisDir)
 		if params.IsHorizontal {
@@ -704,27 +1622,54 @@ func drawConnectorDot(svg *bytes.Buffer, bounds *bounds, params ConnectorDotPara
 			p2xArrow, p2yArrow = dotX+halfDotSize, dotY
 			// Tip points vertically towards axis
 			tipX = dotX
-			tipY = dotY - params.CrossAxisDir*halfDotSize*1.2
+			tipY = dotY - params.CrossAxisDir*halfDotSize*arrowRatio
 		} else { // Vertical timeline
 			// Base is vertical, relative to dotX, dotY
 			p1xArrow, p1yArrow = dotX, dotY-halfDotSize
 			p2xArrow, p2yArrow = dotX, dotY+halfDotSize
 			// Tip points horizontally towards axis
-			tipX = dotX - params.CrossAxisDir*halfDotSize*1.2
+			tipX = dotX - params.CrossAxisDir*halfDotSize*arrowRatio
 			tipY = dotY
 		}
-		points := fmt.Sprintf("%.2f,%.2f %.2f,%.2f %.2f,%.2f", p1xArrow, p1yArrow, p2xArrow, p2yArrow, tipX, tipY)
+		points := fmt.Sprintf("%s,%s %s,%s %s,%s", svg.coord(p1xArrow), svg.coord(p1yArrow), svg.coord(p2xArrow), svg.coord(p2yArrow), svg.coord(tipX), svg.coord(tipY))
 		fmt.Fprintf(svg, `  <polygon points="%s" fill="%s"/>\n`, points, dotColor)
+		bounds.updatePoint(tipX, tipY) // A long tip reaches past the dot square
 	}
 	// Update bounds for the dot itself
 	bounds.updateRect(dotX-halfDotSize, dotY-halfDotSize, dotSize, dotSize)
 }
 
-// Draw the year element with optional shape and link
-func drawYearElement(svg *bytes.Buffer, bounds *bounds, entry TimelineEntry,
-	yearStyle YearTextStyle, centerX, centerY float64) {
+// Draw the year element with optional shape and link. elementBounds is grown by the year text;
+// the returned area also covers the shape, for image map link areas.
+func drawYearElement(svg *svgBuffer, elementBounds *bounds, entry TimelineEntry,
+	yearStyle YearTextStyle, centerX, centerY float64, baselineCompat bool) bounds {
+	var area bounds
 	yearStr := entry.Period
-	yearWidth, yearHeight := estimateTextSVGWidth(yearStr, yearStyle.Font), getEstimatedHeight(yearStyle.Font)
+	yearLines := strings.Split(yearStr, "\n") // Multi-line periods are stacked as tspans
+	if yearStyle.AutoFitText {
+		yearStyle.Font.FontSize = fitYearFontSize(yearLines, yearStyle)
+	}
+	lineGap := float64(yearStyle.Font.FontSize) * getLineHeightFactor(yearStyle.LineHeight)
+	longestLine := ""
+	yearWidth := 0.0
+	for _, line := range yearLines {
+		if lineWidth := estimateTextSVGWidth(line, yearStyle.Font); lineWidth >= yearWidth {
+			yearWidth, longestLine = lineWidth, line
+		}
+	}
+	yearHeight := getEstimatedHeight(yearStyle.Font) + lineGap*float64(len(yearLines)-1)
+
+	// A subtitle goes below the period; the two are centered together and the shape grows to fit
+	textCenterY := centerY
+	blockWidth, blockHeight := yearWidth, yearHeight
+	var subtitleWidth, subtitleHeight float64
+	if entry.Subtitle != "" {
+		subtitleWidth = estimateTextSVGWidth(entry.Subtitle, yearStyle.SubtitleFont)
+		subtitleHeight = getEstimatedHeight(yearStyle.SubtitleFont)
+		blockWidth = math.Max(yearWidth, subtitleWidth)
+		blockHeight = yearHeight + subtitleGap + subtitleHeight
+		textCenterY = centerY - (subtitleGap+subtitleHeight)/2.0
+	}
 
 	// --- Link Wrapper (around Year element) ---
 	if entry.Link != "" {
@@ -732,10 +1677,17 @@ func drawYearElement(svg *bytes.Buffer, bounds *bounds, entry TimelineEntry,
 		svg.WriteString("  " + linkOpenTag + "\n")
 	}
 
+	// A rotated year turns its shape and text together around the center
+	rotate := yearStyle.Rotate != nil && math.Mod(*yearStyle.Rotate, 360) != 0
+	if rotate {
+		fmt.Fprintf(svg, `  <g transform="rotate(%s, %s, %s)">`, svg.coord(*yearStyle.Rotate), svg.coord(centerX), svg.coord(centerY))
+		svg.WriteString("\n")
+	}
+
 	// Draw background shape
 	shapeType, shapeParams, err := parseShapeString(yearStyle.Shape)
 	if err != nil {
-		log.Printf("Warning: Error parsing shape string \"%s\" for year \"%s\": %v. Skipping shape.",
+		svg.warnf("invalid_shape", "Error parsing shape string \"%s\" for year \"%s\": %v. Skipping shape.",
 			yearStyle.Shape, yearStr, err)
 		shapeType = "none"
 	}
@@ -745,82 +1697,133 @@ func drawYearElement(svg *bytes.Buffer, bounds *bounds, entry TimelineEntry,
 		ShapeParams: shapeParams,
 		CenterX:     centerX,
 		CenterY:     centerY,
-		TextWidth:   yearWidth,
-		TextHeight:  yearHeight,
+		TextWidth:   blockWidth,
+		TextHeight:  blockHeight,
+		FitText:     entry.Subtitle != "",
 		YearStyle:   yearStyle,
+		Area:        &area,
 	})
 
-	// --- DEBUG LOGGING START ---
-	// // log.Printf("DEBUG drawYearElement (%s): CenterX=%.2f, CenterY=%.2f, Color=%s, Size=%d, Family=%s",
-	// // 	yearStr, centerX, centerY, yearStyle.TextColor, yearStyle.Font.FontSize, yearStyle.Font.FontFamily)
-	// --- DEBUG LOGGING END ---
+	debugf("drawYearElement (%s): CenterX=%.2f, CenterY=%.2f, Color=%s, Size=%d, Family=%s",
+		yearStr, centerX, centerY, yearStyle.TextColor, yearStyle.Font.FontSize, yearStyle.Font.FontFamily)
 
-	// Draw the year text
-	fmt.Fprintf(svg, `    <text x="%.2f" y="%.2f" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s" dominant-baseline="middle" text-anchor="middle">`,
-		centerX, centerY, yearStyle.Font.FontFamily, yearStyle.Font.FontSize,
-		yearStyle.Font.FontWeight, yearStyle.Font.FontStyle, yearStyle.TextColor)
-	svg.WriteString(escapeXML(yearStr))
+	// Draw the year text. In baseline compat mode the text sits on its alphabetic baseline,
+	// moved down by half the ascent so it centers even where dominant-baseline is ignored
+	textY := textCenterY
+	baselineAttr := ` dominant-baseline="middle"`
+	if baselineCompat {
+		textY += baselineCompatOffset(yearStyle.Font.FontSize)
+		baselineAttr = ""
+	}
+	fmt.Fprintf(svg, `    <text x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s"%s text-anchor="middle">`,
+		svg.coord(centerX), svg.coord(textY), cssFontFamily(yearStyle.Font.FontFamily), yearStyle.Font.FontSize,
+		yearStyle.Font.FontWeight, yearStyle.Font.FontStyle, svg.mapColor(yearStyle.TextColor), baselineAttr)
+	if len(yearLines) == 1 {
+		svg.WriteString(escapeXML(yearStr))
+	} else {
+		// Center the stack of lines on centerY, each following line lineGap below the previous
+		firstLineY := textY - lineGap*float64(len(yearLines)-1)/2.0
+		for j, line := range yearLines {
+			if j == 0 {
+				fmt.Fprintf(svg, `<tspan x="%s" y="%s">%s</tspan>`, svg.coord(centerX), svg.coord(firstLineY), escapeXML(line))
+			} else {
+				fmt.Fprintf(svg, `<tspan x="%s" dy="%s">%s</tspan>`, svg.coord(centerX), svg.coord(lineGap), escapeXML(line))
+			}
+		}
+	}
 	svg.WriteString(`</text>`)
 		svg.WriteString("\n")
+	if entry.Subtitle != "" {
+		subtitleY := centerY + blockHeight/2.0 - subtitleHeight/2.0
+		subtitleBaseline := ` dominant-baseline="middle"`
+		if baselineCompat {
+			subtitleY += baselineCompatOffset(yearStyle.SubtitleFont.FontSize)
+			subtitleBaseline = ""
+		}
+		fmt.Fprintf(svg, `    <text class="timeline-subtitle" x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s"%s text-anchor="middle">%s</text>`,
+			svg.coord(centerX), svg.coord(subtitleY), cssFontFamily(yearStyle.SubtitleFont.FontFamily), yearStyle.SubtitleFont.FontSize,
+			yearStyle.SubtitleFont.FontWeight, yearStyle.SubtitleFont.FontStyle, svg.mapColor(yearStyle.TextColor), subtitleBaseline, escapeXML(entry.Subtitle))
+		svg.WriteString("\n")
+	}
 
 	// Update bounds for text
-	estWidth := math.Min(float64(len(yearStr))*float64(yearStyle.Font.FontSize)*0.7, 200)
-	estHeight := float64(yearStyle.Font.FontSize)
+	estWidth := math.Min(float64(len(longestLine))*float64(yearStyle.Font.FontSize)*0.7, 200)
+	estHeight := float64(yearStyle.Font.FontSize) + lineGap*float64(len(yearLines)-1)
 	boundsX := centerX - estWidth/2.0
-	boundsY := centerY - estHeight/2.0
-	bounds.updateRect(boundsX, boundsY, estWidth, estHeight)
+	boundsY := textCenterY - estHeight/2.0
+	var textBounds bounds
+	textBounds.updateRect(boundsX, boundsY, estWidth, estHeight)
+	textBounds.updateRect(centerX-subtitleWidth/2.0, centerY+blockHeight/2.0-subtitleHeight, subtitleWidth, subtitleHeight)
+	area.merge(textBounds)
+	if rotate {
+		svg.WriteString("  </g>\n")
+		textBounds = textBounds.rotated(centerX, centerY, *yearStyle.Rotate)
+		area = area.rotated(centerX, centerY, *yearStyle.Rotate)
+	}
+	elementBounds.merge(textBounds)
 
 	// Close link wrapper
 	if entry.Link != "" {
 		svg.WriteString("  </a>\n")
 	}
+	return area
 }
 
 // Update the drawYearShape function to use the parameter struct
-func drawYearShape(svg *bytes.Buffer, params YearShapeParams) {
+func drawYearShape(svg *svgBuffer, params YearShapeParams) {
 	switch params.ShapeType {
 	case "circle":
 		radius := params.ShapeParams["r"]
+		textRadius := math.Max(params.TextWidth/2.0, params.TextHeight/2.0) + yearShapeAutoPadding
 		if radius < 0 { // Handle 'auto' radius
 			// Calculate radius based on text dimensions + default internal padding
-			const defaultAutoPadding = 4.0
-			textHalfWidth := params.TextWidth / 2.0
-			textHalfHeight := params.TextHeight / 2.0
-			radius = math.Max(textHalfWidth, textHalfHeight) + defaultAutoPadding
+			radius = textRadius
 			// Ensure minimum reasonable radius if text is tiny
-			if radius < defaultAutoPadding*1.5 {
-				radius = defaultAutoPadding * 1.5
+			if radius < yearShapeAutoPadding*1.5 {
+				radius = yearShapeAutoPadding * 1.5
 			}
 		} else if radius == 0 {
 			// If radius is explicitly 0, draw nothing
 			return
+		} else if params.FitText {
+			radius = math.Max(radius, textRadius)
 		}
 		// Draw the circle
-		fmt.Fprintf(svg, `  <circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s" stroke="%s" stroke-width="%.2f"/>`,
-			params.CenterX, params.CenterY, radius,
-			params.YearStyle.FillColor, params.YearStyle.BorderColor, params.YearStyle.BorderWidth)
+		fmt.Fprintf(svg, `  <circle cx="%s" cy="%s" r="%s" fill="%s"%s stroke="%s" stroke-width="%s"/>`,
+			svg.coord(params.CenterX), svg.coord(params.CenterY), svg.coord(radius),
+			svg.resolveFill(params.YearStyle.FillColor), svg.fillOpacityAttr(params.YearStyle.FillOpacity), svg.mapColor(params.YearStyle.BorderColor), svg.coord(params.YearStyle.BorderWidth))
 		svg.WriteString("\n")
+		if params.Area != nil {
+			params.Area.updateRect(params.CenterX-radius, params.CenterY-radius, 2*radius, 2*radius)
+		}
 
 	case "rectangle":
 		rectW := params.ShapeParams["w"]
 		rectH := params.ShapeParams["h"]
+		if params.FitText && rectW > 0 && rectH > 0 {
+			rectW = math.Max(rectW, params.TextWidth+2*yearShapeAutoPadding)
+			rectH = math.Max(rectH, params.TextHeight+2*yearShapeAutoPadding)
+		}
 		if rectW > 0 && rectH > 0 {
 			rectX := params.CenterX - rectW/2.0
 			rectY := params.CenterY - rectH/2.0
-			fmt.Fprintf(svg, `  <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s" stroke="%s" stroke-width="%.2f"/>`,
-				rectX, rectY, rectW, rectH,
-				params.YearStyle.FillColor, params.YearStyle.BorderColor, params.YearStyle.BorderWidth)
+			fmt.Fprintf(svg, `  <rect x="%s" y="%s" width="%s" height="%s" fill="%s"%s stroke="%s" stroke-width="%s"/>`,
+				svg.coord(rectX), svg.coord(rectY), svg.coord(rectW), svg.coord(rectH),
+				svg.resolveFill(params.YearStyle.FillColor), svg.fillOpacityAttr(params.YearStyle.FillOpacity), svg.mapColor(params.YearStyle.BorderColor), svg.coord(params.YearStyle.BorderWidth))
 			svg.WriteString("\n")
+			if params.Area != nil {
+				params.Area.updateRect(rectX, rectY, rectW, rectH)
+			}
 		}
 	}
 }
 
 // Calculate the layout for a comment block
-func calculateCommentBlockLayout(params CommentParams) CommentBlockLayout {
+func calculateCommentBlockLayout(diag *diagnosticLog, params CommentParams) CommentBlockLayout {
 	layout := CommentBlockLayout{}
 
 	// --- Parse Padding ---
-	padTop, padRight, padBottom, padLeft := parsePadding(params.Style.Padding)
+	padTop, padRight, padBottom, padLeft := parsePadding(diag, params.Style.Padding)
 	layout.padTop, layout.padRight, layout.padBottom, layout.padLeft = padTop, padRight, padBottom, padLeft
 
 	// --- Calculate Vertical Positions & Estimated Heights (Relative to Block Top - Inside Padding) ---
@@ -858,7 +1861,7 @@ func calculateCommentBlockLayout(params CommentParams) CommentBlockLayout {
 	bodyRelY = currentRelY
 
 	// Estimate foreignObject height (content only, no padding)
-	layout.foHeight = calculateForeignObjectHeight(params.BodyText, params.ImageURL)
+	layout.foHeight = calculateForeignObjectHeight(params.BodyText, params.ImageURL, params.Style.ImagePosition)
 
 	// --- Calculate Visual Block Dimensions ---
 	requiredContentWidth := estTitleWidth // Base width on title/line
@@ -866,11 +1869,25 @@ func calculateCommentBlockLayout(params CommentParams) CommentBlockLayout {
 	// Check for fixed block width from style
 	if params.Style.BlockWidth != nil && *params.Style.BlockWidth > 0 {
 		layout.contentWidth = *params.Style.BlockWidth // Use specified width for content
+	} else if params.Style.MaxBlockWidth != nil && *params.Style.MaxBlockWidth > 0 {
+		// Shrink to the natural width of the title and unwrapped body, wrapping the body at the max
+		naturalWidth := math.Max(requiredContentWidth, estimateBodyTextWidth(params.BodyText, params.Style.Font))
+		layout.contentWidth = math.Min(naturalWidth, *params.Style.MaxBlockWidth)
+		wrappedHeight := float64(estimateWrappedLineCount(params.BodyText, params.Style.Font, layout.contentWidth)) * commentLineHeight(params.Style)
+		layout.foHeight = math.Max(layout.foHeight, wrappedHeight)
 	} else {
 		// Fallback: Use title/line width as content width (current behavior)
 		layout.contentWidth = requiredContentWidth
 	}
 
+	// layout.fit_comments: narrow the block to the space beside the entry, a fixed width is kept
+	if fitContent := params.FitWidth - padLeft - padRight; params.FitWidth > 0 && params.Style.BlockWidth == nil && layout.contentWidth > fitContent {
+		debugf("comment '%s': fit_comments narrows the content from %.2f to %.2f", truncateForLog(params.TitleText), layout.contentWidth, fitContent)
+		layout.contentWidth = fitContent
+		wrappedHeight := float64(estimateWrappedLineCount(params.BodyText, params.Style.Font, layout.contentWidth)) * commentLineHeight(params.Style)
+		layout.foHeight = math.Max(layout.foHeight, wrappedHeight)
+	}
+
 	// Ensure content width is not negative
 	if layout.contentWidth < 0 {
 		layout.contentWidth = 0
@@ -879,13 +1896,51 @@ func calculateCommentBlockLayout(params CommentParams) CommentBlockLayout {
 	// Calculate visual block width including padding
 	layout.visualBlockWidth = layout.contentWidth + padLeft + padRight
 
+	// Text mode bodies are laid out line by line, a measured height (precise_image_layout)
+	// replaces the estimates above
+	if params.TextBody && layout.foHeight > 0 {
+		layout.foHeight = commentTextBodyHeight(params, layout.contentWidth)
+	} else if params.BodyHeight > 0 && layout.foHeight > 0 {
+		layout.foHeight = params.BodyHeight
+	}
+
+	// Fixed block height: the body gets whatever space remains and is clipped if it overflows
+	if params.Style.BlockHeight != nil && *params.Style.BlockHeight > 0 {
+		layout.isFixedHeight = true
+		layout.foHeight = math.Max(*params.Style.BlockHeight-currentRelY-padBottom, 0)
+	}
+
+	// Title below: stack the body first, then the title line as separator, then the title
+	if params.Style.TitlePosition == "below" {
+		bodyRelY = padTop
+		belowRelY := padTop + layout.foHeight
+		if estLineHeight > 0 {
+			titleLineRelY = belowRelY + titleLine.Margin
+			belowRelY += titleLine.Margin + estLineHeight + titleLine.Margin
+		}
+		titleTextRelY = belowRelY
+	}
+
 	// Calculate visual block height (unchanged)
 	layout.visualBlockHeight = currentRelY + layout.foHeight + padBottom // Includes top padding, content, bottom padding
 
 	// --- Calculate Block Position (Top-Left Corner of Visual Block) ---
-	layout.blockX, layout.blockY = calculateBlockPosition(params.AnchorX, params.AnchorY,
-		layout.visualBlockWidth, layout.visualBlockHeight,
-		params.CrossAxisDir, params.IsHorizontal)
+	layout.anchorX, layout.anchorY = params.AnchorX, params.AnchorY
+	if params.Inline {
+		// Centered on the anchor (the axis point); the same box for any axis angle
+		layout.blockX = params.AnchorX - layout.visualBlockWidth/2.0
+		layout.blockY = params.AnchorY - layout.visualBlockHeight/2.0
+	} else if isAngledAxis(params.AxisAngle) {
+		layout.isRotated = true
+		_, _, crossX, crossY := axisFrame(params.IsHorizontal, params.AxisAngle)
+		layout.blockX, layout.blockY = calculateRotatedBlockPosition(params.AnchorX, params.AnchorY,
+			layout.visualBlockWidth, layout.visualBlockHeight,
+			crossX*params.CrossAxisDir, crossY*params.CrossAxisDir)
+	} else {
+		layout.blockX, layout.blockY = calculateBlockPosition(params.AnchorX, params.AnchorY,
+			layout.visualBlockWidth, layout.visualBlockHeight,
+			params.CrossAxisDir, params.IsHorizontal)
+	}
 
 	// --- Calculate Absolute Content Positions (relative to SVG origin) ---
 	layout.contentCenterX = layout.blockX + padLeft + layout.contentWidth/2.0
@@ -897,13 +1952,24 @@ func calculateCommentBlockLayout(params CommentParams) CommentBlockLayout {
 	return layout
 }
 
+// commentLineHeight returns the estimated height of one body text line of a comment.
+func commentLineHeight(style CommentTextStyle) float64 {
+	if style.LineHeight != nil && *style.LineHeight > 0 {
+		return float64(style.Font.FontSize) * *style.LineHeight
+	}
+	return getEstimatedHeight(style.Font)
+}
+
 // Calculate height needed for foreignObject content
-func calculateForeignObjectHeight(bodyText, imageURL string) float64 {
+func calculateForeignObjectHeight(bodyText, imageURL, imagePosition string) float64 {
 	foHeight := foreignObjectHeightEstimate
 	if bodyText == "" && imageURL == "" {
 		foHeight = 0
 	} else if bodyText == "" && imageURL != "" {
 		foHeight = imagePlaceholderHeight + 10 // Rough estimate for image only
+	} else if imagePosition == "left" || imagePosition == "right" {
+		// Side by side: the taller of the image and the (narrower, so longer) text column
+		foHeight = math.Max(foreignObjectHeightEstimate*sideImageTextGrowth, imagePlaceholderHeight+10)
 	}
 	return foHeight
 }
@@ -935,30 +2001,87 @@ func calculateBlockPosition(anchorX, anchorY, blockWidth, totalHeight, crossAxis
 	return blockX, blockY
 }
 
+// Calculate the position of a comment block placed away from the anchor in direction (dirX, dirY).
+// The block is pushed out along that direction until its extent in it just reaches the anchor,
+// which reduces to calculateBlockPosition for axis-aligned directions.
+func calculateRotatedBlockPosition(anchorX, anchorY, blockWidth, totalHeight, dirX, dirY float64) (float64, float64) {
+	support := (math.Abs(dirX)*blockWidth + math.Abs(dirY)*totalHeight) / 2.0
+	centerX := anchorX + dirX*support
+	centerY := anchorY + dirY*support
+	return centerX - blockWidth/2.0, centerY - totalHeight/2.0
+}
+
+// commentTailPoints returns the base corners and tip of a comment tail of the given size,
+// centered on the box edge facing the axis and pointing away from the box.
+func commentTailPoints(layout CommentBlockLayout, crossAxisDir float64, isHorizontal bool, size float64) (base1, tip, base2 AxisPoint) {
+	edgeX, edgeY := calculateCommentEdgePoint(layout, crossAxisDir, isHorizontal, 0.5) // The tail stays centered
+	centerX := layout.blockX + layout.visualBlockWidth/2.0
+	centerY := layout.blockY + layout.visualBlockHeight/2.0
+	// Outward direction: from the box center through the edge point (perpendicular for edge centers)
+	dirX, dirY := edgeX-centerX, edgeY-centerY
+	if length := math.Hypot(dirX, dirY); length > 0.001 {
+		dirX, dirY = dirX/length, dirY/length
+	} else {
+		dirX, dirY = 0, 1
+	}
+	half := size / 2.0
+	base1 = AxisPoint{X: edgeX - dirY*half, Y: edgeY + dirX*half}
+	base2 = AxisPoint{X: edgeX + dirY*half, Y: edgeY - dirX*half}
+	tip = AxisPoint{X: edgeX + dirX*size, Y: edgeY + dirY*size}
+	return base1, tip, base2
+}
+
+// drawCommentTail draws the speech-bubble pointer of a boxed comment: a triangle filled like
+// the box whose two sides are stroked like its border, so the box edge under it is hidden.
+func drawCommentTail(svg *svgBuffer, bounds *bounds, style CommentTextStyle, layout CommentBlockLayout, crossAxisDir float64, isHorizontal bool, strokeScale float64) {
+	size := style.TailSize
+	if size <= 0 {
+		size = defaultCommentTailSize
+	}
+	base1, tip, base2 := commentTailPoints(layout, crossAxisDir, isHorizontal, size)
+	borderWidth := float64(style.BorderWidth) * strokeScale
+
+	// Push the filled base into the box by the border width so it covers the border segment
+	inX, inY := (base1.X+base2.X)/2.0-tip.X, (base1.Y+base2.Y)/2.0-tip.Y
+	inX, inY = inX/size*borderWidth, inY/size*borderWidth
+	if fill := svg.resolveFill(style.FillColor); fill != "" && fill != "none" {
+		fmt.Fprintf(svg, `    <polygon points="%s,%s %s,%s %s,%s %s,%s %s,%s" fill="%s"%s />`,
+			svg.coord(base1.X+inX), svg.coord(base1.Y+inY), svg.coord(base1.X), svg.coord(base1.Y), svg.coord(tip.X), svg.coord(tip.Y),
+			svg.coord(base2.X), svg.coord(base2.Y), svg.coord(base2.X+inX), svg.coord(base2.Y+inY), fill, svg.fillOpacityAttr(style.FillOpacity))
+		svg.WriteString("\n")
+	}
+	if style.BorderColor != "" && borderWidth > 0 {
+		fmt.Fprintf(svg, `    <polyline points="%s,%s %s,%s %s,%s" fill="none" stroke="%s" stroke-width="%s"%s />`,
+			svg.coord(base1.X), svg.coord(base1.Y), svg.coord(tip.X), svg.coord(tip.Y), svg.coord(base2.X), svg.coord(base2.Y),
+			svg.mapColor(style.BorderColor), svg.coord(borderWidth), getStrokeDashArray(style.BorderStyle, int(borderWidth)))
+		svg.WriteString("\n")
+	}
+	bounds.updatePoint(tip.X, tip.Y)
+}
+
 // Draw the background rectangle for a comment
-func drawCommentBackground(svg *bytes.Buffer, bounds *bounds, style CommentTextStyle, layout CommentBlockLayout) {
+func drawCommentBackground(svg *svgBuffer, bounds *bounds, style CommentTextStyle, layout CommentBlockLayout, strokeScale float64) {
 	if style.Shape == "rectangle" {
 		// Use the calculated visual block dimensions and position
-		rectX := layout.blockX
-		rectY := layout.blockY
+		rectX, rectY := svg.jitterPoint(layout.blockX, layout.blockY) // Hand-drawn offset, 0 unless layout.jitter
 		rectW := layout.visualBlockWidth
 		rectH := layout.visualBlockHeight
-		rectFill := style.FillColor
+		rectFill := svg.resolveFill(style.FillColor)
 		if rectFill == "" {
 			rectFill = "none"
 		}
-		rectBorderColor := style.BorderColor
+		rectBorderColor := svg.mapColor(style.BorderColor)
 		if rectBorderColor == "" {
 			rectBorderColor = "none"
 		}
-		rectBorderWidth := float64(style.BorderWidth)
+		rectBorderWidth := float64(style.BorderWidth) * strokeScale
 		if rectBorderWidth < 0 {
 			rectBorderWidth = 0
 		}
 		rectBorderStyle := style.BorderStyle
 		rectBorderDashArray := getStrokeDashArray(rectBorderStyle, int(rectBorderWidth))
-		fmt.Fprintf(svg, `    <rect x="%.2f" y="%.2f" width="%.2f" height="%.2f" fill="%s" stroke="%s" stroke-width="%.2f"%s rx="3" ry="3"/>`,
-			rectX, rectY, rectW, rectH, rectFill, rectBorderColor, rectBorderWidth, rectBorderDashArray)
+		fmt.Fprintf(svg, `    <rect x="%s" y="%s" width="%s" height="%s" fill="%s"%s stroke="%s" stroke-width="%s"%s rx="3" ry="3"/>`,
+			svg.coord(rectX), svg.coord(rectY), svg.coord(rectW), svg.coord(rectH), rectFill, svg.fillOpacityAttr(style.FillOpacity), rectBorderColor, svg.coord(rectBorderWidth), rectBorderDashArray)
 		svg.WriteString("\n")
 		bounds.updateRect(rectX, rectY, rectW, rectH)
 	}
@@ -977,19 +2100,93 @@ type CommentBodyParams struct {
 	BodyFont  FontStyle
 	TextColor string
 	Layout    CommentBlockLayout
+	ClipID    string // id of the clipPath used when the block has a fixed height
 }
 
 // Update drawCommentTitle to use the parameter struct
-func drawCommentTitle(svg *bytes.Buffer, bounds *bounds, params CommentTitleParams) {
-	fmt.Fprintf(svg, `    <text x="%.2f" y="%.2f" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s" text-anchor="middle" dominant-baseline="hanging">`,
-		params.Layout.contentCenterX, params.Layout.titleTextAbsY, params.TitleFont.FontFamily, params.TitleFont.FontSize,
-		params.TitleFont.FontWeight, params.TitleFont.FontStyle, params.TitleColor)
+func drawCommentTitle(svg *svgBuffer, bounds *bounds, params CommentTitleParams) {
+	fmt.Fprintf(svg, `    <text x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s" text-anchor="middle" dominant-baseline="hanging">`,
+		svg.coord(params.Layout.contentCenterX), svg.coord(params.Layout.titleTextAbsY), cssFontFamily(params.TitleFont.FontFamily), params.TitleFont.FontSize,
+		params.TitleFont.FontWeight, params.TitleFont.FontStyle, svg.mapColor(params.TitleColor))
 	svg.WriteString(escapeXML(params.TitleText))
 		svg.WriteString(`</text>`)
 		svg.WriteString("\n")
 	bounds.updatePoint(params.Layout.contentCenterX, params.Layout.titleTextAbsY) // Approximate bounds update
 }
 
+// embedImageSource returns src as a data URI when it is a local file path; URLs and data
+// URIs are returned unchanged. It returns "" (after a warning) if the file can't be read.
+// The render caches the result, so an image used by several entries is read (or reported
+// missing) once per render.
+func (c *renderContext) embedImageSource(src string) string {
+	c.warnLimitedImageSupport(src)
+	if isRemoteImage(src) {
+		return src
+	}
+	if dataURI, ok := c.embeddedImage(src); ok {
+		return dataURI
+	}
+	log.Printf("Attempting to read and embed local image: %s", src)
+	imgData, err := os.ReadFile(src)
+	if err != nil {
+		c.warnf("missing_image", "Could not read image file '%s': %v. Skipping image.", src, err)
+		c.cacheImage(src, "")
+		return ""
+	}
+	dataURI := fmt.Sprintf("data:%s;base64,%s", getMimeType(src), base64.StdEncoding.EncodeToString(imgData))
+	log.Printf("Successfully embedded image '%s' as data URI.", src)
+	c.cacheImage(src, dataURI)
+	return dataURI
+}
+
+// embeddedImage returns the data URI the render already made of the local image src ("" for
+// an unreadable one); ok is false if src wasn't embedded yet.
+func (c *renderContext) embeddedImage(src string) (string, bool) {
+	if c == nil {
+		return "", false
+	}
+	dataURI, ok := c.images[src]
+	return dataURI, ok
+}
+
+// cacheImage records the data URI of the local image src for the rest of the render.
+func (c *renderContext) cacheImage(src, dataURI string) {
+	if c == nil {
+		return
+	}
+	if c.images == nil {
+		c.images = map[string]string{}
+	}
+	c.images[src] = dataURI
+}
+
+// limitedSupportImageTypes render in browsers (and so in PNG/JPG output) but not in some
+// standalone SVG renderers such as librsvg or older Inkscape versions.
+var limitedSupportImageTypes = []string{"image/webp", "image/avif"}
+
+// warnLimitedImageSupport warns when src is a WebP or AVIF image (local files only the first
+// time, before they are cached).
+func (c *renderContext) warnLimitedImageSupport(src string) {
+	if _, seen := c.embeddedImage(src); seen {
+		return
+	}
+	mimeType := getMimeType(strings.SplitN(src, "?", 2)[0]) // Ignore URL query strings
+	if strings.HasPrefix(src, "data:") {
+		mimeType, _, _ = strings.Cut(strings.TrimPrefix(src, "data:"), ";")
+	}
+	if slices.Contains(limitedSupportImageTypes, mimeType) {
+		c.warnf("image_format_support", "image '%s' is %s, which browsers render but some SVG viewers outside a browser don't.", truncateForLog(src), mimeType)
+	}
+}
+
+// truncateForLog shortens long values such as data URIs for log messages.
+func truncateForLog(s string) string {
+	if len(s) > 60 {
+		return s[:60] + "..."
+	}
+	return s
+}
+
 // Helper function to get MIME type from file extension
 func getMimeType(filename string) string {
 	ext := strings.ToLower(filepath.Ext(filename))
@@ -1004,6 +2201,10 @@ func getMimeType(filename string) string {
 			return "image/gif"
 		case ".svg":
 			return "image/svg+xml"
+		case ".webp":
+			return "image/webp"
+		case ".avif":
+			return "image/avif"
 		// Add more common types if needed
 		default:
 			return "application/octet-stream" // Generic fallback
@@ -1013,61 +2214,62 @@ func getMimeType(filename string) string {
 }
 
 // Update drawCommentBody to use the parameter struct and embed local images
-func drawCommentBody(svg *bytes.Buffer, bounds *bounds, params CommentBodyParams) {
+func drawCommentBody(svg *svgBuffer, bounds *bounds, params CommentBodyParams) {
 	// Use the calculated content width for the foreignObject
 	contentWidth := params.Layout.contentWidth
 	bounds.updateRect(params.Layout.bodyAbsX, params.Layout.bodyAbsY, contentWidth, params.Layout.foHeight)
 
-	fmt.Fprintf(svg, `    <foreignObject x="%.2f" y="%.2f" width="%.2f" height="%.2f">`,
-		params.Layout.bodyAbsX, params.Layout.bodyAbsY, contentWidth, params.Layout.foHeight)
+	clipAttr := writeCommentBodyClip(svg, params)
+
+	fmt.Fprintf(svg, `    <foreignObject x="%s" y="%s" width="%s" height="%s"%s>`,
+		svg.coord(params.Layout.bodyAbsX), svg.coord(params.Layout.bodyAbsY), svg.coord(contentWidth), svg.coord(params.Layout.foHeight), clipAttr)
 		svg.WriteString("\n")
 		fmt.Fprintf(svg, `        <div xmlns="http://www.w3.org/1999/xhtml">`)
 
-		// Use text-align from style, default to center
-	textAlign := params.Params.Style.TextAlign
-		if textAlign == "" {
-			textAlign = "center"
-		}
+	// Use text-align from style, default to center ("auto" mirrors by side on vertical timelines)
+	textAlign := resolveCommentTextAlign(params.Params.Style.TextAlign, params.Params.IsHorizontal, params.Params.CrossAxisDir)
 
 	// Prepare style string outside Fprintf for clarity
 	bodyStyle := fmt.Sprintf("color:%s; font-family:%s; font-size:%dpx; font-weight:%s; font-style:%s; text-align:%s;",
-		params.TextColor, escapeXML(params.BodyFont.FontFamily), params.BodyFont.FontSize,
+		svg.mapColor(params.TextColor), cssFontFamily(params.BodyFont.FontFamily), params.BodyFont.FontSize,
 		escapeXML(params.BodyFont.FontWeight), escapeXML(params.BodyFont.FontStyle), textAlign)
+	if params.Params.Style.LineHeight != nil && *params.Params.Style.LineHeight > 0 {
+		bodyStyle += fmt.Sprintf(" line-height:%.2f;", *params.Params.Style.LineHeight)
+	}
 
 	fmt.Fprintf(svg, `<div class="comment-html-content" style="%s">`, bodyStyle)
 
+	imagePosition := resolveCommentImagePosition(svg.warnings(), params.Params.Style.ImagePosition)
+	imageTag := ""
 	if params.Params.ImageURL != "" {
-		imgSrc := params.Params.ImageURL
-		// Check if it's a likely file path (not URL or data URI)
-		if !strings.HasPrefix(imgSrc, "http://") && !strings.HasPrefix(imgSrc, "https://") && !strings.HasPrefix(imgSrc, "data:") {
-			log.Printf("Attempting to read and embed local image: %s", imgSrc)
-			imgData, err := os.ReadFile(imgSrc)
-			if err != nil {
-				log.Printf("Warning: Could not read image file '%s': %v. Skipping image.", imgSrc, err)
-				imgSrc = "" // Clear src if file read failed
-			} else {
-				mimeType := getMimeType(imgSrc)
-				imgSrc = fmt.Sprintf("data:%s;base64,%s", mimeType, base64.StdEncoding.EncodeToString(imgData))
-				log.Printf("Successfully embedded image '%s' as data URI.", params.Params.ImageURL)
-			}
-		}
+		imgSrc := svg.embedImageSource(params.Params.ImageURL) // Local files become data URIs
 
 		// Only output image tag if imgSrc is still valid
 		if imgSrc != "" {
-			fmt.Fprintf(svg, `<img src="%s" style="max-width: 100%%; height: auto; display: block; margin-bottom: 5px;" alt="Timeline image"/>`,
-				escapeXML(imgSrc)) // Escape the potentially long data URI? Probably not needed for src attribute.
-			svg.WriteString("\n")
+			imgAlt := params.Params.ImageAlt
+			if imgAlt == "" {
+				imgAlt = defaultImageAlt
+			}
+			imageTag = fmt.Sprintf(`<img src="%s" style="%s" alt="%s"/>`,
+				escapeXML(imgSrc), commentImageCSS(imagePosition), escapeXML(imgAlt)) + "\n"
 		}
 	}
 
+	// Floated (left/right) and top images precede the text; bottom images follow it
+	if imagePosition != "bottom" {
+		svg.WriteString(imageTag)
+	}
 	if params.Params.BodyText != "" {
-			// Basic markdown link support: [text](url)
-		re := regexp.MustCompile(`\[([^\]]+)\]\(([^\)]+)\)`) // Escaped brackets
-		formattedText := re.ReplaceAllString(params.Params.BodyText, `<a href="$2" target="_blank">$1</a>`)
+		formattedText := markdownLinkRegex.ReplaceAllString(params.Params.BodyText, `<a href="$2" target="_blank">$1</a>`)
 			formattedText = strings.ReplaceAll(formattedText, "\n", "<br />") // Handle newlines
 			svg.WriteString(formattedText)
 			svg.WriteString("\n")
 		}
+	if imagePosition == "bottom" {
+		svg.WriteString(imageTag)
+	} else if imageTag != "" && (imagePosition == "left" || imagePosition == "right") {
+		svg.WriteString(`<div style="clear: both;"></div>`) // Keep the float inside the content box
+	}
 
 		svg.WriteString(`</div></div>`)
 		svg.WriteString("\n")
@@ -1075,56 +2277,108 @@ func drawCommentBody(svg *bytes.Buffer, bounds *bounds, params CommentBodyParams
 		svg.WriteString("\n")
 	}
 
-// Assemble the final SVG document
-func assembleFinalSVG(svgBody bytes.Buffer, timelineBounds bounds, layoutPadding float64, globalFont *FontStyle) string {
-
-	// --- DEBUG LOGGING START ---
-	// log.Printf("--- Debug assembleFinalSVG ---")
-	// if timelineBounds.isSet {
-	// 	log.Printf("  Bounds: minX=%.2f, maxX=%.2f, minY=%.2f, maxY=%.2f",
-	// 		timelineBounds.minX, timelineBounds.maxX, timelineBounds.minY, timelineBounds.maxY)
-	// } else {
-	// 	log.Printf("  Bounds: Not set")
-	// }
-	// --- DEBUG LOGGING END ---
+// writeCommentBodyClip writes the clipPath cutting overflowing body content at the fixed
+// block height and returns the clip-path attribute using it ("" without a fixed height).
+func writeCommentBodyClip(svg *svgBuffer, params CommentBodyParams) string {
+	if !params.Layout.isFixedHeight {
+		return ""
+	}
+	fmt.Fprintf(svg, `    <defs><clipPath id="%s"><rect x="%s" y="%s" width="%s" height="%s"/></clipPath></defs>`,
+		escapeXML(params.ClipID), svg.coord(params.Layout.bodyAbsX), svg.coord(params.Layout.bodyAbsY), svg.coord(params.Layout.contentWidth), svg.coord(params.Layout.foHeight))
+	svg.WriteString("\n")
+	return fmt.Sprintf(` clip-path="url(#%s)"`, escapeXML(params.ClipID))
+}
+
+// drawCaption writes the footer caption centered below the current bounds and extends
+// the bounds by its height, so the canvas grows to fit it. Lines split on "\n".
+func drawCaption(svg *svgBuffer, timelineBounds *bounds, caption string, font FontStyle) {
+	centerX, top := 0.0, 0.0
+	if timelineBounds.isSet {
+		centerX = (timelineBounds.minX + timelineBounds.maxX) / 2.0
+		top = timelineBounds.maxY + captionGap
+	}
+	lineHeight := getEstimatedHeight(font)
+	lines := strings.Split(caption, "\n")
 
-	finalWidth := layoutPadding * 2
-	finalHeight := layoutPadding * 2
-	offsetX := layoutPadding - timelineBounds.minX
-	offsetY := layoutPadding - timelineBounds.minY
+	fmt.Fprintf(svg, `  <text class="timeline-caption" x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s" text-anchor="middle" dominant-baseline="hanging">`,
+		svg.coord(centerX), svg.coord(top), cssFontFamily(font.FontFamily), font.FontSize, escapeXML(font.FontWeight), escapeXML(font.FontStyle), svg.mapColor(defaultCaptionColor))
+	for i, line := range lines {
+		fmt.Fprintf(svg, `<tspan x="%s" y="%s">%s</tspan>`, svg.coord(centerX), svg.coord(top+float64(i)*lineHeight), captionSVGContent(line))
 
+		lineWidth := estimateTextSVGWidth(markdownLinkRegex.ReplaceAllString(line, "$1"), font)
+		timelineBounds.updateRect(centerX-lineWidth/2.0, top+float64(i)*lineHeight, lineWidth, lineHeight)
+	}
+	svg.WriteString("</text>\n")
+}
+
+// captionSVGContent escapes a caption line, turning markdown links into SVG <a> elements.
+func captionSVGContent(line string) string {
+	var sb strings.Builder
+	last := 0
+	for _, m := range markdownLinkRegex.FindAllStringSubmatchIndex(line, -1) {
+		sb.WriteString(escapeXML(line[last:m[0]]))
+		fmt.Fprintf(&sb, `<a href="%s" target="_blank"><tspan text-decoration="underline">%s</tspan></a>`,
+			escapeXML(line[m[4]:m[5]]), escapeXML(line[m[2]:m[3]]))
+		last = m[1]
+	}
+	sb.WriteString(escapeXML(line[last:]))
+	return sb.String()
+}
+
+// Assemble the final SVG document
+func assembleFinalSVG(svgBody *svgBuffer, timelineBounds bounds, config LayoutConfig, globalFont *FontStyle) string {
 	if timelineBounds.isSet {
-		finalWidth += timelineBounds.maxX - timelineBounds.minX
-		finalHeight += timelineBounds.maxY - timelineBounds.minY
+		debugf("assembleFinalSVG bounds: minX=%.2f, maxX=%.2f, minY=%.2f, maxY=%.2f",
+			timelineBounds.minX, timelineBounds.maxX, timelineBounds.minY, timelineBounds.maxY)
 	} else {
-		finalWidth += 600 // Default size if bounds not set
-		finalHeight += 100
+		debugf("assembleFinalSVG bounds: not set")
 	}
 
-	finalWidth = math.Max(finalWidth, 10)
-	finalHeight = math.Max(finalHeight, 10)
+	canvas := computeCanvasGeometry(timelineBounds, config)
+	finalWidth, finalHeight, contentScale := canvas.width, canvas.height, canvas.scale
+
+	debugf("assembleFinalSVG canvas: finalWidth=%.0f, finalHeight=%.0f, offsetX=%.2f, offsetY=%.2f, scale=%.4f",
+		finalWidth, finalHeight, canvas.translateX, canvas.translateY, contentScale)
+	if contentScale < 1.0 {
+		svgBody.warnf("canvas_scaled", "Timeline exceeds layout max_width/max_height, content scaled by %.4f to fit a %.0fx%.0f canvas.", contentScale, finalWidth, finalHeight)
+	}
 
-	// --- DEBUG LOGGING START ---
-	// log.Printf("  Calculated: finalWidth=%.0f, finalHeight=%.0f, offsetX=%.2f, offsetY=%.2f",
-	// 	finalWidth, finalHeight, offsetX, offsetY)
-	// --- DEBUG LOGGING END ---
+	renderingAttrs := ""
+	if config.shapeRendering != "" {
+		renderingAttrs += fmt.Sprintf(` shape-rendering="%s"`, config.shapeRendering)
+	}
+	if config.textRendering != "" {
+		renderingAttrs += fmt.Sprintf(` text-rendering="%s"`, config.textRendering)
+	}
 
-	var finalSVG bytes.Buffer
-	fmt.Fprintf(&finalSVG, `<svg width="%.0f" height="%.0f" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`,
-		finalWidth, finalHeight)
+	finalSVG := svgBuffer{renderContext: svgBody.renderContext}
+	fmt.Fprintf(&finalSVG, `<svg width="%.0f" height="%.0f"%s xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`,
+		finalWidth, finalHeight, renderingAttrs)
 	finalSVG.WriteString("\n")
 
-	// Add a white background rectangle
-	fmt.Fprintf(&finalSVG, `  <rect width="%.0f" height="%.0f" fill="#FFFFFF" />\n`, finalWidth, finalHeight)
+	// Add a white background rectangle, unless the SVG should stay transparent
+	if !config.transparent {
+		fmt.Fprintf(&finalSVG, `  <rect width="%.0f" height="%.0f" fill="%s" />\n`, finalWidth, finalHeight, finalSVG.mapColor("#FFFFFF"))
+	}
 
 	// Styles - Keep the tags but remove the placeholder comment
 	finalSVG.WriteString("  <style>\n")
 	if globalFont != nil { /* Placeholder for potential future global font CSS */
 	}
+	if config.animateConnectors {
+		finalSVG.WriteString("    @keyframes timeline-connector-draw { to { stroke-dashoffset: 0; } }\n")
+		fmt.Fprintf(&finalSVG, "    .timeline-connector-draw { animation: timeline-connector-draw %.2fs ease-out forwards; }\n", connectorAnimationDuration)
+	}
 	finalSVG.WriteString("  </style>\n")
+	writeDefs(&finalSVG, config.customDefs, config.centerLineGradientDef+patternDefs(svgBody.Bytes())) // Only the patterns actually referenced
 
-	// Transform Group...
-	fmt.Fprintf(&finalSVG, `<g transform="translate(%.2f, %.2f)">`, offsetX, offsetY)
+	// Transform Group... (scale applies to content coordinates, so the translate is scaled too)
+	if contentScale != 1.0 {
+		fmt.Fprintf(&finalSVG, `<g transform="translate(%s, %s) scale(%.4f)">`,
+			finalSVG.coord(canvas.translateX), finalSVG.coord(canvas.translateY), contentScale)
+	} else {
+		fmt.Fprintf(&finalSVG, `<g transform="translate(%s, %s)">`, finalSVG.coord(canvas.translateX), finalSVG.coord(canvas.translateY))
+	}
 	finalSVG.WriteString("\n")
 	finalSVG.Write(svgBody.Bytes())
 	finalSVG.WriteString("</g>\n")
@@ -1133,46 +2387,260 @@ func assembleFinalSVG(svgBody bytes.Buffer, timelineBounds bounds, layoutPadding
 	return finalSVG.String()
 }
 
+// writeDefs writes a single <defs> block holding the template's custom defs (verbatim,
+// unvalidated) followed by the built-in pattern defs. Nothing is written when both are empty.
+func writeDefs(finalSVG *svgBuffer, customDefs, builtinDefs string) {
+	customDefs = strings.TrimSpace(customDefs)
+	if customDefs == "" && builtinDefs == "" {
+		return
+	}
+	finalSVG.WriteString("  <defs>\n")
+	if customDefs != "" {
+		finalSVG.WriteString(customDefs)
+		finalSVG.WriteString("\n")
+	}
+	finalSVG.WriteString(builtinDefs)
+	finalSVG.WriteString("  </defs>\n")
+}
+
+// canvasGeometry is the final canvas size and the transform placing content coordinates on it.
+type canvasGeometry struct {
+	width, height          float64
+	scale                  float64 // Uniform content scale from max_width/max_height
+	translateX, translateY float64 // Applied after scaling
+}
+
+// toCanvas maps a content coordinate to its position on the canvas.
+func (c canvasGeometry) toCanvas(x, y float64) (float64, float64) {
+	return x*c.scale + c.translateX, y*c.scale + c.translateY
+}
+
+// computeCanvasGeometry sizes the canvas around timelineBounds with the configured padding
+// and canvas limits.
+func computeCanvasGeometry(timelineBounds bounds, config LayoutConfig) canvasGeometry {
+	finalWidth := config.paddingLeft + config.paddingRight
+	finalHeight := config.paddingTop + config.paddingBottom
+	offsetX := config.paddingLeft - timelineBounds.minX
+	offsetY := config.paddingTop - timelineBounds.minY
+
+	if timelineBounds.isSet {
+		finalWidth += timelineBounds.maxX - timelineBounds.minX
+		finalHeight += timelineBounds.maxY - timelineBounds.minY
+	} else {
+		finalWidth += 600 // Default size if bounds not set
+		finalHeight += 100
+	}
+
+	finalWidth = math.Max(finalWidth, 10)
+	finalHeight = math.Max(finalHeight, 10)
+
+	// Clamp canvas size: scale oversized content down, center undersized content
+	finalWidth, finalHeight, contentScale, centerX, centerY := clampCanvasSize(finalWidth, finalHeight, config)
+	return canvasGeometry{
+		width:      finalWidth,
+		height:     finalHeight,
+		scale:      contentScale,
+		translateX: offsetX*contentScale + centerX,
+		translateY: offsetY*contentScale + centerY,
+	}
+}
+
+// clampCanvasSize applies the optional min/max canvas limits to the computed canvas size.
+// It returns the final canvas dimensions, the uniform content scale and the extra
+// offsets needed to center the (scaled) content within the canvas.
+func clampCanvasSize(width, height float64, config LayoutConfig) (canvasW, canvasH, scale, centerX, centerY float64) {
+	scale = 1.0
+	if config.maxWidth > 0 && width > config.maxWidth {
+		scale = math.Min(scale, config.maxWidth/width)
+	}
+	if config.maxHeight > 0 && height > config.maxHeight {
+		scale = math.Min(scale, config.maxHeight/height)
+	}
+	canvasW, canvasH = width*scale, height*scale
+
+	// Pad out tiny timelines, keeping the content centered
+	if config.minWidth > 0 && canvasW < config.minWidth {
+		centerX = (config.minWidth - canvasW) / 2.0
+		canvasW = config.minWidth
+	}
+	if config.minHeight > 0 && canvasH < config.minHeight {
+		centerY = (config.minHeight - canvasH) / 2.0
+		canvasH = config.minHeight
+	}
+	return canvasW, canvasH, scale, centerX, centerY
+}
+
+// drawNumberBadge draws the entry number centered on the axis point in a circle, which
+// stretches into a pill when the number is wider than the badge (double digits and up).
+func drawNumberBadge(svg *svgBuffer, bounds *bounds, params NumberBadgeParams) {
+	label := strconv.Itoa(params.Number)
+	height := params.Style.Size
+	width := math.Max(height, estimateTextSVGWidth(label, params.Style.Font)+height/2)
+	x, y := params.CenterX-width/2, params.CenterY-height/2
+
+	borderAttr := ""
+	borderWidth := 0.0
+	if params.Style.BorderColor != "" && params.Style.BorderWidth > 0 {
+		borderWidth = params.Style.BorderWidth * params.StrokeScale
+		borderAttr = fmt.Sprintf(` stroke="%s" stroke-width="%s"`, svg.mapColor(params.Style.BorderColor), svg.coord(borderWidth))
+	}
+	fmt.Fprintf(svg, `  <rect class="timeline-number" x="%s" y="%s" width="%s" height="%s" rx="%s" ry="%s" fill="%s"%s/>`,
+		svg.coord(x), svg.coord(y), svg.coord(width), svg.coord(height), svg.coord(height/2), svg.coord(height/2), svg.resolveFill(params.FillColor), borderAttr)
+	svg.WriteString("\n")
+
+	textY := params.CenterY
+	baselineAttr := ` dominant-baseline="middle"`
+	if params.BaselineCompat {
+		textY += baselineCompatOffset(params.Style.Font.FontSize)
+		baselineAttr = ""
+	}
+	font := params.Style.Font
+	fmt.Fprintf(svg, `  <text x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s"%s text-anchor="middle">%s</text>`,
+		svg.coord(params.CenterX), svg.coord(textY), cssFontFamily(font.FontFamily), font.FontSize, escapeXML(font.FontWeight), escapeXML(font.FontStyle),
+		svg.mapColor(params.Style.TextColor), baselineAttr, label)
+	svg.WriteString("\n")
+	bounds.updateRect(x-borderWidth/2, y-borderWidth/2, width+borderWidth, height+borderWidth)
+}
+
+// drawThumbnail draws the entry's image clipped to a circle centered on the axis point,
+// with an optional ring around it.
+func drawThumbnail(svg *svgBuffer, bounds *bounds, params ThumbnailParams) {
+	src := svg.embedImageSource(params.Image)
+	if src == "" || params.Size <= 0 {
+		return
+	}
+	radius := params.Size / 2.0
+	x, y := params.CenterX-radius, params.CenterY-radius
+	fmt.Fprintf(svg, `  <defs><clipPath id="%s"><circle cx="%s" cy="%s" r="%s"/></clipPath></defs>`,
+		escapeXML(params.ClipID), svg.coord(params.CenterX), svg.coord(params.CenterY), svg.coord(radius))
+	svg.WriteString("\n")
+	fmt.Fprintf(svg, `  <image x="%s" y="%s" width="%s" height="%s" href="%s" xlink:href="%s" preserveAspectRatio="xMidYMid slice" clip-path="url(#%s)"/>`,
+		svg.coord(x), svg.coord(y), svg.coord(params.Size), svg.coord(params.Size), escapeXML(src), escapeXML(src), escapeXML(params.ClipID))
+	svg.WriteString("\n")
+	bounds.updateRect(x, y, params.Size, params.Size)
+
+	if params.BorderWidth > 0 {
+		borderWidth := params.BorderWidth * params.StrokeScale
+		fmt.Fprintf(svg, `  <circle cx="%s" cy="%s" r="%s" fill="none" stroke="%s" stroke-width="%s"/>`,
+			svg.coord(params.CenterX), svg.coord(params.CenterY), svg.coord(radius), svg.mapColor(params.BorderColor), svg.coord(borderWidth))
+		svg.WriteString("\n")
+		bounds.updateRect(x-borderWidth/2.0, y-borderWidth/2.0, params.Size+borderWidth, params.Size+borderWidth)
+	}
+}
+
 // Helper: Draw Junction Marker
-func drawJunctionMarker(svg *bytes.Buffer, bounds *bounds, params JunctionMarkerParams) {
+func drawJunctionMarker(svg *svgBuffer, bounds *bounds, params JunctionMarkerParams) {
 	if params.Style.Shape == "none" || params.Style.Size <= 0 {
 		return
 	}
 	size := params.Style.Size
 	halfSize := size / 2.0
-	fillColor := params.MarkerColor
+	fillColor := svg.mapColor(params.MarkerColor)
+	// Optional outline so markers stand out on segments of the same color
+	borderAttr := ""
+	if params.Style.BorderColor != "" && params.Style.BorderWidth > 0 {
+		borderAttr = fmt.Sprintf(` stroke="%s" stroke-width="%s"`, svg.mapColor(params.Style.BorderColor), svg.coord(params.Style.BorderWidth*params.StrokeScale))
+	}
 	switch params.Style.Shape {
 	case "arrow", "diamond": /* ... draw polygons ... */
 		var points1, points2 string
-		var p1x, p1y, p2x, p2y, p3x, p3y, p4x, p4y float64
-		if params.IsHorizontal {
-			p2x, p2y = params.CenterX-halfSize, params.CenterY
-			p3x, p3y = params.CenterX+halfSize, params.CenterY
-			p1x, p1y = params.CenterX, params.CenterY+halfSize
-			p4x, p4y = params.CenterX, params.CenterY-halfSize
-		} else {
-			p2x, p2y = params.CenterX, params.CenterY-halfSize
-			p3x, p3y = params.CenterX, params.CenterY+halfSize
-			p1x, p1y = params.CenterX+halfSize, params.CenterY
-			p4x, p4y = params.CenterX-halfSize, params.CenterY
-		}
-		points1 = fmt.Sprintf("%.2f,%.2f %.2f,%.2f %.2f,%.2f", p1x, p1y, p2x, p2y, p3x, p3y)
-		points2 = fmt.Sprintf("%.2f,%.2f %.2f,%.2f %.2f,%.2f", p4x, p4y, p2x, p2y, p3x, p3y)
+		// p2/p3 lie along the axis and p1/p4 across it, so the shape follows an angled axis too
+		mainX, mainY, crossX, crossY := axisFrame(params.IsHorizontal, params.AxisAngle)
+		p2x, p2y := params.CenterX-mainX*halfSize, params.CenterY-mainY*halfSize
+		p3x, p3y := params.CenterX+mainX*halfSize, params.CenterY+mainY*halfSize
+		p1x, p1y := params.CenterX+crossX*halfSize, params.CenterY+crossY*halfSize
+		p4x, p4y := params.CenterX-crossX*halfSize, params.CenterY-crossY*halfSize
+		points1 = fmt.Sprintf("%s,%s %s,%s %s,%s", svg.coord(p1x), svg.coord(p1y), svg.coord(p2x), svg.coord(p2y), svg.coord(p3x), svg.coord(p3y))
+		points2 = fmt.Sprintf("%s,%s %s,%s %s,%s", svg.coord(p4x), svg.coord(p4y), svg.coord(p2x), svg.coord(p2y), svg.coord(p3x), svg.coord(p3y))
 		fmt.Fprintf(svg, `  <polygon points="%s" fill="%s" />`, points1, fillColor)
 		fmt.Fprintf(svg, `  <polygon points="%s" fill="%s" />`, points2, fillColor)
+		if borderAttr != "" {
+			// Outline the whole shape once, stroking each half would draw the shared edge too
+			outline := fmt.Sprintf("%s,%s %s,%s %s,%s %s,%s", svg.coord(p2x), svg.coord(p2y), svg.coord(p1x), svg.coord(p1y), svg.coord(p3x), svg.coord(p3y), svg.coord(p4x), svg.coord(p4y))
+			fmt.Fprintf(svg, `  <polygon points="%s" fill="none"%s />`, outline, borderAttr)
+		}
 		svg.WriteString("\n")
-		bounds.updatePoint(params.CenterX-halfSize, params.CenterY-halfSize)
-		bounds.updatePoint(params.CenterX+halfSize, params.CenterY+halfSize)
+		bounds.updatePoint(p1x, p1y)
+		bounds.updatePoint(p2x, p2y)
+		bounds.updatePoint(p3x, p3y)
+		bounds.updatePoint(p4x, p4y)
 	case "circle": /* ... draw circle ... */
-		fmt.Fprintf(svg, `  <circle cx="%.2f" cy="%.2f" r="%.2f" fill="%s" />`,
-			params.CenterX, params.CenterY, halfSize, fillColor)
+		fmt.Fprintf(svg, `  <circle cx="%s" cy="%s" r="%s" fill="%s"%s />`,
+			svg.coord(params.CenterX), svg.coord(params.CenterY), svg.coord(halfSize), fillColor, borderAttr)
 		svg.WriteString("\n")
 		bounds.updateRect(params.CenterX-halfSize, params.CenterY-halfSize, size, size)
+	case "flag":
+		// A pole standing size tall on the start side of the axis with a pennant along the axis
+		mainX, mainY, crossX, crossY := axisFrame(params.IsHorizontal, params.AxisAngle)
+		topX, topY := params.CenterX-crossX*size, params.CenterY-crossY*size
+		tipX, tipY := topX+mainX*size*0.6+crossX*size*0.25, topY+mainY*size*0.6+crossY*size*0.25
+		baseX, baseY := topX+crossX*halfSize, topY+crossY*halfSize
+		fmt.Fprintf(svg, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s" />`,
+			svg.coord(params.CenterX), svg.coord(params.CenterY), svg.coord(topX), svg.coord(topY), fillColor, svg.coord(math.Max(size/10, 1)*params.StrokeScale))
+		fmt.Fprintf(svg, `  <polygon points="%s,%s %s,%s %s,%s" fill="%s"%s />`,
+			svg.coord(topX), svg.coord(topY), svg.coord(tipX), svg.coord(tipY), svg.coord(baseX), svg.coord(baseY), fillColor, borderAttr)
+		svg.WriteString("\n")
+		bounds.updatePoint(params.CenterX, params.CenterY)
+		bounds.updatePoint(topX, topY)
+		bounds.updatePoint(tipX, tipY)
+	}
+}
+
+// defaultAxisCapSize is the size of center line caps that set none.
+const defaultAxisCapSize = 12.0
+
+// drawAxisCap draws a center line start or end cap at point with the junction marker shapes.
+// A nil style draws nothing.
+func drawAxisCap(svg *svgBuffer, bounds *bounds, style *AxisCapStyle, point AxisPoint, isHorizontal bool, axisAngle *float64, config LayoutConfig) {
+	if style == nil {
+		return
+	}
+	size := style.Size
+	if size <= 0 {
+		size = defaultAxisCapSize
 	}
+	drawJunctionMarker(svg, bounds, JunctionMarkerParams{
+		Style:           JunctionMarkerStyle{Shape: style.Shape, Size: size},
+		CenterX:         point.X,
+		CenterY:         point.Y,
+		MarkerColor:     ternary(style.Color != "", style.Color, config.centerLineBaseColor),
+		IsHorizontal:    isHorizontal,
+		AxisAngle:       axisAngle,
+		CenterLineWidth: config.centerLineWidth,
+		StrokeScale:     config.strokeScale,
+	})
+}
+
+// drawDivider draws a line perpendicular to the axis through the center point, StartReach
+// towards the start side and EndReach towards the end side.
+func drawDivider(svg *svgBuffer, bounds *bounds, params DividerParams) {
+	width := params.Style.Width
+	if width <= 0 {
+		width = 1
+	}
+	width *= params.StrokeScale
+	color := params.Style.Color
+	if color == "" {
+		color = params.Color
+	}
+	lineType := params.Style.LineType
+	if lineType == "" {
+		lineType = "dashed"
+	}
+	_, _, crossX, crossY := axisFrame(params.IsHorizontal, params.AxisAngle)
+	x1, y1 := params.CenterX-crossX*params.StartReach, params.CenterY-crossY*params.StartReach
+	x2, y2 := params.CenterX+crossX*params.EndReach, params.CenterY+crossY*params.EndReach
+	x1, y1 = svg.jitterPoint(x1, y1)
+	x2, y2 = svg.jitterPoint(x2, y2)
+	fmt.Fprintf(svg, `    <line class="timeline-divider" x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s />`,
+		svg.coord(x1), svg.coord(y1), svg.coord(x2), svg.coord(y2), svg.mapColor(color), svg.coord(width), getStrokeDashArray(lineType, int(width)))
+	svg.WriteString("\n")
+	bounds.updatePoint(x1, y1)
+	bounds.updatePoint(x2, y2)
 }
 
 // Helper: Draw Comment
-func drawComment(svg *bytes.Buffer, bounds *bounds, params CommentParams) {
+func drawComment(svg *svgBuffer, bounds *bounds, params CommentParams) {
 	// --- Font and Color Setup ---
 	bodyFont := params.Style.Font
 	titleFont := params.Style.TitleFont
@@ -1188,10 +2656,48 @@ func drawComment(svg *bytes.Buffer, bounds *bounds, params CommentParams) {
 	}
 
 	// --- Block Layout Calculation ---
-	blockLayout := calculateCommentBlockLayout(params)
+	blockLayout := calculateCommentBlockLayout(svg.warnings(), params)
+
+	// --- Link Wrapper (around the whole comment block) ---
+	// Nested anchors are invalid, so skip the wrapper if the body already has its own links
+	wrapInLink := params.Link != ""
+	if wrapInLink && containsLink(params.BodyText) {
+		svg.warnf("comment_link_ignored", "comment_link \"%s\" ignored because the comment body contains links.", params.Link)
+		wrapInLink = false
+	}
+	if wrapInLink {
+		fmt.Fprintf(svg, `  <a xlink:href="%s" target="_blank">`, escapeXML(params.Link))
+		svg.WriteString("\n")
+		defer svg.WriteString("  </a>\n")
+	}
 
 	// --- Draw Background/Border ---
-	drawCommentBackground(svg, bounds, params.Style, blockLayout)
+	drawCommentBackground(svg, bounds, params.Style, blockLayout, params.StrokeScale)
+	if params.Style.Tail && params.Style.Shape == "rectangle" && !params.Inline {
+		drawCommentTail(svg, bounds, params.Style, blockLayout, params.CrossAxisDir, params.IsHorizontal, params.StrokeScale)
+	}
+
+	// --- Draw Body Content (foreignObject), in stacking order with the title ---
+	drawBody := func() {
+		if blockLayout.foHeight > 0 {
+			bodyParams := CommentBodyParams{
+				Params:    params,
+				BodyFont:  bodyFont,
+				TextColor: textColor,
+				Layout:    blockLayout,
+				ClipID:    params.EntryID + "-body-clip",
+			}
+			if params.TextBody {
+				drawCommentBodyText(svg, bounds, bodyParams)
+			} else {
+				drawCommentBody(svg, bounds, bodyParams)
+			}
+		}
+	}
+	titleBelow := params.Style.TitlePosition == "below"
+	if titleBelow {
+		drawBody()
+	}
 
 	// --- Draw Title Text ---
 	if params.TitleText != "" {
@@ -1206,75 +2712,322 @@ func drawComment(svg *bytes.Buffer, bounds *bounds, params CommentParams) {
 	// --- Draw Title Line (Decorative) ---
 	if params.TitleText != "" && titleLine.Visible && titleLine.Length > 0 && titleLine.Width > 0 {
 		drawCommentTitleLine(svg, bounds, CommentTitleLineParams{
-			TitleLine: titleLine,
-			Layout:    blockLayout,
+			TitleLine:   titleLine,
+			Layout:      blockLayout,
+			StrokeScale: params.StrokeScale,
 		})
 	}
 
-	// --- Draw Body Content (foreignObject) ---
-	if blockLayout.foHeight > 0 {
-		drawCommentBody(svg, bounds, CommentBodyParams{
-			Params:    params,
-			BodyFont:  bodyFont,
-			TextColor: textColor,
-			Layout:    blockLayout,
-		})
+	if !titleBelow {
+		drawBody()
+	}
+}
+
+// containsLink reports whether text contains a markdown or HTML link.
+func containsLink(text string) bool {
+	return markdownLinkRegex.MatchString(text) || strings.Contains(strings.ToLower(text), "<a ")
+}
+
+// isAngledAxis reports whether angleDeg describes an axis that isn't horizontal or vertical.
+// Axis-aligned angles keep the orientation-based placement.
+func isAngledAxis(angleDeg *float64) bool {
+	return angleDeg != nil && math.Mod(*angleDeg, 90) != 0
+}
+
+// axisFrame returns unit vectors for the main-axis direction and the cross-axis direction
+// (the CrossDir = +1 side) used to place elements around an axis point. For axis-aligned
+// segments the orientation decides, as before; for angled segments both are rotated so
+// elements sit perpendicular to the axis, on the side closest to the unrotated one.
+func axisFrame(isHorizontal bool, angleDeg *float64) (mainX, mainY, crossX, crossY float64) {
+	if isHorizontal {
+		mainX, mainY, crossX, crossY = 1, 0, 0, 1 // Cross +1 = down
+	} else {
+		mainX, mainY, crossX, crossY = 0, 1, 1, 0 // Cross +1 = right
 	}
+	if !isAngledAxis(angleDeg) {
+		return mainX, mainY, crossX, crossY
+	}
+	angleRad := *angleDeg * math.Pi / 180.0
+	nx, ny := -math.Sin(angleRad), math.Cos(angleRad) // Perpendicular to the axis direction
+	if nx*crossX+ny*crossY < 0 {
+		nx, ny = -nx, -ny
+	}
+	// Rotate the main direction by the same angle that takes the base cross direction to (nx, ny)
+	phi := math.Atan2(crossX*ny-crossY*nx, crossX*nx+crossY*ny)
+	cosPhi, sinPhi := math.Cos(phi), math.Sin(phi)
+	return mainX*cosPhi - mainY*sinPhi, mainX*sinPhi + mainY*cosPhi, nx, ny
 }
 
 // Helper: Calculate Element Center
+// The element sits |ConnectorLen + CrossOffset| from the axis on side CrossDir. A negative total
+// means the opposite side: callers resolve CrossDir with resolveCrossSide first, so the block
+// layout, edge point and connectors all agree on the side the element ends up on.
 func calculateElementCenter(params ElementCenterParams) (float64, float64) {
 	centerX, centerY := params.AxisX, params.AxisY // Start at the entry point on axis
-	if params.IsHorizontal {                       // Base orientation is horizontal
+	if params.Inline {
+		// Inline elements sit on the axis itself: only the main-axis offset applies
+		mainX, mainY, _, _ := axisFrame(params.IsHorizontal, params.AxisAngle)
+		return centerX + mainX*params.MainOffset, centerY + mainY*params.MainOffset
+	}
+	if isAngledAxis(params.AxisAngle) {
+		// Angled axis: MainOffset runs along the axis, the cross distance perpendicular to it
+		mainX, mainY, crossX, crossY := axisFrame(params.IsHorizontal, params.AxisAngle)
+		crossDist := params.CrossDir * math.Abs(params.ConnectorLen+params.CrossOffset)
+		centerX += mainX*params.MainOffset + crossX*crossDist
+		centerY += mainY*params.MainOffset + crossY*crossDist
+	} else if params.IsHorizontal { // Base orientation is horizontal
 		// MainOffset shifts along the intended horizontal axis (X) - usually 0 for these elements
 		centerX += params.MainOffset
 		// CrossOffset shifts vertically (Y) based on CrossDir (+1 down, -1 up)
 		// ConnectorLen provides the base distance from the axis.
-		centerY += params.CrossDir * (params.ConnectorLen + params.CrossOffset)
+		centerY += params.CrossDir * math.Abs(params.ConnectorLen+params.CrossOffset)
 	} else { // Base orientation is vertical
 		// MainOffset shifts along the intended vertical axis (Y) - usually 0
 		centerY += params.MainOffset
 		// CrossOffset shifts horizontally (X) based on CrossDir (+1 right, -1 left)
-		centerX += params.CrossDir * (params.ConnectorLen + params.CrossOffset)
+		centerX += params.CrossDir * math.Abs(params.ConnectorLen+params.CrossOffset)
 	}
 	return centerX, centerY
 }
 
 // Add a parameter struct for drawCommentTitleLine
 type CommentTitleLineParams struct {
-	TitleLine TitleLineStyle
-	Layout    CommentBlockLayout
+	TitleLine   TitleLineStyle
+	Layout      CommentBlockLayout
+	StrokeScale float64
 }
 
 // Draw the decorative line below a comment title
-func drawCommentTitleLine(svg *bytes.Buffer, bounds *bounds, params CommentTitleLineParams) {
+func drawCommentTitleLine(svg *svgBuffer, bounds *bounds, params CommentTitleLineParams) {
 	lineX1 := params.Layout.contentCenterX - params.TitleLine.Length/2.0
 	lineX2 := params.Layout.contentCenterX + params.TitleLine.Length/2.0
-	fmt.Fprintf(svg, ` <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f" />`,
-		lineX1, params.Layout.titleLineAbsY, lineX2, params.Layout.titleLineAbsY, params.TitleLine.Color, params.TitleLine.Width)
-	svg.WriteString("\n")
-	bounds.updatePoint(lineX1, params.Layout.titleLineAbsY)
-	bounds.updatePoint(lineX2, params.Layout.titleLineAbsY)
+	lineY := params.Layout.titleLineAbsY
+	params.TitleLine.Width *= params.StrokeScale
+	if params.TitleLine.LineType == "double" {
+		// Two thin parallel lines filling the configured thickness (thirds: line, gap, line)
+		thinWidth := params.TitleLine.Width / 3.0
+		for _, y := range []float64{lineY - thinWidth, lineY + thinWidth} {
+			fmt.Fprintf(svg, ` <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s" />`,
+				svg.coord(lineX1), svg.coord(y), svg.coord(lineX2), svg.coord(y), svg.mapColor(params.TitleLine.Color), svg.coord(thinWidth))
+			svg.WriteString("\n")
+		}
+	} else {
+		dashArray := getStrokeDashArray(params.TitleLine.LineType, int(params.TitleLine.Width))
+		fmt.Fprintf(svg, ` <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s />`,
+			svg.coord(lineX1), svg.coord(lineY), svg.coord(lineX2), svg.coord(lineY), svg.mapColor(params.TitleLine.Color), svg.coord(params.TitleLine.Width), dashArray)
+		svg.WriteString("\n")
+	}
+	bounds.updatePoint(lineX1, lineY)
+	bounds.updatePoint(lineX2, lineY)
 }
 
 // Helper function to draw a single segment of the center line
 func drawCenterLineSegment(params DrawCenterLineSegmentParams) {
+	params.X1, params.Y1 = params.SVG.jitterPoint(params.X1, params.Y1)
+	params.X2, params.Y2 = params.SVG.jitterPoint(params.X2, params.Y2)
 	strokeDash := getStrokeDashArray(params.LineType, int(params.Width))
+	if strokeDash != "" && params.DashOffset > 0 {
+		// Pick the pattern up where the previous segment left it; solid lines don't need it
+		strokeDash += fmt.Sprintf(` stroke-dashoffset="%s"`, params.SVG.coord(params.DashOffset))
+	}
 	strokeLineCap := ""
 	if params.RoundedCaps {
 		strokeLineCap = ` stroke-linecap="round"`
 	}
 
-	fmt.Fprintf(params.SVG, `  <line x1="%.2f" y1="%.2f" x2="%.2f" y2="%.2f" stroke="%s" stroke-width="%.2f"%s%s />`+"\n",
-		params.X1, params.Y1, params.X2, params.Y2, params.Color, params.Width, strokeDash, strokeLineCap)
+	fmt.Fprintf(params.SVG, `  <line x1="%s" y1="%s" x2="%s" y2="%s" stroke="%s" stroke-width="%s"%s%s />`+"\n",
+		params.SVG.coord(params.X1), params.SVG.coord(params.Y1), params.SVG.coord(params.X2), params.SVG.coord(params.Y2), params.SVG.mapColor(params.Color), params.SVG.coord(params.Width), strokeDash, strokeLineCap)
 	params.Bounds.updatePoint(params.X1, params.Y1)
 	params.Bounds.updatePoint(params.X2, params.Y2)
 }
 
+// centerLineGradientID is the id of the <linearGradient> drawn for center_line.gradient.
+const centerLineGradientID = "center-line-gradient"
+
+// centerLineGradientDef returns a <linearGradient> running from start to end, in content
+// coordinates so it follows the axis direction (angled axes too), with the stop colors
+// spread evenly. A single color gives a solid line.
+func centerLineGradientDef(render *renderContext, stops []string, start, end AxisPoint) string {
+	var def strings.Builder
+	fmt.Fprintf(&def, `    <linearGradient id="%s" gradientUnits="userSpaceOnUse" x1="%s" y1="%s" x2="%s" y2="%s">`,
+		centerLineGradientID, render.coord(start.X), render.coord(start.Y), render.coord(end.X), render.coord(end.Y))
+	for i, color := range stops {
+		offset := 0.0
+		if len(stops) > 1 {
+			offset = float64(i) / float64(len(stops)-1) * 100
+		}
+		fmt.Fprintf(&def, `<stop offset="%s%%" stop-color="%s"/>`, render.coord(offset), escapeXML(render.mapColor(color)))
+	}
+	def.WriteString("</linearGradient>\n")
+	return def.String()
+}
+
+// drawGradientCenterLine draws the straight center line through all points as a single
+// path stroked with the center line gradient.
+func drawGradientCenterLine(params GradientCenterLineParams) {
+	strokeLineCap := ""
+	if params.RoundedCaps {
+		strokeLineCap = ` stroke-linecap="round"`
+	}
+	pathData := ""
+	for i, p := range params.Points {
+		p.X, p.Y = params.SVG.jitterPoint(p.X, p.Y)
+		command := ternary(i == 0, "M", " L")
+		pathData += fmt.Sprintf("%s %s %s", command, params.SVG.coord(p.X), params.SVG.coord(p.Y))
+		params.Bounds.updatePoint(p.X, p.Y)
+	}
+	fmt.Fprintf(params.SVG, `  <path d="%s" fill="none" stroke="url(#%s)" stroke-width="%s"%s%s%s />`+"\n",
+		pathData, centerLineGradientID, params.SVG.coord(params.Width), getStrokeDashArray(params.LineType, int(params.Width)), strokeLineCap, lineJoinAttr(params.LineJoin))
+}
+
+// catmullRomControlPoints returns the cubic bezier control points for the
+// Catmull-Rom segment p1->p2, with p0 and p3 as its neighbours.
+func catmullRomControlPoints(p0, p1, p2, p3 AxisPoint) (AxisPoint, AxisPoint) {
+	c1 := AxisPoint{X: p1.X + (p2.X-p0.X)/6.0, Y: p1.Y + (p2.Y-p0.Y)/6.0}
+	c2 := AxisPoint{X: p2.X - (p3.X-p1.X)/6.0, Y: p2.Y - (p3.Y-p1.Y)/6.0}
+	return c1, c2
+}
+
+// Draw the center line as a smooth curve through all points. A single path is used
+// when every segment has the same color, otherwise one curved path per segment.
+func drawSmoothCenterLine(params SmoothCenterLineParams) {
+	strokeLineCap := ""
+	if params.RoundedCaps {
+		strokeLineCap = ` stroke-linecap="round"`
+	}
+
+	// Drop zero-length segments, otherwise their control points would loop back
+	pts := []AxisPoint{params.Points[0]}
+	colors := []string{}
+	widths := []float64{}
+	for i := 1; i < len(params.Points); i++ {
+		if params.Points[i] != pts[len(pts)-1] {
+			pts = append(pts, params.Points[i])
+			colors = append(colors, params.Colors[i-1])
+			widths = append(widths, params.Widths[i-1])
+		}
+	}
+	if len(pts) < 2 {
+		return // Nothing to draw
+	}
+	for i := range pts {
+		pts[i].X, pts[i].Y = params.SVG.jitterPoint(pts[i].X, pts[i].Y)
+	}
+
+	// One path for the whole curve unless segments differ in color or width
+	singleColor := true
+	for i := range colors {
+		if colors[i] != colors[0] || widths[i] != widths[0] {
+			singleColor = false
+			break
+		}
+	}
+
+	last := len(pts) - 1
+	pathData := ""
+	for i := 0; i < last; i++ {
+		p0, p3 := pts[max(i-1, 0)], pts[min(i+2, last)] // Clamp neighbours at the ends
+		c1, c2 := catmullRomControlPoints(p0, pts[i], pts[i+1], p3)
+		if pathData == "" {
+			pathData = fmt.Sprintf("M %s %s", params.SVG.coord(pts[i].X), params.SVG.coord(pts[i].Y))
+		}
+		pathData += fmt.Sprintf(" C %s %s %s %s %s %s", params.SVG.coord(c1.X), params.SVG.coord(c1.Y), params.SVG.coord(c2.X), params.SVG.coord(c2.Y), params.SVG.coord(pts[i+1].X), params.SVG.coord(pts[i+1].Y))
+
+		// Control points bound the curve, so they keep the whole path inside the viewBox
+		params.Bounds.updatePoint(pts[i].X, pts[i].Y)
+		params.Bounds.updatePoint(c1.X, c1.Y)
+		params.Bounds.updatePoint(c2.X, c2.Y)
+		params.Bounds.updatePoint(pts[i+1].X, pts[i+1].Y)
+
+		if !singleColor {
+			fmt.Fprintf(params.SVG, `  <path d="%s" fill="none" stroke="%s" stroke-width="%s"%s%s%s />`+"\n",
+				pathData, params.SVG.mapColor(colors[i]), params.SVG.coord(widths[i]), getStrokeDashArray(params.LineType, int(widths[i])), strokeLineCap, lineJoinAttr(params.LineJoin))
+			pathData = ""
+		}
+	}
+	if singleColor && pathData != "" {
+		fmt.Fprintf(params.SVG, `  <path d="%s" fill="none" stroke="%s" stroke-width="%s"%s%s%s />`+"\n",
+			pathData, params.SVG.mapColor(colors[0]), params.SVG.coord(widths[0]), getStrokeDashArray(params.LineType, int(widths[0])), strokeLineCap, lineJoinAttr(params.LineJoin))
+	}
+}
+
+// periodBarPath returns the axis path covered by entry i's date range: from its own
+// axis point to the position of its PeriodEnd. Positions between entries are linearly
+// interpolated by date between the neighbouring entries whose periods are dates; an end
+// date outside the dated entries is clamped to the first/last of them.
+func periodBarPath(diag *diagnosticLog, entries []TimelineEntry, axisPoints []AxisPoint, i int) ([]AxisPoint, bool) {
+	startDate, okStart := parsePeriodDate(entries[i].Period)
+	endDate, okEnd := parsePeriodDate(entries[i].PeriodEnd)
+	if !okStart || !okEnd || endDate < startDate {
+		return nil, false
+	}
+
+	// Walk the dated entries from i onwards until the end date falls between two of them
+	path := []AxisPoint{axisPoints[i]}
+	prevIndex, prevDate := i, startDate
+	for j := i + 1; j < len(entries); j++ {
+		date, ok := parsePeriodDate(entries[j].Period)
+		if !ok || date < prevDate {
+			continue // Free text or out-of-order periods don't anchor positions
+		}
+		if endDate <= date {
+			fraction := 0.0
+			if date > prevDate {
+				fraction = (endDate - prevDate) / (date - prevDate)
+			}
+			return append(path, interpolateAxisPath(axisPoints[prevIndex:j+1], fraction)...), true
+		}
+		path = append(path, axisPoints[prevIndex+1:j+1]...)
+		prevIndex, prevDate = j, date
+	}
+	if endDate > prevDate {
+		diag.warnf("date_clamped", "period_end '%s' is after the last dated entry, bar clamped.", entries[i].PeriodEnd)
+	}
+	return path, true
+}
+
+// interpolateAxisPath returns the points of the polyline pts (after its first point)
+// up to the given fraction of its total length.
+func interpolateAxisPath(pts []AxisPoint, fraction float64) []AxisPoint {
+	totalLength := 0.0
+	for k := 1; k < len(pts); k++ {
+		totalLength += math.Hypot(pts[k].X-pts[k-1].X, pts[k].Y-pts[k-1].Y)
+	}
+	remaining := totalLength * fraction
+	var out []AxisPoint
+	for k := 1; k < len(pts); k++ {
+		segLength := math.Hypot(pts[k].X-pts[k-1].X, pts[k].Y-pts[k-1].Y)
+		if remaining <= segLength {
+			t := 0.0
+			if segLength > 0 {
+				t = remaining / segLength
+			}
+			return append(out, AxisPoint{X: pts[k-1].X + (pts[k].X-pts[k-1].X)*t, Y: pts[k-1].Y + (pts[k].Y-pts[k-1].Y)*t})
+		}
+		out = append(out, pts[k])
+		remaining -= segLength
+	}
+	return out
+}
+
+// Draw a date range as a thick bar following the axis
+func drawPeriodBar(params PeriodBarParams) {
+	if len(params.Points) < 2 {
+		return // Zero-length range: the junction marker alone shows it
+	}
+	pointStrs := make([]string, len(params.Points))
+	for i, p := range params.Points {
+		pointStrs[i] = fmt.Sprintf("%s,%s", params.SVG.coord(p.X), params.SVG.coord(p.Y))
+		params.Bounds.updatePoint(p.X, p.Y)
+	}
+	fmt.Fprintf(params.SVG, `  <polyline points="%s" fill="none" stroke="%s" stroke-width="%s" stroke-linecap="butt" stroke-linejoin="round" />`+"\n",
+		strings.Join(pointStrs, " "), params.SVG.mapColor(params.Color), params.SVG.coord(params.Width))
+}
+
 // Helper function to draw a single axis segment and update current coordinates
 // Returns the end coordinates (new currentX, new currentY) of the drawn segment.
 func drawAndAdvanceAxisSegment(params DrawAndAdvanceAxisSegmentParams) (float64, float64) {
-	var segmentLength float64
+	var segmentLength, dashOffset float64
 	var angleOverride *float64
 	var segmentColorIndex int
 
@@ -1286,6 +3039,7 @@ func drawAndAdvanceAxisSegment(params DrawAndAdvanceAxisSegmentParams) (float64,
 		segmentColorIndex = 0
 	} else { // Subsequent segment (from junction i to junction i+1)
 		segmentLength = params.Data.junctionPoints[params.StartIndex+1] - params.Data.junctionPoints[params.StartIndex]
+		dashOffset = params.Data.junctionPoints[params.StartIndex]
 		if params.StartIndex+1 < len(params.Entries) {
 			angleOverride = params.Entries[params.StartIndex+1].AngleOverride
 		}
@@ -1313,9 +3067,10 @@ func drawAndAdvanceAxisSegment(params DrawAndAdvanceAxisSegmentParams) (float64,
 		X2:          segEndX,
 		Y2:          segEndY,
 		Color:       drawColor,
-		Width:       params.LayoutConfig.centerLineWidth,
+		Width:       params.Data.segmentWidths[segmentColorIndex] * params.LayoutConfig.strokeScale,
 		LineType:    params.CenterLineType,
 		RoundedCaps: params.LayoutConfig.centerLineIsRounded,
+		DashOffset:  dashOffset,
 	})
 
 	// Return the end coordinates for the next iteration
@@ -1324,24 +3079,53 @@ func drawAndAdvanceAxisSegment(params DrawAndAdvanceAxisSegmentParams) (float64,
 
 // GenerateSVG generates an SVG timeline from a template and entries
 func GenerateSVG(template Template, entries []TimelineEntry) (string, error) {
+	svg, _, err := generateSVG(template, entries, nil)
+	return svg, err
+}
+
+// timelineLayout records where a generated SVG placed its entries; canvas maps the entry
+// layouts' content coordinates onto the canvas.
+type timelineLayout struct {
+	entries      []TimelineEntry // Visible entries, in drawing order
+	entryLayouts []EntryLayout
+	canvas       canvasGeometry
+	warnings     []Diagnostic // Warnings raised by the render
+}
+
+// generateSVG builds the timeline SVG and returns it with its layout. bodyHeights, keyed by
+// entry id, replaces the estimated comment body heights with measured ones; nil keeps the estimates.
+func generateSVG(template Template, entries []TimelineEntry, bodyHeights map[string]float64) (string, timelineLayout, error) {
+	entries = visibleEntries(entries) // Drop hidden entries before any geometry is computed
 	if len(entries) == 0 {
-		return "", fmt.Errorf("no timeline entries to generate")
+		return "", timelineLayout{}, &GenerationError{Format: "svg", Err: ErrNoEntries}
 	}
 
-	var svgBody bytes.Buffer
 	timelineBounds := bounds{}
 	isHorizontal := template.CenterLine.Orientation == "horizontal"
 
 	layoutConfig := initializeLayoutConfig(template)
+	layoutConfig.bodyHeights = bodyHeights
+	diag := layoutConfig.diagnostics
+	if layoutConfig.bucket != "" {
+		entries, layoutConfig.bucketRanks = bucketEntries(diag, entries, layoutConfig.bucket, layoutConfig.bucketMerge)
+	}
+	if layoutConfig.noForeignObject {
+		diag.warnf("feature_loss", "layout.no_foreign_object: comment bodies are plain SVG text, so HTML markup, side-by-side images and justified text are dropped and precise_image_layout measurements are ignored.")
+	}
+	svgBody := svgBuffer{renderContext: &renderContext{
+		precision: layoutConfig.precision,
+		colorMap:  layoutConfig.colorMap,
+		jitter:    layoutConfig.jitter,
+		seed:      layoutConfig.seed,
+
+		diagnostics: diag,
+	}}
 	timelineData := calculateTimelinePositionsAndStyles(entries, template, layoutConfig)
 
 	startX, startY := 0.0, 0.0
 	timelineBounds.updatePoint(startX, startY)
 
 	// --- Phase 1: Pre-calculate all axis geometry ---
-	type AxisPoint struct {
-		X, Y float64
-	}
 	entryAxisPoints := make([]AxisPoint, len(entries))
 	segmentStartPoints := make([]AxisPoint, len(entries)) // Start point of segment LEADING to entry i
 	segmentEndPoints := make([]AxisPoint, len(entries))   // End point of segment LEADING to entry i ( = start of next)
@@ -1386,40 +3170,155 @@ func GenerateSVG(template Template, entries []TimelineEntry) (string, error) {
 	segmentStartPoints[0] = AxisPoint{X: initialSegStartX, Y: initialSegStartY}
 	segmentEndPoints[0] = AxisPoint{X: initialSegEndX, Y: initialSegEndY}
 
-	// --- Phase 2: Draw all Center Line Segments FIRST ---
+	// --- Phase 2: Draw all Center Line Segments FIRST (or buffer them for the top with draw_on_top) ---
+	axisSVG := svgBuffer{renderContext: svgBody.renderContext}
 	centerLineType := template.CenterLine.Type
+	segmentDrawColors := make([]string, len(entries))
+	segmentDrawWidths := make([]float64, len(entries))
 	for i := range entries {
-		drawColor := timelineData.segmentColors[i]
-		if drawColor == "" {
-			drawColor = layoutConfig.centerLineBaseColor
+		segmentDrawColors[i] = timelineData.segmentColors[i]
+		if segmentDrawColors[i] == "" {
+			segmentDrawColors[i] = layoutConfig.centerLineBaseColor
+		}
+		segmentDrawWidths[i] = timelineData.segmentWidths[i] * layoutConfig.strokeScale
+	}
+	axisGradient := template.CenterLine.Gradient
+	if axisGradient != nil && len(*axisGradient) == 0 {
+		diag.warnf("invalid_option", "center_line.gradient has no colors, using the segment colors.")
+		axisGradient = nil
+	}
+	if axisGradient != nil {
+		// One stroke over the whole axis; the gradient replaces the per-segment colors and widths
+		axisPoints := append([]AxisPoint{segmentStartPoints[0]}, entryAxisPoints...)
+		layoutConfig.centerLineGradientDef = centerLineGradientDef(svgBody.renderContext, *axisGradient, axisPoints[0], axisPoints[len(axisPoints)-1])
+		axisWidth := layoutConfig.centerLineWidth * layoutConfig.strokeScale
+		if template.CenterLine.Smooth {
+			gradientColors := make([]string, len(entries))
+			gradientWidths := make([]float64, len(entries))
+			for i := range entries {
+				gradientColors[i], gradientWidths[i] = "url(#"+centerLineGradientID+")", axisWidth
+			}
+			drawSmoothCenterLine(SmoothCenterLineParams{
+				SVG:         &axisSVG,
+				Bounds:      &timelineBounds,
+				Points:      axisPoints,
+				Colors:      gradientColors,
+				Widths:      gradientWidths,
+				LineType:    centerLineType,
+				RoundedCaps: layoutConfig.centerLineIsRounded,
+				LineJoin:    layoutConfig.lineJoin,
+			})
+		} else {
+			drawGradientCenterLine(GradientCenterLineParams{
+				SVG:         &axisSVG,
+				Bounds:      &timelineBounds,
+				Points:      axisPoints,
+				Width:       axisWidth,
+				LineType:    centerLineType,
+				RoundedCaps: layoutConfig.centerLineIsRounded,
+				LineJoin:    layoutConfig.lineJoin,
+			})
 		}
-		drawCenterLineSegment(DrawCenterLineSegmentParams{
-			SVG:         &svgBody,
+	} else if template.CenterLine.Smooth {
+		smoothPoints := append([]AxisPoint{segmentStartPoints[0]}, entryAxisPoints...)
+		drawSmoothCenterLine(SmoothCenterLineParams{
+			SVG:         &axisSVG,
 			Bounds:      &timelineBounds,
-			X1:          segmentStartPoints[i].X,
-			Y1:          segmentStartPoints[i].Y,
-			X2:          segmentEndPoints[i].X,
-			Y2:          segmentEndPoints[i].Y,
-			Color:       drawColor,
-			Width:       layoutConfig.centerLineWidth,
+			Points:      smoothPoints,
+			Colors:      segmentDrawColors,
+			Widths:      segmentDrawWidths,
 			LineType:    centerLineType,
 			RoundedCaps: layoutConfig.centerLineIsRounded,
+			LineJoin:    layoutConfig.lineJoin,
 		})
+	} else {
+		for i := range entries {
+			dashOffset := 0.0 // Segment i starts at the previous junction
+			if i > 0 {
+				dashOffset = timelineData.junctionPoints[i-1]
+			}
+			drawCenterLineSegment(DrawCenterLineSegmentParams{
+				SVG:         &axisSVG,
+				Bounds:      &timelineBounds,
+				X1:          segmentStartPoints[i].X,
+				Y1:          segmentStartPoints[i].Y,
+				X2:          segmentEndPoints[i].X,
+				Y2:          segmentEndPoints[i].Y,
+				Color:       segmentDrawColors[i],
+				Width:       segmentDrawWidths[i],
+				LineType:    centerLineType,
+				RoundedCaps: layoutConfig.centerLineIsRounded,
+				DashOffset:  dashOffset,
+			})
+		}
+	}
+	// Caps at the very start and end of the axis, over the line and following its direction
+	lastEntry := len(entries) - 1
+	startAngle, endAngle := entries[0].AngleOverride, entries[lastEntry].AngleOverride
+	if startAngle == nil {
+		startAngle = globalAxisAngle
+	}
+	if endAngle == nil {
+		endAngle = globalAxisAngle
+	}
+	drawAxisCap(&axisSVG, &timelineBounds, template.CenterLine.StartCap, segmentStartPoints[0], isHorizontal, startAngle, layoutConfig)
+	drawAxisCap(&axisSVG, &timelineBounds, template.CenterLine.EndCap, segmentEndPoints[lastEntry], isHorizontal, endAngle, layoutConfig)
+	if !template.CenterLine.DrawOnTop {
+		svgBody.Write(axisSVG.Bytes())
 	}
 
-	// --- Phase 3: Draw all Entries ON TOP ---
+	// --- Phase 2b: Draw date range bars on the axis, below the entries ---
 	for i, entry := range entries {
-		// Use the pre-calculated axis point for this entry
-		drawTimelineEntry(&svgBody, &timelineBounds, TimelineEntryParams{
-			Index:        i,
-			Entry:        entry,
-			Data:         timelineData,
-			EntryAxisX:   entryAxisPoints[i].X,
-			EntryAxisY:   entryAxisPoints[i].Y,
-			IsHorizontal: isHorizontal,
-			Config:       layoutConfig,
+		if entry.PeriodEnd == "" {
+			continue
+		}
+		barPoints, ok := periodBarPath(diag, entries, entryAxisPoints, i)
+		if !ok {
+			diag.warnf("unparseable_date", "entry %d: period '%s' to '%s' is not a date range, no bar drawn.", i, entry.Period, entry.PeriodEnd)
+			continue
+		}
+		drawPeriodBar(PeriodBarParams{
+			SVG:    &svgBody,
+			Bounds: &timelineBounds,
+			Points: barPoints,
+			Color:  segmentDrawColors[i],
+			Width:  segmentDrawWidths[i] * periodBarWidthFactor,
 		})
 	}
 
-	return assembleFinalSVG(svgBody, timelineBounds, layoutConfig.layoutPadding, template.GlobalFont), nil
+	// --- Phase 3: Draw all Entries ON TOP ---
+	entryLayouts := drawTimelineEntries(TimelineEntriesParams{
+		SVG:          &svgBody,
+		Bounds:       &timelineBounds,
+		Entries:      entries,
+		Data:         timelineData,
+		AxisPoints:   entryAxisPoints,
+		IsHorizontal: isHorizontal,
+		Config:       layoutConfig,
+	})
+
+	// --- Phase 3b: Center line over the entries, if requested ---
+	if template.CenterLine.DrawOnTop {
+		svgBody.Write(axisSVG.Bytes())
+	}
+
+	// --- Optional post-layout checks ---
+	if layoutConfig.warnOverlap {
+		warnOverlappingComments(diag, entryLayouts)
+	}
+
+	// --- Phase 4: Caption below everything, included in the bounds ---
+	if layoutConfig.caption != "" {
+		drawCaption(&svgBody, &timelineBounds, layoutConfig.caption, layoutConfig.captionFont)
+	}
+
+	// --- Phase 5: Debug overlay, drawn over everything without changing the bounds ---
+	if layoutConfig.debugBoxes {
+		drawDebugBoxes(&svgBody, entryLayouts, timelineBounds)
+	}
+
+	svg := assembleFinalSVG(&svgBody, timelineBounds, layoutConfig, template.GlobalFont)
+	// Built after assembly so its warnings (e.g. canvas_scaled) are included
+	layout := timelineLayout{entries: entries, entryLayouts: entryLayouts, canvas: computeCanvasGeometry(timelineBounds, layoutConfig), warnings: diag.warnings}
+	return svg, layout, nil
 }
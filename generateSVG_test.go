@@ -0,0 +1,1854 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// svgHeight reads the canvas height from the root <svg> element.
+func svgHeight(t *testing.T, svg string) float64 {
+	t.Helper()
+	var width, height float64
+	if _, err := fmt.Sscanf(svg, `<svg width="%g" height="%g"`, &width, &height); err != nil {
+		t.Fatalf("Could not read canvas size: %v", err)
+	}
+	return height
+}
+
+// loadTestTemplate loads the shared test template from testdata.
+func loadTestTemplate(t *testing.T) Template {
+	t.Helper()
+	templateBytes, err := os.ReadFile("testdata/test1.tmpl.json")
+	if err != nil {
+		t.Fatalf("Error reading test template: %v", err)
+	}
+	var template Template
+	if err := json.Unmarshal(templateBytes, &template); err != nil {
+		t.Fatalf("Error unmarshalling test template: %v", err)
+	}
+	return template
+}
+
+// TestHiddenEntryClosesGap verifies a hidden middle entry renders exactly like it was never there.
+func TestHiddenEntryClosesGap(t *testing.T) {
+	template := loadTestTemplate(t)
+	withHidden := []TimelineEntry{
+		{Period: "2001", CommentText: "first"},
+		{Period: "2002", CommentText: "hidden", Hidden: true},
+		{Period: "2003", CommentText: "third"},
+	}
+	withoutHidden := []TimelineEntry{withHidden[0], withHidden[2]}
+
+	got, err := GenerateSVG(template, withHidden)
+	if err != nil {
+		t.Fatalf("GenerateSVG with hidden entry failed: %v", err)
+	}
+	want, err := GenerateSVG(template, withoutHidden)
+	if err != nil {
+		t.Fatalf("GenerateSVG without hidden entry failed: %v", err)
+	}
+	if got != want {
+		t.Errorf("Hidden entry changed the layout; expected output identical to omitting it")
+	}
+}
+
+// TestFixedCommentBlockHeightClipsBody verifies block_height fixes the box height and clips the body.
+func TestFixedCommentBlockHeightClipsBody(t *testing.T) {
+	template := loadTestTemplate(t)
+	blockHeight := 60.0
+	template.PeriodDefaults.CommentText.BlockHeight = &blockHeight
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: strings.Repeat("Long body text. ", 40)}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, `width="150.00" height="60.00" fill="none" stroke="red"`) {
+		t.Errorf("Expected the comment rect to use the fixed 60px height")
+	}
+	if !strings.Contains(svg, `<clipPath id="entry-2001-body-clip">`) || !strings.Contains(svg, `clip-path="url(#entry-2001-body-clip)"`) {
+		t.Errorf("Expected the foreignObject to be clipped by a clipPath")
+	}
+}
+
+// TestAngledAxisPlacesElementsPerpendicular checks elements sit perpendicular to a 45° axis.
+func TestAngledAxisPlacesElementsPerpendicular(t *testing.T) {
+	template := loadTestTemplate(t)
+	angle := 45.0
+	template.CenterLine.Angle = &angle
+	entries := []TimelineEntry{{Period: "2001"}, {Period: "2002"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	// The first entry sits on the axis origin; its year is placed 55px (connector length)
+	// on the start side, i.e. along (sin45, -cos45) rather than straight up.
+	if !strings.Contains(svg, `<circle cx="38.89" cy="-38.89" r="30.00"`) {
+		t.Errorf("Expected the year circle perpendicular to the 45° axis at (38.89, -38.89)")
+	}
+}
+
+// TestSmoothCenterLineUsesSinglePath verifies a uniformly colored smooth axis is one curved path.
+func TestSmoothCenterLineUsesSinglePath(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Smooth = true
+	entries := []TimelineEntry{{Period: "2001"}, {Period: "2002"}, {Period: "2003"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if n := strings.Count(svg, `<path d="M `); n != 1 {
+		t.Errorf("Expected a single smooth center line path, got %d", n)
+	}
+	if !strings.Contains(svg, ` C `) {
+		t.Errorf("Expected the center line path to use cubic bezier segments")
+	}
+}
+
+// TestTransparentLayoutOmitsBackground verifies layout.transparent drops the white background rect.
+func TestTransparentLayoutOmitsBackground(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.Transparent = true
+
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, `fill="#FFFFFF" />`) {
+		t.Errorf("Expected no white background rect for a transparent layout")
+	}
+}
+
+// TestStrokeScaleMultipliesStrokeWidths verifies layout.stroke_scale scales strokes without moving anything.
+func TestStrokeScaleMultipliesStrokeWidths(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002"}}
+
+	unscaled, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	template.Layout.StrokeScale = 2
+	scaled, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG with stroke_scale failed: %v", err)
+	}
+
+	for _, want := range []string{
+		`stroke-width="24.00" stroke-linecap="round"`, // Center line (12)
+		`stroke="#BDBDBD" stroke-width="4.00"`,        // Connector (2)
+		`stroke="red" stroke-width="2.00"`,            // Comment border (1)
+		`stroke-width="6.00"/>`,                       // Year border (3)
+	} {
+		if !strings.Contains(scaled, want) {
+			t.Errorf("Expected scaled output to contain %q", want)
+		}
+	}
+	if strings.Count(scaled, "stroke-width") != strings.Count(unscaled, "stroke-width") {
+		t.Errorf("Stroke scaling should not add or remove elements")
+	}
+}
+
+// TestInlineCommentCenteredOnAxis verifies an inline comment is centered on the axis point without a connector.
+func TestInlineCommentCenteredOnAxis(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Orientation = "horizontal"
+	template.PeriodDefaults.CommentText.Position = "inline"
+	blockHeight := 40.0
+	template.PeriodDefaults.CommentText.BlockHeight = &blockHeight
+	template.PeriodDefaults.Connector.DrawToPeriod = new(bool) // Only the comment connector could remain
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	// Block width 150 (130 + padding) and fixed height 40, centered on axis point (0, 0)
+	if !strings.Contains(svg, `<rect x="-75.00" y="-20.00" width="150.00" height="40.00"`) {
+		t.Errorf("Expected the inline comment block to be centered on the axis point")
+	}
+	if strings.Contains(svg, `stroke="#BDBDBD" stroke-width="2.00"`) {
+		t.Errorf("Expected no connector for an inline comment")
+	}
+}
+
+// TestPatternFillEmitsDefs verifies "pattern:" fills reference a deterministic pattern def.
+func TestPatternFillEmitsDefs(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.CommentText.FillColor = "pattern:hatch"
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002", CommentText: "Body"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Count(svg, `<pattern id="pattern-hatch"`) != 1 {
+		t.Errorf("Expected exactly one hatch pattern def")
+	}
+	if !strings.Contains(svg, `fill="url(#pattern-hatch)"`) {
+		t.Errorf("Expected the comment background to reference the hatch pattern")
+	}
+	if strings.Contains(svg, `pattern-dots`) {
+		t.Errorf("Unused patterns should not be emitted")
+	}
+}
+
+// TestPrecisionControlsCoordinateDecimals verifies layout.precision changes the number of decimals written.
+func TestPrecisionControlsCoordinateDecimals(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002"}}
+
+	zero := 0
+	template.Layout.Precision = &zero
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, `.00"`) {
+		t.Errorf("Expected no decimals with precision 0")
+	}
+
+	template.Layout.Precision = nil
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, `.00"`) {
+		t.Errorf("Expected two decimals by default")
+	}
+}
+
+// TestConcurrentRendersKeepTheirSettings checks renders running at the same time each write
+// with their own layout settings.
+func TestConcurrentRendersKeepTheirSettings(t *testing.T) {
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002"}}
+	variants := []func(template *Template){
+		func(template *Template) { zero := 0; template.Layout.Precision = &zero },
+		func(template *Template) { three := 3; template.Layout.Precision = &three },
+		func(template *Template) { template.Layout.ColorMap = map[string]string{"#BDBDBD": "#FF0000"} },
+		func(template *Template) { template.Layout.ColorMap = map[string]string{"#BDBDBD": "#0000FF"} },
+		func(template *Template) { template.Layout.Jitter, template.Layout.Seed = 3, 1 },
+		func(template *Template) { template.Layout.Jitter, template.Layout.Seed = 3, 2 },
+		func(template *Template) { template.CenterLine.Smooth, template.Layout.LineJoin = true, "round" },
+		func(template *Template) { template.CenterLine.Smooth, template.Layout.LineJoin = true, "bevel" },
+	}
+	templates := make([]Template, len(variants))
+	expected := make([]string, len(templates))
+	for i, variant := range variants {
+		templates[i] = loadTestTemplate(t)
+		variant(&templates[i])
+		svg, err := GenerateSVG(templates[i], entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		expected[i] = svg
+	}
+
+	var wg sync.WaitGroup
+	results := make([]string, len(templates))
+	for i := range templates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i], _ = GenerateSVG(templates[i], entries)
+		}()
+	}
+	wg.Wait()
+	for i := range templates {
+		if results[i] != expected[i] {
+			t.Errorf("Expected concurrent render %d to match its own render", i)
+		}
+	}
+}
+
+// TestPeriodEndDrawsBarToInterpolatedDate verifies a date range bar ends between the neighbouring dated entries.
+func TestPeriodEndDrawsBarToInterpolatedDate(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Orientation = "horizontal"
+	spacing := 100.0
+	template.Layout.EntrySpacing = spacing
+	entries := []TimelineEntry{
+		{Period: "2000", PeriodEnd: "2005"},
+		{Period: "2010"},
+	}
+	pointEntries := []TimelineEntry{{Period: "2000"}, {Period: "2010"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	// Entries sit 100px apart, so 2005 is halfway: from x=0 to x=50
+	if !strings.Contains(svg, `<polyline points="0.00,0.00 50.00,0.00"`) {
+		t.Errorf("Expected a bar from the 2000 entry to the 2005 position")
+	}
+
+	plain, err := GenerateSVG(template, pointEntries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(plain, "<polyline") {
+		t.Errorf("Point entries should not draw a bar")
+	}
+}
+
+// TestJunctionMarkerBorder verifies the marker outline is drawn only when configured.
+func TestJunctionMarkerBorder(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.JunctionMarker.Shape = "circle"
+	entries := []TimelineEntry{{Period: "2001"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, `stroke="#000000"`) {
+		t.Errorf("Expected no marker outline by default")
+	}
+
+	template.PeriodDefaults.JunctionMarker.BorderColor = "#000000"
+	template.PeriodDefaults.JunctionMarker.BorderWidth = 1.5
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, `stroke="#000000" stroke-width="1.50" />`) {
+		t.Errorf("Expected the marker to be outlined")
+	}
+}
+
+// TestCaptionGrowsCanvasAndRendersLinks verifies the caption adds height and turns markdown links into <a>.
+func TestCaptionGrowsCanvasAndRendersLinks(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001"}, {Period: "2002"}}
+
+	plain, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	template.Layout.Caption = "Source: [Archive](https://example.org/a)"
+	captioned, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG with caption failed: %v", err)
+	}
+
+	if svgHeight(t, captioned) <= svgHeight(t, plain) {
+		t.Errorf("Expected the caption to increase the canvas height")
+	}
+	want := `Source: <a href="https://example.org/a" target="_blank"><tspan text-decoration="underline">Archive</tspan></a>`
+	if !strings.Contains(captioned, want) {
+		t.Errorf("Expected caption markup %q in SVG", want)
+	}
+}
+
+// TestCenterLineDrawOnTopReordersWithoutChangingBounds verifies draw_on_top moves the axis after the entries.
+func TestCenterLineDrawOnTopReordersWithoutChangingBounds(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002"}}
+
+	below, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	template.CenterLine.DrawOnTop = true
+	onTop, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG with draw_on_top failed: %v", err)
+	}
+
+	axisStroke := `stroke-width="12.00" stroke-linecap="round"` // The test template's center line
+	if strings.Index(below, axisStroke) > strings.Index(below, `<g id="entry-`) {
+		t.Errorf("Expected the center line before the entries by default")
+	}
+	if strings.Index(onTop, axisStroke) < strings.LastIndex(onTop, `<g id="entry-`) {
+		t.Errorf("Expected the center line after the entries with draw_on_top")
+	}
+	if !strings.HasPrefix(onTop, below[:strings.Index(below, "\n")]) {
+		t.Errorf("Expected identical canvas size, got %q", onTop[:strings.Index(onTop, "\n")])
+	}
+}
+
+// TestCommentImagePosition verifies image_position controls image order and float in the comment body.
+func TestCommentImagePosition(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body", CommentImage: "https://example.org/a.png"}}
+
+	template.PeriodDefaults.CommentText.ImagePosition = "right"
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, "float: right;") || !strings.Contains(svg, `<div style="clear: both;"></div>`) {
+		t.Errorf("Expected a right-floated image followed by a clearing div")
+	}
+
+	template.PeriodDefaults.CommentText.ImagePosition = "bottom"
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Index(svg, "<img ") < strings.Index(svg, "Body\n") {
+		t.Errorf("Expected the image after the body text for image_position bottom")
+	}
+}
+
+// TestColorMapRemapsCenterLineColor verifies layout.color_map replaces colors case-insensitively.
+func TestColorMapRemapsCenterLineColor(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.ColorMap = map[string]string{"#bdbdbd": "#112233"} // The test template's axis color
+
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}, {Period: "2002"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(strings.ToLower(svg), "#bdbdbd") {
+		t.Errorf("Expected the mapped color to be replaced everywhere")
+	}
+	if !strings.Contains(svg, `stroke="#112233" stroke-width="12.00"`) {
+		t.Errorf("Expected the center line to use the mapped color")
+	}
+}
+
+// TestImportanceEnlargesJunctionMarker verifies a high-importance entry gets a larger marker.
+func TestImportanceEnlargesJunctionMarker(t *testing.T) {
+	template := loadTestTemplate(t) // Diamond markers of size 18
+	importance := 4.0
+
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", Importance: &importance}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	// Default scale 0.25: importance 4 doubles the size to 36
+	if !strings.Contains(svg, `<polygon points="0.00,18.00 -18.00,0.00 18.00,0.00"`) {
+		t.Errorf("Expected a doubled diamond marker for importance 4")
+	}
+}
+
+// TestCustomDefsShareDefsBlockWithPatterns verifies layout.custom_defs is injected verbatim in one <defs>.
+func TestCustomDefsShareDefsBlockWithPatterns(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, "<defs>\n") {
+		t.Errorf("Expected no top-level <defs> without custom defs or patterns")
+	}
+
+	customDefs := `<linearGradient id="myGrad"><stop offset="0" stop-color="#f00"/></linearGradient>`
+	template.Layout.CustomDefs = customDefs
+	template.PeriodDefaults.CommentText.FillColor = "pattern:dots"
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, "  <defs>\n"+customDefs+"\n    <pattern id=\"pattern-dots\"") {
+		t.Errorf("Expected the custom defs followed by the dots pattern in a single <defs> block")
+	}
+	if strings.Count(svg, "  <defs>\n") != 1 {
+		t.Errorf("Expected exactly one top-level <defs> block")
+	}
+}
+
+// TestURLFillReferencesPassThrough verifies url(#...) values reach the SVG verbatim, even when minified.
+func TestURLFillReferencesPassThrough(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.YearText.FillColor = "url(#g)"
+	template.PeriodDefaults.CommentText.FillColor = "url(#g1.50)"
+	template.PeriodDefaults.Connector.Color = "url(#g)"
+	template.Layout.ColorMap = map[string]string{"url(#g)": "#000000"} // References are never remapped
+
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	for _, want := range []string{`fill="url(#g)"`, `stroke="url(#g)"`, `fill="url(#g1.50)"`} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected %s in SVG", want)
+		}
+	}
+	if !strings.Contains(minifySVG(svg), `fill="url(#g1.50)"`) {
+		t.Errorf("Expected minify to leave url(#g1.50) untouched")
+	}
+}
+
+// TestBaselineCompatShiftsYearText verifies layout.baseline_compat replaces dominant-baseline with a y shift.
+func TestBaselineCompatShiftsYearText(t *testing.T) {
+	template := loadTestTemplate(t) // Year font size 15
+	entries := []TimelineEntry{{Period: "2001"}}
+	yearTextY := func(svg string) float64 {
+		t.Helper()
+		match := regexp.MustCompile(`<text x="[^"]*" y="([^"]*)"[^>]*>2001</text>`).FindStringSubmatch(svg)
+		if match == nil {
+			t.Fatalf("Year text not found")
+		}
+		y, err := strconv.ParseFloat(match[1], 64)
+		if err != nil {
+			t.Fatalf("Bad year y %q: %v", match[1], err)
+		}
+		return y
+	}
+
+	plain, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	template.Layout.BaselineCompat = true
+	compat, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG with baseline_compat failed: %v", err)
+	}
+
+	if strings.Contains(compat, `dominant-baseline="middle"`) {
+		t.Errorf("Expected no dominant-baseline on year text in compat mode")
+	}
+	if shift := yearTextY(compat) - yearTextY(plain); math.Abs(shift-5.25) > 0.01 {
+		t.Errorf("Expected the year text moved down by half the ascent (5.25), got %.2f", shift)
+	}
+}
+
+// TestCommentTailPointsTowardAxis verifies the tail tip lies between the box and the axis
+// for both sides of horizontal and vertical timelines.
+func TestCommentTailPointsTowardAxis(t *testing.T) {
+	for _, orientation := range []string{"horizontal", "vertical"} {
+		template := loadTestTemplate(t)
+		template.CenterLine.Orientation = orientation
+		template.PeriodDefaults.CommentText.Tail = true
+		entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002", CommentText: "Body"}}
+
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		tails := regexp.MustCompile(`<polyline points="([-\d.]+),([-\d.]+) ([-\d.]+),([-\d.]+) [-\d.]+,[-\d.]+" fill="none" stroke="red"`).FindAllStringSubmatch(svg, -1)
+		if len(tails) != 2 {
+			t.Fatalf("%s: expected 2 tails, got %d", orientation, len(tails))
+		}
+		for _, tail := range tails {
+			coord := 2 // Distance from a horizontal axis is |y|, from a vertical one |x|
+			if orientation == "vertical" {
+				coord = 1
+			}
+			base, _ := strconv.ParseFloat(tail[coord], 64)
+			tip, _ := strconv.ParseFloat(tail[coord+2], 64)
+			if math.Abs(tip) >= math.Abs(base) || math.Abs(math.Abs(base)-math.Abs(tip)-10) > 0.01 {
+				t.Errorf("%s: expected the tip 10px closer to the axis than the base, got base %.2f tip %.2f", orientation, base, tip)
+			}
+		}
+	}
+}
+
+// TestSpacingScaleLogEvensOutWideDateGaps compares linear and log date spacing for
+// dates whose gaps range from one year to a millennium.
+func TestSpacingScaleLogEvensOutWideDateGaps(t *testing.T) {
+	entries := []TimelineEntry{{Period: "1900"}, {Period: "1901"}, {Period: "2000"}, {Period: "3000"}}
+	gaps := func(scale string) []float64 {
+		t.Helper()
+		template := loadTestTemplate(t)
+		template.Layout.SpacingScale = scale
+		config := initializeLayoutConfig(template)
+		data := calculateTimelinePositionsAndStyles(entries, template, config)
+		result := make([]float64, len(entries)-1)
+		for i := range result {
+			result[i] = data.junctionPoints[i+1] - data.junctionPoints[i]
+		}
+		return result
+	}
+
+	linear := gaps("linear")
+	logGaps := gaps("log")
+	if ratio := linear[2] / linear[1]; math.Abs(ratio-1000.0/99.0) > 0.01 {
+		t.Errorf("Expected linear gaps proportional to the date deltas, got ratio %.3f", ratio)
+	}
+	if linear[0] >= linear[1] || logGaps[0] >= logGaps[1] || logGaps[1] >= logGaps[2] {
+		t.Errorf("Expected gaps to grow with the date deltas, got linear %v, log %v", linear, logGaps)
+	}
+	linearSpread := linear[2] / linear[0]
+	logSpread := logGaps[2] / logGaps[0]
+	if logSpread >= linearSpread/2 { // Linear is capped by the minimum gap, so compare loosely
+		t.Errorf("Expected log gaps far more even than linear (spread %.1f vs %.1f)", logSpread, linearSpread)
+	}
+
+	// Out-of-order dates must not produce negative gaps
+	entries[1].Period = "1800"
+	for _, scale := range []string{"linear", "log"} {
+		for _, gap := range gaps(scale) {
+			if gap <= 0 {
+				t.Errorf("Expected positive gaps for out-of-order dates in %s mode, got %v", scale, gap)
+			}
+		}
+	}
+}
+
+// TestMultiWordFontFamiliesAreQuoted checks year, title and body text quote "Times New Roman".
+func TestMultiWordFontFamiliesAreQuoted(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.YearText.Font.FontFamily = "Times New Roman, serif"
+	template.PeriodDefaults.CommentText.TitleFont.FontFamily = "Times New Roman, serif"
+	template.PeriodDefaults.CommentText.Font.FontFamily = `"Times New Roman", serif`
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if count := strings.Count(svg, `font-family="'Times New Roman', serif"`); count != 2 {
+		t.Errorf("Expected quoted font-family on the year and title text, found %d", count)
+	}
+	if !strings.Contains(svg, "font-family:'Times New Roman', serif;") {
+		t.Errorf("Expected quoted font-family in the comment body style")
+	}
+	if strings.Contains(svg, "Times New Roman, serif") {
+		t.Errorf("Expected no unquoted multi-word family in the output")
+	}
+}
+
+// TestNegativeConnectorLengthFlipsSide checks a negative connector length puts the first
+// comment above the axis (instead of below), growing away from it, with the connector ending
+// on the box edge facing the axis.
+func TestNegativeConnectorLengthFlipsSide(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.ConnectorLength = -80
+	drawToComment := true
+	template.PeriodDefaults.Connector.DrawToComment = &drawToComment
+
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	box := regexp.MustCompile(`<rect x="[^"]*" y="([^"]*)" width="[^"]*" height="([^"]*)" fill="none" stroke="red"`).FindStringSubmatch(svg)
+	if box == nil {
+		t.Fatalf("Comment box not found")
+	}
+	top, _ := strconv.ParseFloat(box[1], 64)
+	height, _ := strconv.ParseFloat(box[2], 64)
+	if math.Abs(top+height+80) > 0.01 {
+		t.Errorf("Expected the box to end 80px above the axis, got y %.2f height %.2f", top, height)
+	}
+	if !strings.Contains(svg, `<line x1="0.00" y1="-80.00" x2="0.00" y2="0.00"`) {
+		t.Errorf("Expected the comment connector to start at the box edge facing the axis")
+	}
+	if !strings.Contains(svg, `<text x="0.00" y="80.00"`) {
+		t.Errorf("Expected the year flipped below the axis")
+	}
+}
+
+// TestCenterlineProjectionWidthWidensSegment checks a per-entry width only affects the segment
+// leading into that entry, for both straight and smooth center lines.
+func TestCenterlineProjectionWidthWidensSegment(t *testing.T) {
+	wide := 20.0
+	entries := []TimelineEntry{
+		{Period: "2001"},
+		{Period: "2002", CenterlineProjectionOverride: &CenterlineProjectionStyle{Width: &wide}},
+		{Period: "2003"},
+	}
+	for _, smooth := range []bool{false, true} {
+		template := loadTestTemplate(t) // center_line.width 12
+		template.CenterLine.Smooth = smooth
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		if got := strings.Count(svg, `stroke-width="20.00"`); got != 1 {
+			t.Errorf("smooth=%v: expected one 20px segment, got %d", smooth, got)
+		}
+		if !strings.Contains(svg, `stroke-width="12.00"`) {
+			t.Errorf("smooth=%v: expected the other segments to keep the 12px center line width", smooth)
+		}
+	}
+}
+
+// TestThumbnailDrawsClippedImageOnAxis checks a local thumbnail is embedded once, clipped to a
+// circle on the axis point with a deterministic id, and that entries without one get nothing.
+func TestThumbnailDrawsClippedImageOnAxis(t *testing.T) {
+	imagePath := filepath.Join(t.TempDir(), "photo.png")
+	if err := os.WriteFile(imagePath, []byte("png"), 0o644); err != nil {
+		t.Fatalf("Could not write image: %v", err)
+	}
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{
+		{Period: "2001", ThumbnailImage: imagePath, ThumbnailSize: 40, ThumbnailBorderWidth: 2, ThumbnailBorderColor: "#123456"},
+		{Period: "2002"},
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	for _, want := range []string{
+		`<clipPath id="entry-2001-thumbnail-clip"><circle cx="0.00" cy="0.00" r="20.00"/></clipPath>`,
+		`<image x="-20.00" y="-20.00" width="40.00" height="40.00" href="data:image/png;base64,cG5n"`,
+		`clip-path="url(#entry-2001-thumbnail-clip)"`,
+		`<circle cx="0.00" cy="0.00" r="20.00" fill="none" stroke="#123456" stroke-width="2.00"/>`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected %q in the SVG", want)
+		}
+	}
+	if got := strings.Count(svg, "<image "); got != 1 {
+		t.Errorf("Expected only the first entry to get a thumbnail, got %d images", got)
+	}
+}
+
+// TestDotElbowAtChoosesBendPosition checks the dogleg bends at the dot's level by default
+// and at the element's level with elbow_at "axis".
+func TestDotElbowAtChoosesBendPosition(t *testing.T) {
+	tests := []struct {
+		elbowAt string
+		legs    []string
+	}{
+		{"", []string{`<line x1="30.00" y1="-60.00" x2="30.00" y2="0.00"`, `<line x1="30.00" y1="0.00" x2="0.00" y2="0.00"`}},
+		{"element", []string{`<line x1="30.00" y1="-60.00" x2="30.00" y2="0.00"`, `<line x1="30.00" y1="0.00" x2="0.00" y2="0.00"`}},
+		{"axis", []string{`<line x1="30.00" y1="-60.00" x2="0.00" y2="-60.00"`, `<line x1="0.00" y1="-60.00" x2="0.00" y2="0.00"`}},
+	}
+	for _, tt := range tests {
+		template := loadTestTemplate(t)
+		template.PeriodDefaults.YearText.MainAxisOffset = 30 // Year at (30, -60), dot on the axis point
+		template.PeriodDefaults.YearText.CrossAxisOffset = 5 // Any element offset makes a dogleg
+		template.PeriodDefaults.Connector.Dot.ElbowAt = tt.elbowAt
+
+		svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}})
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		for _, leg := range tt.legs {
+			if !strings.Contains(svg, leg) {
+				t.Errorf("elbow_at %q: expected leg %s", tt.elbowAt, leg)
+			}
+		}
+	}
+}
+
+// TestFocusRingSurroundsEntry checks the focus ring encloses the focused entry's comment box
+// and year, and that other entries get none.
+func TestFocusRingSurroundsEntry(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.FocusColor = "#00AA00"
+	entries := []TimelineEntry{
+		{Period: "2001", CommentText: "Body", Focus: true},
+		{Period: "2002", CommentText: "Body"},
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	rectRegex := `<rect %sx="([-\d.]+)" y="([-\d.]+)" width="([-\d.]+)" height="([-\d.]+)"[^>]*%s`
+	rings := regexp.MustCompile(fmt.Sprintf(rectRegex, `class="timeline-focus" `, `stroke="#00AA00"`)).FindAllStringSubmatch(svg, -1)
+	if len(rings) != 1 {
+		t.Fatalf("Expected one focus ring, got %d", len(rings))
+	}
+	box := regexp.MustCompile(fmt.Sprintf(rectRegex, "", `stroke="red"`)).FindStringSubmatch(svg) // First entry's comment box
+	if box == nil {
+		t.Fatalf("Comment box not found")
+	}
+	values := func(match []string) (x, y, w, h float64) {
+		x, _ = strconv.ParseFloat(match[1], 64)
+		y, _ = strconv.ParseFloat(match[2], 64)
+		w, _ = strconv.ParseFloat(match[3], 64)
+		h, _ = strconv.ParseFloat(match[4], 64)
+		return
+	}
+	rx, ry, rw, rh := values(rings[0])
+	bx, by, bw, bh := values(box)
+	if rx >= bx || ry >= by || rx+rw <= bx+bw || ry+rh <= by+bh {
+		t.Errorf("Expected the ring (%.0f,%.0f %.0fx%.0f) around the comment box (%.0f,%.0f %.0fx%.0f)", rx, ry, rw, rh, bx, by, bw, bh)
+	}
+	if ry >= -30 { // The year circle sits above the axis (radius 30 at y -55)
+		t.Errorf("Expected the ring to reach the year above the axis, top is %.2f", ry)
+	}
+}
+
+// TestEntryLayoutsHoldOwnBounds checks every entry reports a non-empty bounding box that lies
+// inside the timeline bounds and contains the entry's axis point.
+func TestEntryLayoutsHoldOwnBounds(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{
+		{Period: "2001", CommentText: "Body"},
+		{Period: "2002", TitleText: "Title"},
+		{Period: "2003"},
+	}
+	config := initializeLayoutConfig(template)
+	data := calculateTimelinePositionsAndStyles(entries, template, config)
+	axisPoints := make([]AxisPoint, len(entries))
+	for i := range entries {
+		axisPoints[i] = AxisPoint{X: data.junctionPoints[i]} // Straight horizontal axis
+	}
+
+	var svg svgBuffer
+	var timelineBounds bounds
+	layouts := drawTimelineEntries(TimelineEntriesParams{
+		SVG:          &svg,
+		Bounds:       &timelineBounds,
+		Entries:      entries,
+		Data:         data,
+		AxisPoints:   axisPoints,
+		IsHorizontal: true,
+		Config:       config,
+	})
+
+	for i, layout := range layouts {
+		b := layout.Bounds
+		if !b.isSet || b.maxX <= b.minX || b.maxY <= b.minY {
+			t.Errorf("Entry %d: expected a non-empty bounding box, got %+v", i, b)
+			continue
+		}
+		if b.minX < timelineBounds.minX || b.maxX > timelineBounds.maxX || b.minY < timelineBounds.minY || b.maxY > timelineBounds.maxY {
+			t.Errorf("Entry %d: box %+v is not within the timeline bounds %+v", i, b, timelineBounds)
+		}
+		if b.minX > axisPoints[i].X || b.maxX < axisPoints[i].X {
+			t.Errorf("Entry %d: expected the box to contain its axis point %.2f, got %+v", i, axisPoints[i].X, b)
+		}
+	}
+}
+
+// TestEntrySidesForceCommentAndYearTogether checks both elements of an entry can be put above the axis.
+func TestEntrySidesForceCommentAndYearTogether(t *testing.T) {
+	template := loadTestTemplate(t)
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentSide: "start", YearSide: "start"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	box := regexp.MustCompile(`<rect x="[^"]*" y="([^"]*)" width="[^"]*" height="([^"]*)" fill="none" stroke="red"`).FindStringSubmatch(svg)
+	if box == nil {
+		t.Fatalf("Comment box not found")
+	}
+	top, _ := strconv.ParseFloat(box[1], 64)
+	height, _ := strconv.ParseFloat(box[2], 64)
+	if top+height > 0 {
+		t.Errorf("Expected the first comment moved above the axis, got y %.2f height %.2f", top, height)
+	}
+	if !strings.Contains(svg, `<text x="0.00" y="-55.00"`) {
+		t.Errorf("Expected the year to stay above the axis as well")
+	}
+}
+
+// TestModernImageFormatsEmbedWithMimeAndWarning checks WebP/AVIF get their MIME types in the
+// data URI and are reported as possibly unsupported outside browsers.
+func TestModernImageFormatsEmbedWithMimeAndWarning(t *testing.T) {
+	for ext, want := range map[string]string{".webp": "image/webp", ".avif": "image/avif", ".PNG": "image/png"} {
+		if got := getMimeType("photo" + ext); got != want {
+			t.Errorf("getMimeType(photo%s) = %q, want %q", ext, got, want)
+		}
+	}
+
+	render := &renderContext{diagnostics: &diagnosticLog{}}
+	imagePath := filepath.Join(t.TempDir(), "photo.webp")
+	if err := os.WriteFile(imagePath, []byte("webp"), 0o644); err != nil {
+		t.Fatalf("Could not write image: %v", err)
+	}
+	if got := render.embedImageSource(imagePath); got != "data:image/webp;base64,d2VicA==" {
+		t.Errorf("Unexpected data URI %q", got)
+	}
+	if warnings := render.diagnostics.warnings; len(warnings) != 1 || warnings[0].Code != "image_format_support" {
+		t.Errorf("Expected one image_format_support warning, got %+v", warnings)
+	}
+}
+
+// TestMissingImageReportedByEveryRender checks the image cache lives for one render: a missing
+// image shared by two entries is reported once, and again by the next render.
+func TestMissingImageReportedByEveryRender(t *testing.T) {
+	template := loadTestTemplate(t)
+	missing := filepath.Join(t.TempDir(), "missing.png")
+	entries := []TimelineEntry{
+		{Period: "2001", CommentText: "Body", CommentImage: missing},
+		{Period: "2002", CommentText: "Body", CommentImage: missing},
+	}
+	for render := 1; render <= 2; render++ {
+		_, layout, err := generateSVG(template, entries, nil)
+		if err != nil {
+			t.Fatalf("generateSVG failed: %v", err)
+		}
+		reported := 0
+		for _, warning := range layout.warnings {
+			if warning.Code == "missing_image" {
+				reported++
+			}
+		}
+		if reported != 1 {
+			t.Errorf("Render %d: expected one missing_image warning, got %+v", render, layout.warnings)
+		}
+	}
+}
+
+// TestNumberEntriesDrawsCenteredBadges checks each entry gets its 1-based number centered on
+// the axis point, with the badge widening for double digits.
+func TestNumberEntriesDrawsCenteredBadges(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.NumberEntries = true
+	template.Layout.NumberStyle.FillColor = "#333333"
+	entries := make([]TimelineEntry, 10)
+	for i := range entries {
+		entries[i] = TimelineEntry{Period: strconv.Itoa(2001 + i)}
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	badges := regexp.MustCompile(`<rect class="timeline-number" x="([-\d.]+)" y="[-\d.]+" width="([\d.]+)" height="18.00"[^>]*fill="#333333"/>\s*<text x="([-\d.]+)"[^>]*>(\d+)</text>`).FindAllStringSubmatch(svg, -1)
+	if len(badges) != len(entries) {
+		t.Fatalf("Expected %d badges, got %d", len(entries), len(badges))
+	}
+	for i, badge := range badges {
+		x, _ := strconv.ParseFloat(badge[1], 64)
+		width, _ := strconv.ParseFloat(badge[2], 64)
+		textX, _ := strconv.ParseFloat(badge[3], 64)
+		if badge[4] != strconv.Itoa(i+1) {
+			t.Errorf("Badge %d: expected number %d, got %s", i, i+1, badge[4])
+		}
+		if math.Abs(x+width/2-textX) > 0.01 || math.Abs(textX-float64(i)*260) > 0.01 {
+			t.Errorf("Badge %d: expected the number centered on the axis point, got rect x %.2f width %.2f text x %.2f", i, x, width, textX)
+		}
+		if (i < 9 && width != 18) || (i == 9 && width <= 18) {
+			t.Errorf("Badge %d: unexpected width %.2f for %s", i, width, badge[4])
+		}
+	}
+
+	template.Layout.NumberEntries = false
+	if svg, _ := GenerateSVG(template, entries); strings.Contains(svg, "timeline-number") {
+		t.Errorf("Expected no badges with number_entries off")
+	}
+}
+
+// TestAsymmetricCanvasPadding checks per-side padding changes the canvas size and the content
+// offset, with padding_top/left winning over padding_string.
+func TestAsymmetricCanvasPadding(t *testing.T) {
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}}
+	canvas := func(template Template) (width, height, offsetX, offsetY float64) {
+		t.Helper()
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		if _, err := fmt.Sscanf(svg, `<svg width="%g" height="%g"`, &width, &height); err != nil {
+			t.Fatalf("Could not read canvas size: %v", err)
+		}
+		match := regexp.MustCompile(`<g transform="translate\(([-\d.]+), ([-\d.]+)\)">`).FindStringSubmatch(svg)
+		if match == nil {
+			t.Fatalf("Content translate not found")
+		}
+		offsetX, _ = strconv.ParseFloat(match[1], 64)
+		offsetY, _ = strconv.ParseFloat(match[2], 64)
+		return
+	}
+
+	template := loadTestTemplate(t)
+	template.Layout.Padding = 50
+	baseW, baseH, baseX, baseY := canvas(template)
+
+	top, left := 5.0, 80.0
+	template.Layout.PaddingString = "10 40 30"
+	template.Layout.PaddingTop = &top
+	template.Layout.PaddingLeft = &left
+	w, h, x, y := canvas(template) // top 5, right 40, bottom 30, left 80
+
+	// Canvas sizes are rounded to whole pixels
+	if math.Abs(w-baseW-((80+40)-100)) > 1 || math.Abs(h-baseH-((5+30)-100)) > 1 {
+		t.Errorf("Expected the canvas to change by the padding difference, got %.0fx%.0f vs %.0fx%.0f", w, h, baseW, baseH)
+	}
+	if x-baseX != 80-50 || y-baseY != 5-50 {
+		t.Errorf("Expected the content offset to follow the left/top padding, got (%.2f, %.2f) vs (%.2f, %.2f)", x, y, baseX, baseY)
+	}
+}
+
+// TestMaxBlockWidthShrinksToContentAndWraps checks max_block_width keeps short bodies narrow
+// and wraps (taller box) once the body would exceed the max.
+func TestMaxBlockWidthShrinksToContentAndWraps(t *testing.T) {
+	maxWidth := 200.0
+	style := CommentTextStyle{Font: FontStyle{FontSize: 12}, MaxBlockWidth: &maxWidth}
+
+	short := calculateCommentBlockLayout(nil, CommentParams{Style: style, BodyText: "Short", IsHorizontal: true, CrossAxisDir: 1})
+	if want := estimateTextSVGWidth("Short", style.Font); short.contentWidth != want {
+		t.Errorf("Expected a short body to use its natural width %.2f, got %.2f", want, short.contentWidth)
+	}
+
+	long := calculateCommentBlockLayout(nil, CommentParams{Style: style, BodyText: strings.Repeat("Long body text. ", 20), IsHorizontal: true, CrossAxisDir: 1})
+	if long.contentWidth != maxWidth {
+		t.Errorf("Expected a long body to be capped at %.0f, got %.2f", maxWidth, long.contentWidth)
+	}
+	if long.foHeight <= foreignObjectHeightEstimate {
+		t.Errorf("Expected the wrapped body to grow taller than %.0f, got %.2f", foreignObjectHeightEstimate, long.foHeight)
+	}
+}
+
+// TestMinimalModeSkipsComments checks minimal_mode keeps the spine and years but drops comment boxes.
+func TestMinimalModeSkipsComments(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.MinimalMode = true
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002", CommentText: "Body"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, "<foreignObject") || strings.Contains(svg, `stroke="red"`) {
+		t.Errorf("Expected no comment blocks in minimal mode")
+	}
+	if !strings.Contains(svg, `<circle cx="0.00" cy="-55.00" r="30.00"`) || !strings.Contains(svg, ">2002<") {
+		t.Errorf("Expected the year elements to remain in minimal mode")
+	}
+}
+
+// TestJunctionMarkerFollowsAngledAxis checks a diamond is rotated with a 30° axis.
+func TestJunctionMarkerFollowsAngledAxis(t *testing.T) {
+	var svg svgBuffer
+	b := &bounds{}
+	angle := 30.0
+	drawJunctionMarker(&svg, b, JunctionMarkerParams{
+		Style:        JunctionMarkerStyle{Shape: "diamond", Size: 20},
+		MarkerColor:  "#000000",
+		IsHorizontal: true,
+		AxisAngle:    &angle,
+		StrokeScale:  1,
+	})
+	// Tips along the axis at ±(cos30, sin30)*10, across it at ±(-sin30, cos30)*10
+	if !strings.Contains(svg.String(), `<polygon points="-5.00,8.66 -8.66,-5.00 8.66,5.00"`) {
+		t.Errorf("Expected the diamond rotated by 30°, got %s", svg.String())
+	}
+	if math.Abs(b.maxX-8.66) > 0.01 || math.Abs(b.minY+8.66) > 0.01 {
+		t.Errorf("Expected bounds to follow the rotated points, got %+v", *b)
+	}
+}
+
+// TestTitlePositionBelowStacksBodyFirst checks title_position "below" moves the title under the
+// body while the block keeps its size.
+func TestTitlePositionBelowStacksBodyFirst(t *testing.T) {
+	style := CommentTextStyle{
+		Font:      FontStyle{FontSize: 12},
+		TitleFont: FontStyle{FontSize: 14},
+		TitleLine: TitleLineStyle{Visible: true, Width: 2, Length: 40, Margin: 3},
+		Padding:   "5",
+	}
+	params := CommentParams{Style: style, TitleText: "Title", BodyText: "Body", IsHorizontal: true, CrossAxisDir: 1}
+	above := calculateCommentBlockLayout(nil, params)
+	params.Style.TitlePosition = "below"
+	below := calculateCommentBlockLayout(nil, params)
+
+	if below.visualBlockHeight != above.visualBlockHeight {
+		t.Errorf("Expected the same block height, got %.2f and %.2f", below.visualBlockHeight, above.visualBlockHeight)
+	}
+	if below.bodyAbsY != below.blockY+5 {
+		t.Errorf("Expected the body right below the top padding, got %.2f", below.bodyAbsY-below.blockY)
+	}
+	if want := below.bodyAbsY + below.foHeight + 3; below.titleLineAbsY != want {
+		t.Errorf("Expected the title line %.2f below the body, got %.2f", want, below.titleLineAbsY)
+	}
+	if want := below.titleLineAbsY + 2 + 3; below.titleTextAbsY != want {
+		t.Errorf("Expected the title at %.2f, got %.2f", want, below.titleTextAbsY)
+	}
+	if want := below.blockY + below.visualBlockHeight - 5 - getEstimatedHeight(style.TitleFont); math.Abs(below.titleTextAbsY-want) > 1e-9 {
+		t.Errorf("Expected the title to end at the bottom padding, got %.2f want %.2f", below.titleTextAbsY, want)
+	}
+}
+
+// TestDotArrowRatioControlsTipLength checks arrow_ratio scales the arrow tip, defaulting to 1.2.
+func TestDotArrowRatioControlsTipLength(t *testing.T) {
+	arrow := func(ratio float64) string {
+		var svg svgBuffer
+		drawConnectorDot(&svg, &bounds{}, ConnectorDotParams{
+			DotStyle:     DotStyle{Size: 10, Shape: "arrow", Visible: true, Color: "#000000", ArrowRatio: ratio},
+			IsHorizontal: true,
+			CrossAxisDir: 1,
+		}, 0, 0)
+		return svg.String()
+	}
+	if got := arrow(0); !strings.Contains(got, `points="-5.00,0.00 5.00,0.00 0.00,-6.00"`) {
+		t.Errorf("Expected the default 1.2 ratio tip at -6, got %s", got)
+	}
+	if got := arrow(2); !strings.Contains(got, `points="-5.00,0.00 5.00,0.00 0.00,-10.00"`) {
+		t.Errorf("Expected a ratio 2 tip at -10, got %s", got)
+	}
+	if got := arrow(-1); !strings.Contains(got, `0.00,-0.50"`) {
+		t.Errorf("Expected a negative ratio to be raised to the minimum, got %s", got)
+	}
+}
+
+// TestCommentEdgeAnchorMovesConnectorPoint checks edge_anchor slides the connection point along
+// the facing edge: 0 is the corner, unset is the center.
+func TestCommentEdgeAnchorMovesConnectorPoint(t *testing.T) {
+	layout := CommentBlockLayout{blockX: 10, blockY: 55, visualBlockWidth: 150, visualBlockHeight: 128}
+	zero := 0.0
+
+	if x, y := calculateCommentEdgePoint(layout, 1, true, resolveEdgeAnchor(nil)); x != 85 || y != 55 {
+		t.Errorf("Expected the top edge center (85, 55), got (%.2f, %.2f)", x, y)
+	}
+	if x, y := calculateCommentEdgePoint(layout, 1, true, resolveEdgeAnchor(&zero)); x != 10 || y != 55 {
+		t.Errorf("Expected the top-left corner (10, 55), got (%.2f, %.2f)", x, y)
+	}
+	if x, y := calculateCommentEdgePoint(layout, -1, false, resolveEdgeAnchor(&zero)); x != 160 || y != 55 {
+		t.Errorf("Expected the top-right corner (160, 55) for a box left of a vertical axis, got (%.2f, %.2f)", x, y)
+	}
+	tooFar := 3.0
+	if got := resolveEdgeAnchor(&tooFar); got != 1 {
+		t.Errorf("Expected edge_anchor to be clamped to 1, got %.2f", got)
+	}
+}
+
+// TestSegmentColorAfterColorsFollowingSegment checks segment_color_after colors the segment after
+// an entry while centerline_projection_override keeps coloring the one leading to it.
+func TestSegmentColorAfterColorsFollowingSegment(t *testing.T) {
+	template := loadTestTemplate(t)
+	after := "#00FF00"
+	entries := []TimelineEntry{
+		{Period: "2001", CenterlineProjectionOverride: &CenterlineProjectionStyle{Color: "#FF0000"}, SegmentColorAfter: &after},
+		{Period: "2002"},
+		{Period: "2003"},
+	}
+	data := calculateTimelinePositionsAndStyles(entries, template, initializeLayoutConfig(template))
+	if got := data.segmentColors; got[0] != "#FF0000" || got[1] != after || got[2] != "#BDBDBD" {
+		t.Errorf("Expected segment colors [#FF0000 %s #BDBDBD], got %v", after, got)
+	}
+
+	entries[2].SegmentColorAfter = &after
+	config := initializeLayoutConfig(template)
+	calculateTimelinePositionsAndStyles(entries, template, config)
+	if warnings := config.diagnostics.warnings; len(warnings) != 1 || warnings[0].Code != "ignored_override" {
+		t.Errorf("Expected an ignored_override warning for the last entry, got %+v", warnings)
+	}
+}
+
+// TestMeasuredBodyHeightsReplaceEstimate checks the heights measured for precise_image_layout
+// size the comment body and box, while other entries keep the estimate.
+func TestMeasuredBodyHeightsReplaceEstimate(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002", CommentText: "Body"}}
+
+	svg, _, err := generateSVG(template, entries, map[string]float64{"entry-2001": 42})
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, `height="42.00">`) {
+		t.Errorf("Expected the measured 42px foreignObject for entry-2001")
+	}
+	if !strings.Contains(svg, fmt.Sprintf(`height="%s">`, fcoord(foreignObjectHeightEstimate, defaultCoordPrecision))) {
+		t.Errorf("Expected entry-2002 to keep the estimated body height")
+	}
+}
+
+// TestEntryDividerSpansCommentAndYear checks a divider entry gets a dashed line across the
+// axis covering its comment and year, drawn before (behind) the entry's marker.
+func TestEntryDividerSpansCommentAndYear(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002", Divider: true, TitleText: "New era", CommentText: "Body"}}
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	var x1, y1, x2, y2 float64
+	start := strings.Index(svg, `<line class="timeline-divider"`)
+	if start < 0 {
+		t.Fatalf("Expected a divider line, got none")
+	}
+	if _, err := fmt.Sscanf(svg[start:], `<line class="timeline-divider" x1="%g" y1="%g" x2="%g" y2="%g"`, &x1, &y1, &x2, &y2); err != nil {
+		t.Fatalf("Could not parse divider: %v", err)
+	}
+	if strings.Count(svg, "timeline-divider") != 1 || !strings.Contains(svg[start:strings.Index(svg[start:], "/>")+start], "stroke-dasharray") {
+		t.Errorf("Expected exactly one dashed divider")
+	}
+	if x1 != x2 {
+		t.Errorf("Expected a vertical divider on a horizontal axis, got x1=%g x2=%g", x1, x2)
+	}
+	// The second entry's comment box spans y -198.6..-55 and its year circle reaches y 85
+	if y1 != -198.6 || y2 != 85 {
+		t.Errorf("Expected the divider to span y -198.6..85 (comment to year circle), got %g..%g", y1, y2)
+	}
+	if groupStart := strings.Index(svg, `<g id="entry-2002">`); groupStart < 0 || groupStart > start || strings.Contains(svg[groupStart:start], "<polygon") {
+		t.Errorf("Expected the divider first in its entry group, behind the marker")
+	}
+}
+
+// TestCenterLineGradientFollowsAxis checks center_line.gradient draws the axis as one path
+// stroked with a gradient running from the axis start to the last entry, also when angled.
+func TestCenterLineGradientFollowsAxis(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Gradient = &[]string{"#FF0000", "#00FF00", "#0000FF"}
+	angle := 30.0
+	template.CenterLine.Angle = &angle
+	entries := []TimelineEntry{{Period: "2001"}, {Period: "2002"}, {Period: "2003"}}
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if n := strings.Count(svg, `stroke="url(#center-line-gradient)"`); n != 1 {
+		t.Errorf("Expected the axis as a single gradient stroke, got %d", n)
+	}
+	var x1, y1, x2, y2 float64
+	start := strings.Index(svg, `<linearGradient id="center-line-gradient"`)
+	if start < 0 || start > strings.Index(svg, "</defs>") {
+		t.Fatalf("Expected the gradient inside <defs>")
+	}
+	if _, err := fmt.Sscanf(svg[start:], `<linearGradient id="center-line-gradient" gradientUnits="userSpaceOnUse" x1="%g" y1="%g" x2="%g" y2="%g">`, &x1, &y1, &x2, &y2); err != nil {
+		t.Fatalf("Could not parse gradient: %v", err)
+	}
+	if math.Abs(math.Atan2(y2-y1, x2-x1)*180/math.Pi-angle) > 0.1 {
+		t.Errorf("Expected the gradient along the 30° axis, got (%g,%g) to (%g,%g)", x1, y1, x2, y2)
+	}
+	if !strings.Contains(svg, `<stop offset="50.00%" stop-color="#00FF00"/>`) {
+		t.Errorf("Expected the middle stop at 50%%")
+	}
+}
+
+// TestDashedCenterLineOffsetContinuesAcrossSegments checks every dashed segment after the
+// first starts its dash pattern at the axis distance already drawn.
+func TestDashedCenterLineOffsetContinuesAcrossSegments(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Type = "dashed"
+	entries := []TimelineEntry{{Period: "2001"}, {Period: "2002"}, {Period: "2003"}}
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	segmentRegex := regexp.MustCompile(`<line x1="([-0-9.]+)" y1="([-0-9.]+)" x2="([-0-9.]+)" y2="([-0-9.]+)" [^>]*stroke-dasharray="[^"]*"(?: stroke-dashoffset="([0-9.]+)")?`)
+	segments := segmentRegex.FindAllStringSubmatch(svg, -1)
+	if len(segments) != len(entries) {
+		t.Fatalf("Expected %d dashed axis segments, got %d", len(entries), len(segments))
+	}
+	drawn := 0.0
+	for i, segment := range segments {
+		offset := 0.0
+		if segment[5] != "" {
+			offset, _ = strconv.ParseFloat(segment[5], 64)
+		}
+		if math.Abs(offset-drawn) > 0.01 {
+			t.Errorf("Expected segment %d to start its dashes at %g, got %g", i, drawn, offset)
+		}
+		var coords [4]float64
+		for j := range coords {
+			coords[j], _ = strconv.ParseFloat(segment[j+1], 64)
+		}
+		drawn += math.Hypot(coords[2]-coords[0], coords[3]-coords[1])
+	}
+
+	template.CenterLine.Type = "solid"
+	if svg, _ = GenerateSVG(template, entries); strings.Contains(svg, "stroke-dashoffset") {
+		t.Errorf("Expected no dash offset on a solid center line")
+	}
+}
+
+// TestJitterIsReproducibleFromSeed checks layout.jitter moves line ends within the limit,
+// gives the same SVG for the same seed and a different one for another seed.
+func TestJitterIsReproducibleFromSeed(t *testing.T) {
+	entries := []TimelineEntry{{Period: "2001", TitleText: "Title", CommentText: "Body"}, {Period: "2002"}}
+	generate := func(jitter float64, seed int64) string {
+		template := loadTestTemplate(t)
+		template.Layout.Jitter = jitter
+		template.Layout.Seed = seed
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		return svg
+	}
+	plain, first, again, other := generate(0, 0), generate(2, 7), generate(2, 7), generate(2, 8)
+	if first != again {
+		t.Errorf("Expected the same SVG for the same seed")
+	}
+	if first == plain || first == other {
+		t.Errorf("Expected the jitter to change the drawing and depend on the seed")
+	}
+	var x1, y1 float64
+	if _, err := fmt.Sscanf(first[strings.Index(first, `<line x1=`):], `<line x1="%g" y1="%g"`, &x1, &y1); err != nil {
+		t.Fatalf("Could not parse the first line: %v", err)
+	}
+	if math.Abs(x1) > 2 || math.Abs(y1) > 2 || (x1 == 0 && y1 == 0) {
+		t.Errorf("Expected the axis start (0,0) moved by at most 2px, got (%g,%g)", x1, y1)
+	}
+}
+
+// TestFillOpacityOnCommentAndYear checks fill_opacity becomes fill-opacity on the comment
+// background and the year shape, including per-entry overrides, and is omitted when unset.
+func TestFillOpacityOnCommentAndYear(t *testing.T) {
+	template := loadTestTemplate(t)
+	half := 0.5
+	template.PeriodDefaults.CommentText.FillOpacity = &half
+	quarter := 0.25
+	entries := []TimelineEntry{
+		{Period: "2001", CommentText: "Body", YearTextOverride: &YearTextStyleOverride{FillOpacity: &quarter}},
+		{Period: "2002"},
+	}
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !regexp.MustCompile(`<rect x="[^"]*" y="[^"]*" width="[^"]*" height="[^"]*" fill="[^"]*" fill-opacity="0.5" stroke="red"`).MatchString(svg) {
+		t.Errorf("Expected fill-opacity=\"0.5\" on the comment background")
+	}
+	if n := strings.Count(svg, `fill-opacity="0.25"`); n != 1 {
+		t.Errorf("Expected the overridden year circle only to get fill-opacity=\"0.25\", got %d", n)
+	}
+	if n := strings.Count(svg, "fill-opacity"); n != 2 {
+		t.Errorf("Expected the attribute omitted for the unset year shape, got %d fill-opacity attributes", n)
+	}
+}
+
+// TestCenterLineStartFlagCap checks center_line.start_cap draws a flag at the axis origin,
+// standing on the start side, and no end cap when it is unset.
+func TestCenterLineStartFlagCap(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.StartCap = &AxisCapStyle{Shape: "flag", Size: 20, Color: "#E53935"}
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}, {Period: "2002"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !strings.Contains(svg, `<line x1="0.00" y1="0.00" x2="0.00" y2="-20.00" stroke="#E53935" stroke-width="2.00" />`) {
+		t.Errorf("Expected a 20px flag pole rising from the axis start")
+	}
+	if !strings.Contains(svg, `<polygon points="0.00,-20.00 12.00,-15.00 0.00,-10.00" fill="#E53935" />`) {
+		t.Errorf("Expected the pennant pointing along the axis")
+	}
+	if n := strings.Count(svg, `#E53935`); n != 2 {
+		t.Errorf("Expected only the start cap in the cap color, got %d uses", n)
+	}
+}
+
+// TestFitCommentsNarrowsCrowdedSide checks fit_comments keeps comment boxes from overlapping
+// their same-side neighbors while a box with an open side keeps its natural width.
+func TestFitCommentsNarrowsCrowdedSide(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.CommentText.BlockWidth = nil // Let the boxes grow to their titles
+	longTitle := strings.Repeat("A long comment title ", 4)
+	// The first three comments are above the axis 260px apart, the last one is alone below
+	entries := []TimelineEntry{
+		{Period: "2001", TitleText: longTitle, CommentSide: "start"},
+		{Period: "2002", TitleText: longTitle, CommentSide: "start"},
+		{Period: "2003", TitleText: longTitle, CommentSide: "start"},
+		{Period: "2004", TitleText: longTitle, CommentSide: "end"},
+	}
+	commentWidths := func(fit bool) []float64 {
+		template.Layout.FitComments = fit
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		var widths []float64
+		for _, m := range regexp.MustCompile(`<rect [^>]*width="([0-9.]+)"[^>]*stroke="red"`).FindAllStringSubmatch(svg, -1) {
+			var w float64
+			fmt.Sscanf(m[1], "%g", &w)
+			widths = append(widths, w)
+		}
+		if len(widths) != len(entries) {
+			t.Fatalf("Expected %d comment boxes, found %d", len(entries), len(widths))
+		}
+		return widths
+	}
+
+	natural := commentWidths(false)
+	fitted := commentWidths(true)
+	if natural[0] <= 260 {
+		t.Fatalf("Test title too short, natural width is only %.2f", natural[0])
+	}
+	for i := 0; i < 3; i++ {
+		if fitted[i] > 260-commentFitGap+0.01 {
+			t.Errorf("Expected crowded comment %d to fit into %.0f, got %.2f", i, 260-commentFitGap, fitted[i])
+		}
+	}
+	if fitted[3] != natural[3] {
+		t.Errorf("Expected the lone comment below the axis to keep its width %.2f, got %.2f", natural[3], fitted[3])
+	}
+}
+
+// TestYearRotateTurnsTextAndBounds checks a 90 degree year rotation wraps the shape and text
+// in a rotate transform and turns the year's bounds upright.
+func TestYearRotateTurnsTextAndBounds(t *testing.T) {
+	entry := TimelineEntry{Period: "1999-2004"}
+	style := YearTextStyle{Font: FontStyle{FontSize: 12}, Shape: "rectangle;w=80;h=20"}
+
+	var upright svgBuffer
+	var plainBounds bounds
+	plainArea := drawYearElement(&upright, &plainBounds, entry, style, 100, 200, false)
+
+	angle := 90.0
+	style.Rotate = &angle
+	var svg svgBuffer
+	var rotatedBounds bounds
+	rotatedArea := drawYearElement(&svg, &rotatedBounds, entry, style, 100, 200, false)
+
+	if !strings.Contains(svg.String(), `<g transform="rotate(90.00, 100.00, 200.00)">`) {
+		t.Errorf("Expected the year to be wrapped in a rotate transform, got:\n%s", svg.String())
+	}
+	plainW, plainH := plainBounds.maxX-plainBounds.minX, plainBounds.maxY-plainBounds.minY
+	rotW, rotH := rotatedBounds.maxX-rotatedBounds.minX, rotatedBounds.maxY-rotatedBounds.minY
+	if plainW <= plainH || math.Abs(rotW-plainH) > 1e-6 || math.Abs(rotH-plainW) > 1e-6 {
+		t.Errorf("Expected the text bounds to swap from %.2fx%.2f, got %.2fx%.2f", plainW, plainH, rotW, rotH)
+	}
+	if areaH := rotatedArea.maxY - rotatedArea.minY; math.Abs(areaH-(plainArea.maxX-plainArea.minX)) > 1e-6 {
+		t.Errorf("Expected the link area to grow vertically to %.2f, got %.2f", plainArea.maxX-plainArea.minX, areaH)
+	}
+}
+
+// TestYearSubtitleAddsSmallerLineAndGrowsShape checks a subtitle is drawn below the period
+// in a smaller font and a fixed-size year shape grows to hold both lines.
+func TestYearSubtitleAddsSmallerLineAndGrowsShape(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.YearText.Shape = "rectangle;w=40;h=20"
+	entries := []TimelineEntry{{Period: "2001", Subtitle: "14 Jul 2001, 09:30"}, {Period: "2002"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	subtitle := regexp.MustCompile(`<text class="timeline-subtitle" x="[^"]+" y="([^"]+)"[^>]*font-size="(\d+)"[^>]*>14 Jul 2001, 09:30</text>`).FindStringSubmatch(svg)
+	if subtitle == nil {
+		t.Fatalf("Expected a subtitle text element, got:\n%s", svg)
+	}
+	year := regexp.MustCompile(`y="([^"]+)"[^>]*font-size="(\d+)"[^>]*>2001</text>`).FindStringSubmatch(svg)
+	if year == nil {
+		t.Fatalf("Expected the period text, got:\n%s", svg)
+	}
+	var subtitleY, yearY float64
+	var subtitleSize, yearSize int
+	fmt.Sscanf(subtitle[1]+" "+subtitle[2], "%g %d", &subtitleY, &subtitleSize)
+	fmt.Sscanf(year[1]+" "+year[2], "%g %d", &yearY, &yearSize)
+	if subtitleY <= yearY || subtitleSize >= yearSize {
+		t.Errorf("Expected a smaller subtitle (size %d, y %.2f) below the period (size %d, y %.2f)", subtitleSize, subtitleY, yearSize, yearY)
+	}
+
+	rects := regexp.MustCompile(`<rect x="[^"]+" y="[^"]+" width="([^"]+)" height="([^"]+)" fill="#FFFFFF"`).FindAllStringSubmatch(svg, -1)
+	if len(rects) < 2 {
+		t.Fatalf("Expected two year shapes, got %d", len(rects))
+	}
+	var grownW, grownH float64
+	fmt.Sscanf(rects[0][1]+" "+rects[0][2], "%g %g", &grownW, &grownH)
+	if grownW <= 40 || grownH <= 20 || rects[1][1] != "40.00" || rects[1][2] != "20.00" {
+		t.Errorf("Expected only the subtitled year shape to grow beyond 40x20, got %sx%s and %sx%s", rects[0][1], rects[0][2], rects[1][1], rects[1][2])
+	}
+}
+
+// TestLineJoinOnMultiSegmentLines checks line_join reaches orthogonal connectors and the
+// smooth center line, and that the default miter adds no attribute.
+func TestLineJoinOnMultiSegmentLines(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.CenterLine.Smooth = true
+	template.PeriodDefaults.Connector.Routing = "orthogonal"
+	entries := []TimelineEntry{{Period: "2001", CommentText: "A"}, {Period: "2002", CommentText: "B"}, {Period: "2003", CommentText: "C"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, "stroke-linejoin") {
+		t.Errorf("Expected no stroke-linejoin with the default miter join")
+	}
+
+	template.Layout.LineJoin = "round"
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if !regexp.MustCompile(`<polyline [^>]*stroke-linejoin="round"`).MatchString(svg) {
+		t.Errorf("Expected the orthogonal connectors to use round joins")
+	}
+	if !regexp.MustCompile(`<path d="M[^"]*C[^>]*stroke-linejoin="round"`).MatchString(svg) {
+		t.Errorf("Expected the smooth center line to use round joins")
+	}
+}
+
+// TestNoForeignObjectDrawsTextBodies checks no_foreign_object replaces every foreignObject
+// with wrapped text lines, linked tspans and an <image>.
+func TestNoForeignObjectDrawsTextBodies(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.NoForeignObject = true
+	imageURI := "data:image/png;base64,iVBORw0KGgo="
+	entries := []TimelineEntry{
+		{Period: "2001", TitleText: "Launch", CommentText: "A body long enough to need at least two lines, see [the notes](https://example.com/notes) &amp; <b>more</b>"},
+		{Period: "2002", CommentText: "Short", CommentImage: imageURI, ImageAlt: "Photo"},
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if strings.Contains(svg, "<foreignObject") {
+		t.Errorf("Expected no foreignObject in compatibility mode")
+	}
+	if n := strings.Count(svg, "<tspan x="); n < 3 {
+		t.Errorf("Expected the first body to wrap into several lines, found %d line tspans", n)
+	}
+	if !regexp.MustCompile(`<a xlink:href="https://example.com/notes" target="_blank"><tspan text-decoration="underline">the( notes)?</tspan></a>`).MatchString(svg) {
+		t.Errorf("Expected the markdown link as a linked tspan, got:\n%s", svg)
+	}
+	if !strings.Contains(svg, "&amp;") || strings.Contains(svg, "<b>") {
+		t.Errorf("Expected entities to be kept and HTML tags to be dropped")
+	}
+	if !strings.Contains(svg, `<image href="`+imageURI+`"`) || !strings.Contains(svg, "<title>Photo</title>") {
+		t.Errorf("Expected the comment image as an <image> element with its alt text")
+	}
+}
+
+// TestElementOrderChangesEntryStacking checks element_order draws the entry parts in the given
+// order and that an invalid order keeps the default marker, year, comment.
+func TestElementOrderChangesEntryStacking(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: "A"}}
+	positions := func(svg string) (marker, year, comment int) {
+		return strings.Index(svg, "<polygon"), strings.Index(svg, "<circle"), strings.Index(svg, "<foreignObject")
+	}
+
+	template.Layout.ElementOrder = "comment, year, marker"
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if marker, year, comment := positions(svg); !(comment < year && year < marker) {
+		t.Errorf("Expected comment, year, marker order, got offsets comment %d, year %d, marker %d", comment, year, marker)
+	}
+
+	template.Layout.ElementOrder = "year,year,comment"
+	svg, err = GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if marker, year, comment := positions(svg); !(marker < year && year < comment) {
+		t.Errorf("Expected the default order for an invalid element_order, got offsets marker %d, year %d, comment %d", marker, year, comment)
+	}
+}
+
+// TestAnimateConnectorsAddsStaggeredDrawIn checks animate_connectors injects the keyframes and
+// gives each connector its length as dash offset and a delay growing with the entry index.
+func TestAnimateConnectorsAddsStaggeredDrawIn(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.AnimateConnectors = true
+	entries := []TimelineEntry{{Period: "2001", CommentText: "A"}, {Period: "2002", CommentText: "B"}}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	for _, want := range []string{
+		"@keyframes timeline-connector-draw { to { stroke-dashoffset: 0; } }",
+		".timeline-connector-draw { animation: timeline-connector-draw 0.80s ease-out forwards; }",
+		`class="timeline-connector-draw" stroke-dasharray="55.00" stroke-dashoffset="55.00" style="animation-delay: 0.00s"`,
+		`style="animation-delay: 0.15s"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected SVG to contain %q", want)
+		}
+	}
+
+	template.Layout.AnimateConnectors = false
+	if svg, _ = GenerateSVG(template, entries); strings.Contains(svg, "timeline-connector-draw") {
+		t.Errorf("Expected no connector animation by default")
+	}
+}
+
+// TestConnectorGradientToElementFadesIntoBorder checks gradient_to_element gives each
+// connector its own gradient from the axis color to the comment border, without moving
+// anything, and that connectors stay solid by default.
+func TestConnectorGradientToElementFadesIntoBorder(t *testing.T) {
+	template := loadTestTemplate(t)
+	drawLines := true
+	template.PeriodDefaults.CenterlineProjection.Color = "#00AA00"
+	template.PeriodDefaults.Connector.DrawToComment = &drawLines
+	template.PeriodDefaults.CommentText.BorderColor = "#FF0000"
+	template.PeriodDefaults.CommentText.BorderWidth = 1
+	entries := []TimelineEntry{{Period: "2001", CommentText: "A"}, {Period: "2002", CommentText: "B"}}
+	solid, solidLayout, err := generateSVG(template, entries, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	if strings.Contains(solid, "connector-gradient") {
+		t.Errorf("Expected solid connectors by default")
+	}
+
+	template.PeriodDefaults.Connector.GradientToElement = true
+	svg, layout, err := generateSVG(template, entries, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	gradients := regexp.MustCompile(`<linearGradient id="([^"]+-comment-connector-gradient)"[^>]*><stop offset="0%" stop-color="([^"]+)"/><stop offset="100%" stop-color="([^"]+)"/>`).FindAllStringSubmatch(svg, -1)
+	if len(gradients) != len(entries) {
+		t.Fatalf("Expected one comment connector gradient per entry, got %d", len(gradients))
+	}
+	if gradients[0][1] == gradients[1][1] {
+		t.Errorf("Expected distinct gradient ids, got %s twice", gradients[0][1])
+	}
+	for _, gradient := range gradients {
+		if gradient[2] != "#00AA00" || gradient[3] != "#FF0000" {
+			t.Errorf("Expected %s to fade from the axis color to the border, got %s to %s", gradient[1], gradient[2], gradient[3])
+		}
+		if !strings.Contains(svg, `stroke="url(#`+gradient[1]+`)"`) {
+			t.Errorf("Expected a connector stroked with %s", gradient[1])
+		}
+	}
+	if layout.canvas != solidLayout.canvas {
+		t.Errorf("Expected the gradients to keep the canvas %+v, got %+v", solidLayout.canvas, layout.canvas)
+	}
+	if again, _, _ := generateSVG(template, entries, nil); again != svg {
+		t.Errorf("Expected the same gradient ids on every render")
+	}
+}
+
+// TestYearAutoFitTextShrinksLongLabels checks auto_fit_text lowers the font size of a long
+// period until it fits a narrow fixed rectangle, and leaves it alone when off.
+func TestYearAutoFitTextShrinksLongLabels(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.YearText.Shape = "rectangle;w=60;h=30"
+	entries := []TimelineEntry{{Period: "Late Antiquity"}}
+	fontSize := func(svg string) int {
+		t.Helper()
+		m := regexp.MustCompile(`font-size="(\d+)"[^>]*text-anchor="middle">Late Antiquity<`).FindStringSubmatch(svg)
+		if m == nil {
+			t.Fatalf("No period text in SVG")
+		}
+		size, _ := strconv.Atoi(m[1])
+		return size
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	original := fontSize(svg)
+
+	template.PeriodDefaults.YearText.AutoFitText = true
+	if svg, err = GenerateSVG(template, entries); err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	fitted := fontSize(svg)
+	if fitted >= original || fitted < minAutoFitFontSize {
+		t.Errorf("Expected the font to shrink from %d but not below %d, got %d", original, minAutoFitFontSize, fitted)
+	}
+	font := template.PeriodDefaults.YearText.Font
+	font.FontSize = fitted
+	if width := estimateTextSVGWidth("Late Antiquity", font); width > 60-2*yearShapeAutoPadding {
+		t.Errorf("Expected the fitted label to fit the rectangle, estimated %g px wide", width)
+	}
+}
+
+// TestCommentLinkIsEscaped checks a comment_link with a query string and quotes stays one
+// well-formed attribute value.
+func TestCommentLinkIsEscaped(t *testing.T) {
+	template := loadTestTemplate(t)
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentLink: `https://e.com/?a=1&b="2"`}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	if want := `xlink:href="https://e.com/?a=1&amp;b=&#34;2&#34;"`; !strings.Contains(svg, want) {
+		t.Errorf("Expected the escaped link %s in the SVG", want)
+	}
+}
+
+// TestImageAltIsEscaped checks quotes, < and & in image_alt can't break the <img> element.
+func TestImageAltIsEscaped(t *testing.T) {
+	template := loadTestTemplate(t)
+	for alt, want := range map[string]string{`say "hi"`: `alt="say &#34;hi&#34;"`, "a < b & c": `alt="a &lt; b &amp; c"`} {
+		svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body", CommentImage: "https://example.com/a.png", ImageAlt: alt}})
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		if !strings.Contains(svg, want) {
+			t.Errorf("Expected %s in the SVG for alt %q", want, alt)
+		}
+	}
+}
+
+// TestClampCanvasSizeScalesUniformly checks an oversized canvas shrinks by the tighter limit
+// on both axes, keeping its aspect ratio.
+func TestClampCanvasSizeScalesUniformly(t *testing.T) {
+	config := LayoutConfig{maxWidth: 500, maxHeight: 1000}
+	width, height, scale, centerX, centerY := clampCanvasSize(1000, 400, config)
+	if scale != 0.5 || width != 500 || height != 200 {
+		t.Errorf("Expected a 500x200 canvas at scale 0.5, got %gx%g at %g", width, height, scale)
+	}
+	if width/height != 1000.0/400.0 {
+		t.Errorf("Expected the aspect ratio to be kept, got %g", width/height)
+	}
+	if centerX != 0 || centerY != 0 {
+		t.Errorf("Expected no centering offset, got %g,%g", centerX, centerY)
+	}
+
+	if width, height, scale, _, _ = clampCanvasSize(300, 200, config); scale != 1 || width != 300 || height != 200 {
+		t.Errorf("Expected a canvas within the limits to be unchanged, got %gx%g at %g", width, height, scale)
+	}
+}
+
+// TestClampCanvasSizeCentersSmallContent checks min_width/min_height pad the canvas and
+// center the content in it without scaling.
+func TestClampCanvasSizeCentersSmallContent(t *testing.T) {
+	config := LayoutConfig{minWidth: 800, minHeight: 600}
+	width, height, scale, centerX, centerY := clampCanvasSize(400, 200, config)
+	if scale != 1 || width != 800 || height != 600 {
+		t.Errorf("Expected an unscaled 800x600 canvas, got %gx%g at %g", width, height, scale)
+	}
+	if centerX != 200 || centerY != 200 {
+		t.Errorf("Expected the content centered at offset 200,200, got %g,%g", centerX, centerY)
+	}
+}
+
+// TestAssembleFinalSVGWarnsWhenScaled checks the canvas_scaled warning only appears when
+// max_width/max_height shrink the content.
+func TestAssembleFinalSVGWarnsWhenScaled(t *testing.T) {
+	var timelineBounds bounds
+	timelineBounds.updateRect(0, 0, 1000, 400)
+	for maxWidth, want := range map[float64]bool{500: true, 2000: false} {
+		diag := &diagnosticLog{}
+		svgBody := svgBuffer{renderContext: &renderContext{diagnostics: diag}}
+		svg := assembleFinalSVG(&svgBody, timelineBounds, LayoutConfig{maxWidth: maxWidth}, nil)
+		warned := slices.ContainsFunc(diag.warnings, func(d Diagnostic) bool { return d.Code == "canvas_scaled" })
+		if warned != want {
+			t.Errorf("max_width %g: expected canvas_scaled warning %v, got %v", maxWidth, want, diag.warnings)
+		}
+		if want && !strings.Contains(svg, `<svg width="500" height="200"`) {
+			t.Errorf("Expected a 500x200 canvas, got %s", svg[:strings.Index(svg, ">")+1])
+		}
+	}
+}
+
+// TestLayoutIncludesAssemblyWarnings checks warnings raised while assembling the final SVG
+// reach the returned layout.
+func TestLayoutIncludesAssemblyWarnings(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.Layout.MaxWidth = 100
+	_, layout, err := generateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body"}, {Period: "2002"}}, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	if !slices.ContainsFunc(layout.warnings, func(d Diagnostic) bool { return d.Code == "canvas_scaled" }) {
+		t.Errorf("Expected a canvas_scaled warning in the layout, got %v", layout.warnings)
+	}
+}
+
+// TestOrthogonalConnectorPaths checks orthogonal routing draws a Z whose middle leg runs
+// parallel to the axis in both orientations, and a single leg when the ends are aligned.
+func TestOrthogonalConnectorPaths(t *testing.T) {
+	tests := []struct {
+		name         string
+		isHorizontal bool
+		x1, y1       float64
+		want         string
+	}{
+		{"horizontal Z", true, 40, 100, `points="40.00,100.00 40.00,50.00 0.00,50.00 0.00,0.00"`},
+		{"vertical Z", false, 100, 40, `points="100.00,40.00 50.00,40.00 50.00,0.00 0.00,0.00"`},
+		{"horizontal aligned", true, 0, 100, `points="0.00,100.00 0.00,0.00"`},
+		{"vertical aligned", false, 100, 0, `points="100.00,0.00 0.00,0.00"`},
+	}
+	for _, tt := range tests {
+		var svg svgBuffer
+		var b bounds
+		drawOrthogonalConnector(ConnectorLineSegmentsParams{
+			SVG:        &svg,
+			Bounds:     &b,
+			ConnParams: ConnectorParams{X1: tt.x1, Y1: tt.y1, IsHorizontal: tt.isHorizontal},
+			DrawWidth:  1,
+			DrawColor:  "#000000",
+		})
+		if !strings.Contains(svg.String(), tt.want) {
+			t.Errorf("%s: expected %s, got %s", tt.name, tt.want, svg.String())
+		}
+	}
+}
+
+// TestUnknownConnectorRoutingWarns checks an unknown connector.routing warns and falls back
+// to a direct line.
+func TestUnknownConnectorRoutingWarns(t *testing.T) {
+	template := loadTestTemplate(t)
+	template.PeriodDefaults.Connector.Routing = "curvy"
+	svg, layout, err := generateSVG(template, []TimelineEntry{{Period: "2001", CommentText: "Body"}}, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	if !slices.ContainsFunc(layout.warnings, func(d Diagnostic) bool {
+		return d.Code == "invalid_option" && strings.Contains(d.Message, "curvy")
+	}) {
+		t.Errorf("Expected an invalid_option warning for the routing, got %v", layout.warnings)
+	}
+	if strings.Contains(svg, "<polyline") {
+		t.Errorf("Expected a direct connector, not an orthogonal one")
+	}
+}
+
+// TestAxisLengthSpreadsEntriesEvenly checks layout.axis_length splits the axis into equal
+// slots with every entry centered in its own, and a zero or negative length keeps the
+// spacing-driven layout.
+func TestAxisLengthSpreadsEntriesEvenly(t *testing.T) {
+	template := loadTestTemplate(t)
+	tests := []struct {
+		name       string
+		axisLength float64
+		entries    int
+		wantPoints []float64
+		wantLength float64
+	}{
+		{"four entries", 400, 4, []float64{50, 150, 250, 350}, 400},
+		{"one entry", 300, 1, []float64{150}, 300},
+		{"zero length", 0, 2, []float64{template.Layout.EntrySpacing / 2, template.Layout.EntrySpacing * 1.5}, template.Layout.EntrySpacing * 2},
+		{"negative length", -100, 1, []float64{template.Layout.EntrySpacing / 2}, template.Layout.EntrySpacing},
+	}
+	for _, tt := range tests {
+		template.Layout.AxisLength = tt.axisLength
+		entries := make([]TimelineEntry, tt.entries)
+		for i := range entries {
+			entries[i] = TimelineEntry{Period: strconv.Itoa(2001 + i)}
+		}
+		data := calculateTimelinePositionsAndStyles(entries, template, initializeLayoutConfig(template))
+		if !slices.Equal(data.entryPoints, tt.wantPoints) {
+			t.Errorf("%s: expected entry points %v, got %v", tt.name, tt.wantPoints, data.entryPoints)
+		}
+		if got := data.junctionPoints[len(entries)]; got != tt.wantLength {
+			t.Errorf("%s: expected an axis of %g, got %g", tt.name, tt.wantLength, got)
+		}
+	}
+}
+
+// TestWarnOverlappingComments checks only intersecting comment boxes on the same side of the
+// axis are reported.
+func TestWarnOverlappingComments(t *testing.T) {
+	box := func(x float64) *CommentBlockLayout {
+		return &CommentBlockLayout{blockX: x, blockY: -100, visualBlockWidth: 100, visualBlockHeight: 80}
+	}
+	tests := []struct {
+		name    string
+		layouts []EntryLayout
+		want    int
+	}{
+		{"overlapping", []EntryLayout{{Comment: box(0), CommentSide: -1, IsHorizontal: true}, {Comment: box(60), CommentSide: -1, IsHorizontal: true}}, 1},
+		{"side by side", []EntryLayout{{Comment: box(0), CommentSide: -1, IsHorizontal: true}, {Comment: box(100), CommentSide: -1, IsHorizontal: true}}, 0},
+		{"opposite sides", []EntryLayout{{Comment: box(0), CommentSide: -1, IsHorizontal: true}, {Comment: box(60), CommentSide: 1, IsHorizontal: true}}, 0},
+		{"no comment", []EntryLayout{{Comment: box(0), CommentSide: -1, IsHorizontal: true}, {CommentSide: -1, IsHorizontal: true}}, 0},
+	}
+	for _, tt := range tests {
+		diag := &diagnosticLog{}
+		warnOverlappingComments(diag, tt.layouts)
+		if len(diag.warnings) != tt.want {
+			t.Errorf("%s: expected %d overlap warnings, got %v", tt.name, tt.want, diag.warnings)
+		}
+		if tt.want > 0 && (diag.warnings[0].Code != "overlap" || !strings.Contains(diag.warnings[0].Message, "3200 px²")) {
+			t.Errorf("%s: expected an overlap warning with the 40x80 area, got %v", tt.name, diag.warnings[0])
+		}
+	}
+}
+
+// TestTitleLineTypeDashArray checks the title underline gets the dash pattern of its
+// line_type, scaled with its width, and a solid line gets none.
+func TestTitleLineTypeDashArray(t *testing.T) {
+	for lineType, want := range map[string]string{"dashed": ` stroke-dasharray="8 4"`, "dotted": ` stroke-dasharray="2 4"`, "": ""} {
+		var svg svgBuffer
+		var b bounds
+		drawCommentTitleLine(&svg, &b, CommentTitleLineParams{
+			TitleLine:   TitleLineStyle{Visible: true, Color: "#000000", Width: 2, Length: 40, LineType: lineType},
+			StrokeScale: 1,
+		})
+		got := svg.String()
+		if strings.Count(got, "<line") != 1 {
+			t.Fatalf("line_type %q: expected one line, got %s", lineType, got)
+		}
+		if want == "" && strings.Contains(got, "stroke-dasharray") {
+			t.Errorf("Expected a solid title line to have no dasharray, got %s", got)
+		} else if want != "" && !strings.Contains(got, want+" />") {
+			t.Errorf("line_type %q: expected%s, got %s", lineType, want, got)
+		}
+	}
+}
@@ -1,9 +1,19 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"hash/fnv"
+	"html"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 // --- Helper Functions for Effective Styles ---
@@ -45,6 +55,8 @@ func getEffectiveJunctionMarkerStyle(defaults JunctionMarkerStyle, override *Jun
 	if override.Color != nil {
 		effective.Color = override.Color // Pointer allows explicit override
 	} // Keep default struct Color pointer if override doesn't specify
+	effective.BorderColor = getString(override.BorderColor, defaults.BorderColor)
+	effective.BorderWidth = getFloat64(override.BorderWidth, defaults.BorderWidth)
 	return effective
 }
 
@@ -58,6 +70,7 @@ func getEffectiveTitleLineStyle(defaults TitleLineStyle, override *TitleLineStyl
 	effective.Width = getFloat64(override.Width, defaults.Width)
 	effective.Length = getFloat64(override.Length, defaults.Length)
 	effective.Margin = getFloat64(override.Margin, defaults.Margin)
+	effective.LineType = getString(override.LineType, defaults.LineType)
 
 	// Re-evaluate visibility based on dimensions if not explicitly set by override
 	if override.Visible == nil { // If visibility wasn't overridden
@@ -87,6 +100,8 @@ func getEffectiveDotStyle(defaults DotStyle, override *DotStyleOverride) DotStyl
 	effective.OffsetCross = getInt(override.OffsetCross, defaults.OffsetCross)
 	// Default stop_at_dot to true if not overridden
 	effective.StopAtDot = getBool(override.StopAtDot, true)
+	effective.ElbowAt = getString(override.ElbowAt, defaults.ElbowAt)
+	effective.ArrowRatio = getFloat64(override.ArrowRatio, defaults.ArrowRatio)
 	return effective
 }
 
@@ -101,6 +116,8 @@ func getEffectiveConnectorStyle(defaults ConnectorStyle, override *ConnectorStyl
 	effective.Color = getString(override.Color, defaults.Color)
 	effective.LineType = getString(override.LineType, defaults.LineType)
 	effective.Width = getInt(override.Width, defaults.Width)
+	effective.Routing = getString(override.Routing, defaults.Routing)
+	effective.GradientToElement = getBool(override.GradientToElement, defaults.GradientToElement)
 	// Use getBool to merge the flags, providing a default value (true)
 	defaultDrawToPeriod := true
 	if defaults.DrawToPeriod != nil { // If default struct has a non-nil value, use it
@@ -143,20 +160,8 @@ func getEffectiveConnectorStyle(defaults ConnectorStyle, override *ConnectorStyl
 }
 
 func getEffectiveCommentTextStyle(globalFont *FontStyle, defaults CommentTextStyle, override *CommentTextStyleOverride) CommentTextStyle {
-	// --- DEBUG LOGGING START ---
-	// log.Printf("DEBUG: Entering getEffectiveCommentTextStyle. Override provided: %t", override != nil)
-	// if override != nil {
-	// 	log.Printf("DEBUG: Checking override.MainAxisOffset. Is nil? %t", override.MainAxisOffset == nil)
-	// 	if override.MainAxisOffset != nil {
-	// 		log.Printf("DEBUG: override.MainAxisOffset value = %.2f", *override.MainAxisOffset)
-	// 	}
-	// 	log.Printf("DEBUG: Checking override.CrossAxisOffset. Is nil? %t", override.CrossAxisOffset == nil)
-	// 	if override.CrossAxisOffset != nil {
-	// 		log.Printf("DEBUG: override.CrossAxisOffset value = %.2f", *override.CrossAxisOffset)
-	// 	}
-	// }
-	// log.Printf("DEBUG: Default MainAxisOffset=%.2f, CrossAxisOffset=%.2f", defaults.MainAxisOffset, defaults.CrossAxisOffset)
-	// --- DEBUG LOGGING END ---
+	debugf("getEffectiveCommentTextStyle: override provided: %t, default MainAxisOffset=%.2f, CrossAxisOffset=%.2f",
+		override != nil, defaults.MainAxisOffset, defaults.CrossAxisOffset)
 
 	effective := defaults
 	bodyFontOverride := (*FontStyleOverride)(nil)
@@ -168,11 +173,34 @@ func getEffectiveCommentTextStyle(globalFont *FontStyle, defaults CommentTextSty
 		effective.MainAxisOffset = getFloat64(override.MainAxisOffset, defaults.MainAxisOffset) // Tries to apply override
 		effective.CrossAxisOffset = getFloat64(override.CrossAxisOffset, defaults.CrossAxisOffset)
 		effective.TitleColor = getString(override.TitleColor, defaults.TitleColor)
+		effective.TitlePosition = getString(override.TitlePosition, defaults.TitlePosition)
 		effective.Shape = getString(override.Shape, defaults.Shape)
 		effective.FillColor = getString(override.FillColor, defaults.FillColor)
+		if override.FillOpacity != nil {
+			effective.FillOpacity = override.FillOpacity
+		}
 		effective.TextColor = getString(override.TextColor, defaults.TextColor)
 		effective.Padding = getString(override.Padding, defaults.Padding)
 		effective.BlockWidth = override.BlockWidth // Directly assign pointer; nil if not overridden
+		if override.MaxBlockWidth != nil {
+			effective.MaxBlockWidth = override.MaxBlockWidth
+		}
+		if override.BlockHeight != nil {
+			effective.BlockHeight = override.BlockHeight
+		}
+		if override.LineHeight != nil {
+			effective.LineHeight = override.LineHeight
+		}
+		effective.ImagePosition = getString(override.ImagePosition, defaults.ImagePosition)
+		if override.Tail != nil {
+			effective.Tail = *override.Tail
+		}
+		if override.TailSize != nil {
+			effective.TailSize = *override.TailSize
+		}
+		if override.EdgeAnchor != nil {
+			effective.EdgeAnchor = override.EdgeAnchor
+		}
 		effective.BorderColor = getString(override.BorderColor, defaults.BorderColor)
 		effective.BorderWidth = getInt(override.BorderWidth, defaults.BorderWidth)
 		effective.BorderStyle = getString(override.BorderStyle, defaults.BorderStyle)
@@ -193,13 +221,14 @@ func getEffectiveCommentTextStyle(globalFont *FontStyle, defaults CommentTextSty
 	// Get effective title line style
 	effective.TitleLine = getEffectiveTitleLineStyle(defaults.TitleLine, titleLineOverride)
 
-	// log.Printf("DEBUG: Exiting getEffectiveCommentTextStyle. Effective MainOffset=%.2f, CrossOffset=%.2f", effective.MainAxisOffset, effective.CrossAxisOffset) // Log effective values
+	debugf("getEffectiveCommentTextStyle: effective MainOffset=%.2f, CrossOffset=%.2f", effective.MainAxisOffset, effective.CrossAxisOffset)
 	return effective
 }
 
 func getEffectiveYearTextStyle(globalFont *FontStyle, defaults YearTextStyle, override *YearTextStyleOverride) YearTextStyle {
 	effective := defaults
 	fontOverride := (*FontStyleOverride)(nil) // Start with nil font override
+	subtitleFontOverride := (*FontStyleOverride)(nil)
 
 	if override != nil {
 		effective.Position = getString(override.Position, defaults.Position)
@@ -208,12 +237,28 @@ func getEffectiveYearTextStyle(globalFont *FontStyle, defaults YearTextStyle, ov
 		effective.TextColor = getString(override.TextColor, defaults.TextColor)
 		effective.Shape = getString(override.Shape, defaults.Shape)
 		effective.FillColor = getString(override.FillColor, defaults.FillColor)
+		if override.FillOpacity != nil {
+			effective.FillOpacity = override.FillOpacity
+		}
 		effective.BorderColor = getString(override.BorderColor, defaults.BorderColor)
 		effective.BorderWidth = getFloat64(override.BorderWidth, defaults.BorderWidth)
+		if override.LineHeight != nil {
+			effective.LineHeight = override.LineHeight
+		}
+		if override.Rotate != nil {
+			effective.Rotate = override.Rotate
+		}
+		effective.AutoFitText = getBool(override.AutoFitText, defaults.AutoFitText)
 		fontOverride = override.Font // Assign the font override struct if present
+		subtitleFontOverride = override.SubtitleFont
 	}
 
 	effective.Font = getEffectiveFontStyle(globalFont, defaults.Font, fontOverride)
+	// The subtitle inherits the year font, scaled down and in normal weight
+	subtitleBase := effective.Font
+	subtitleBase.FontSize = int(math.Round(float64(effective.Font.FontSize) * subtitleFontScale))
+	subtitleBase.FontWeight = "normal"
+	effective.SubtitleFont = getEffectiveFontStyle(&subtitleBase, defaults.SubtitleFont, subtitleFontOverride)
 
 	// Default border color to text color if not set? Or connector color? Let's leave empty for now.
 	// if effective.BorderColor == "" && effective.Shape == "circle" { effective.BorderColor = effective.TextColor }
@@ -229,9 +274,296 @@ func getEffectiveCenterlineProjectionStyle(defaults CenterlineProjectionStyle, o
 	if override.Color != "" {
 		effective.Color = override.Color
 	}
+	if override.Width != nil {
+		effective.Width = override.Width
+	}
 	return effective
 }
 
+// --- Entry Filtering ---
+
+// visibleEntries returns the entries that are not marked hidden. Filtering happens
+// before any layout so hidden entries don't leave gaps on the axis.
+func visibleEntries(entries []TimelineEntry) []TimelineEntry {
+	visible := make([]TimelineEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.Hidden {
+			visible = append(visible, entry)
+		}
+	}
+	return visible
+}
+
+// filterEntriesByTags keeps the entries having at least one of the given tags.
+// With no tags every entry is kept. Like hidden entries, dropped entries leave no gap.
+// It returns an error when the filter matches nothing.
+func filterEntriesByTags(entries []TimelineEntry, tags []string) ([]TimelineEntry, error) {
+	if len(tags) == 0 {
+		return entries, nil
+	}
+	wanted := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		wanted[tag] = true
+	}
+	filtered := make([]TimelineEntry, 0, len(entries))
+	for _, entry := range entries {
+		for _, tag := range entry.Tags {
+			if wanted[tag] {
+				filtered = append(filtered, entry)
+				break
+			}
+		}
+	}
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no entries match tags: %s", strings.Join(tags, ", "))
+	}
+	return filtered, nil
+}
+
+// entryCalloutPadding is the canvas padding of a -entry callout when -padding is not given.
+const entryCalloutPadding = 10.0
+
+// selectEntry keeps the 1-based visible entry n plus up to context visible neighbors on each
+// side, for exporting a single entry as a tight callout. It also returns the number of visible
+// entries before the kept ones (LayoutOptions.entryOffset), and an error when n is out of range.
+func selectEntry(entries []TimelineEntry, n, context int) ([]TimelineEntry, int, error) {
+	entries = visibleEntries(entries) // Hidden entries are not drawn, so they aren't counted either
+	if n < 1 || n > len(entries) {
+		return nil, 0, fmt.Errorf("entry %d is out of range (1-%d)", n, len(entries))
+	}
+	context = max(context, 0)
+	start := max(n-1-context, 0)
+	end := min(n+context, len(entries))
+	return entries[start:end], start, nil
+}
+
+// --- Comment Files ---
+
+// commentFilePrefix marks a comment_text that names a markdown file instead of holding the text.
+const commentFilePrefix = "@file:"
+
+// resolveCommentFiles replaces every comment_text of the form "@file:path" with the contents
+// of that file, resolved relative to baseDir (the data file's directory) unless absolute.
+// Other comment texts are kept as they are. A missing or unreadable file is an error.
+func resolveCommentFiles(entries []TimelineEntry, baseDir string) ([]TimelineEntry, error) {
+	resolved := make([]TimelineEntry, len(entries))
+	for i, entry := range entries {
+		resolved[i] = entry
+		path, isFile := strings.CutPrefix(entry.CommentText, commentFilePrefix)
+		if !isFile {
+			continue
+		}
+		path = strings.TrimSpace(path)
+		if path == "" {
+			return nil, fmt.Errorf("entry %d (%s): comment_text '%s' names no file", i, entry.Period, entry.CommentText)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(baseDir, path)
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("entry %d (%s): could not read comment file '%s': %w", i, entry.Period, path, err)
+		}
+		resolved[i].CommentText = strings.TrimRight(string(content), "\r\n") // Editors add a final newline
+	}
+	return resolved, nil
+}
+
+// resolveDataFiles resolves the @file: comments of entries read from source. Only a local
+// data file may reach into the file system: data from stdin ("-") or an http(s) URL is
+// rejected with ErrLocalReference if it has @file: comments or local image paths, so it
+// can't pull arbitrary files such as ../../.ssh/id_rsa into the rendered output.
+func resolveDataFiles(entries []TimelineEntry, source string) ([]TimelineEntry, error) {
+	if source != "-" && !isRemoteInput(source) {
+		return resolveCommentFiles(entries, filepath.Dir(source))
+	}
+	var refs []string
+	for i, entry := range entries {
+		if strings.HasPrefix(entry.CommentText, commentFilePrefix) {
+			refs = append(refs, fmt.Sprintf("entry %d (%s) comment_text '%s'", i, entry.Period, truncateForLog(entry.CommentText)))
+		}
+		for _, image := range []struct{ field, src string }{
+			{"comment_image", entry.CommentImage},
+			{"thumbnail_image", entry.ThumbnailImage},
+		} {
+			if image.src != "" && !isRemoteImage(image.src) {
+				refs = append(refs, fmt.Sprintf("entry %d (%s) %s '%s'", i, entry.Period, image.field, image.src))
+			}
+		}
+	}
+	if len(refs) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrLocalReference, strings.Join(refs, "; "))
+	}
+	return entries, nil
+}
+
+// --- Period Dates ---
+
+// periodDateLayouts are the date formats accepted for date-based positioning.
+var periodDateLayouts = []string{"2006-01-02", "2006-01", "2006"}
+
+// resolveCrossSide returns the side (+1/-1) an element ends up on when placed distance
+// (connector length + cross-axis offset) from the axis on side crossDir: a negative
+// distance flips it to the opposite side, zero keeps crossDir.
+func resolveCrossSide(crossDir, distance float64) float64 {
+	if distance < 0 {
+		return -crossDir
+	}
+	return crossDir
+}
+
+// parsePeriodTime parses a period in one of periodDateLayouts. It reports false for free text labels.
+func parsePeriodTime(period string) (time.Time, bool) {
+	period = strings.TrimSpace(period)
+	for _, layout := range periodDateLayouts {
+		if t, err := time.Parse(layout, period); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// parsePeriodDate parses a period such as "1999", "1999-07" or "1999-07-14" into a
+// fractional year (1999.5 for mid 1999). It reports false for free text labels.
+func parsePeriodDate(period string) (float64, bool) {
+	t, ok := parsePeriodTime(period)
+	if !ok {
+		return 0, false
+	}
+	yearStart := time.Date(t.Year(), time.January, 1, 0, 0, 0, 0, time.UTC)
+	yearEnd := yearStart.AddDate(1, 0, 0)
+	return float64(t.Year()) + t.Sub(yearStart).Hours()/yearEnd.Sub(yearStart).Hours(), true
+}
+
+// minDateSpacingFactor is the smallest gap (as a fraction of the base spacing) left between
+// entries with equal or out-of-order dates under date-based spacing.
+const minDateSpacingFactor = 0.1
+
+// dateSpacings returns the spacing after each entry when gaps follow the time between
+// consecutive entries: proportional to the delta ("linear") or to log(1 + delta/smallest delta)
+// ("log"). Gaps are normalized so their mean stays baseSpacing; the last entry keeps baseSpacing.
+// It reports false if any period is not a date.
+func dateSpacings(diag *diagnosticLog, entries []TimelineEntry, scale string, baseSpacing float64) ([]float64, bool) {
+	dates := make([]float64, len(entries))
+	for i, entry := range entries {
+		date, ok := parsePeriodDate(entry.Period)
+		if !ok {
+			return nil, false
+		}
+		dates[i] = date
+	}
+
+	spacings := make([]float64, len(entries))
+	if len(entries) < 2 {
+		for i := range spacings {
+			spacings[i] = baseSpacing
+		}
+		return spacings, true
+	}
+
+	deltas := make([]float64, len(entries)-1)
+	minPositive := math.Inf(1)
+	for i := range deltas {
+		deltas[i] = dates[i+1] - dates[i]
+		if deltas[i] < 0 {
+			diag.warnf("date_order", "entry %d (%s) is dated before the previous entry; using the minimum gap.", i+1, entries[i+1].Period)
+			deltas[i] = 0
+		}
+		if deltas[i] > 0 {
+			minPositive = math.Min(minPositive, deltas[i])
+		}
+	}
+
+	weights := make([]float64, len(deltas))
+	total := 0.0
+	for i, delta := range deltas {
+		if scale == "log" && delta > 0 {
+			weights[i] = math.Log1p(delta / minPositive) // Zero/negative deltas keep weight 0
+		} else {
+			weights[i] = delta
+		}
+		total += weights[i]
+	}
+
+	minSpacing := baseSpacing * minDateSpacingFactor
+	for i, weight := range weights {
+		spacing := baseSpacing // All dates equal: fall back to even spacing
+		if total > 0 {
+			spacing = weight / total * baseSpacing * float64(len(weights))
+		}
+		spacings[i] = math.Max(spacing, minSpacing)
+	}
+	spacings[len(entries)-1] = baseSpacing
+	return spacings, true
+}
+
+// --- Entry IDs ---
+
+// slugify lowercases s and replaces every run of non-alphanumeric characters with a single '-'.
+func slugify(s string) string {
+	var buf strings.Builder
+	lastDash := true // Avoid a leading dash
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			buf.WriteRune(r)
+			lastDash = false
+		} else if !lastDash {
+			buf.WriteRune('-')
+			lastDash = true
+		}
+	}
+	return strings.TrimSuffix(buf.String(), "-")
+}
+
+// buildEntryIDs derives a unique, XML-safe id for every entry, used as an SVG fragment target.
+// Explicit entry IDs are preferred; otherwise the period is slugified. Duplicates get a numeric suffix.
+func buildEntryIDs(entries []TimelineEntry) []string {
+	ids := make([]string, len(entries))
+	used := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		id := slugify(entry.ID)
+		if id == "" {
+			id = slugify(entry.Period)
+			if id != "" {
+				id = "entry-" + id
+			}
+		}
+		if id == "" {
+			id = fmt.Sprintf("entry-%d", i+1)
+		}
+		if r := []rune(id)[0]; !unicode.IsLetter(r) { // XML ids must not start with a digit
+			id = "entry-" + id
+		}
+		base := id
+		for n := 2; used[id]; n++ {
+			id = fmt.Sprintf("%s-%d", base, n)
+		}
+		used[id] = true
+		ids[i] = id
+	}
+	return ids
+}
+
+// fillOpacity returns the fill_opacity value clamped to 0-1, ok is false when it is unset.
+func fillOpacity(diag *diagnosticLog, opacity *float64) (float64, bool) {
+	if opacity == nil {
+		return 0, false
+	}
+	if *opacity < 0 || *opacity > 1 {
+		diag.warnf("invalid_option", "fill_opacity %g is outside 0-1, clamping it.", *opacity)
+	}
+	return math.Min(math.Max(*opacity, 0), 1), true
+}
+
+// fillOpacityAttr returns the fill-opacity attribute for a fill_opacity value, or "" when unset.
+func (c *renderContext) fillOpacityAttr(opacity *float64) string {
+	value, ok := fillOpacity(c.warnings(), opacity)
+	if !ok {
+		return ""
+	}
+	return ` fill-opacity="` + strconv.FormatFloat(value, 'f', -1, 64) + `"`
+}
+
 // --- SVG Dash Array Helper --- (No changes needed)
 func getStrokeDashArray(styleType string, width int) string {
 	// ... (implementation from previous step) ...
@@ -250,27 +582,11 @@ func getStrokeDashArray(styleType string, width int) string {
 	return dashArray
 }
 
-// --- XML/HTML Escaping --- (No changes needed)
+// --- XML/HTML Escaping ---
+
+// escapeXML escapes & < > " and ' so s is safe in text content and quoted attribute values.
 func escapeXML(s string) string {
-	// ... (implementation from previous step) ...
-	var buf strings.Builder
-	for _, r := range s {
-		switch r {
-		case '&':
-			buf.WriteString("&")
-		case '<':
-			buf.WriteString("<")
-		case '>':
-			buf.WriteString(">")
-		case '"':
-			buf.WriteString("\"")
-		case '\'':
-			buf.WriteString("'") // ' is not valid in HTML4
-		default:
-			buf.WriteRune(r)
-		}
-	}
-	return buf.String()
+	return html.EscapeString(s)
 }
 
 var escapeHTML = escapeXML
@@ -295,7 +611,7 @@ func getCrossAxisDirection(position string, index int, isHorizontal bool) float6
 		isStart = (index%2 != 0) // Odd index = start
 	default: // Default to start for safety
 		isStart = true
-		// log.Printf("Warning: Unknown position '%s', defaulting to 'start'", position)
+		debugf("unknown position '%s', defaulting to 'start'", position)
 	}
 
 	if isStart {
@@ -304,6 +620,33 @@ func getCrossAxisDirection(position string, index int, isHorizontal bool) float6
 	return 1.0 // End = Bottom (+Y) or Right (+X)
 }
 
+// applyEntrySides overrides the computed comment/year sides with the entry's comment_side and
+// year_side ("start" = -1, "end" = +1). When only one is set, the other goes to the opposite side;
+// when both are set they may share a side. Unknown values are ignored with a warning.
+func applyEntrySides(diag *diagnosticLog, entry TimelineEntry, commentDir, yearDir float64) (float64, float64) {
+	side := func(field, value string) (float64, bool) {
+		switch value {
+		case "":
+			return 0, false
+		case "start", "end":
+			return getCrossAxisDirection(value, 0, false), true
+		}
+		diag.warnf("invalid_option", "unknown %s '%s' on entry %s (use 'start' or 'end'), ignoring it.", field, value, entry.Period)
+		return 0, false
+	}
+	commentSide, hasComment := side("comment_side", entry.CommentSide)
+	yearSide, hasYear := side("year_side", entry.YearSide)
+	switch {
+	case hasComment && hasYear:
+		return commentSide, yearSide
+	case hasComment:
+		return commentSide, -commentSide
+	case hasYear:
+		return -yearSide, yearSide
+	}
+	return commentDir, yearDir
+}
+
 // Helper to get effective FontStyle considering global, default, and override
 func getEffectiveFontStyle(global *FontStyle, defaults FontStyle, override *FontStyleOverride) FontStyle {
 	base := defaults // Start with the specific component's default
@@ -356,8 +699,224 @@ func getEffectiveFontStyle(global *FontStyle, defaults FontStyle, override *Font
 	return effective
 }
 
+// resolveCommentTextAlign maps a configured comment text_align to a CSS value.
+// "auto" aligns text toward the axis on vertical timelines (right on the left side,
+// left on the right side) and centers it on horizontal ones; empty means center.
+func resolveCommentTextAlign(textAlign string, isHorizontal bool, crossAxisDir float64) string {
+	switch textAlign {
+	case "":
+		return "center"
+	case "auto":
+		if isHorizontal {
+			return "center"
+		}
+		return ternary(crossAxisDir < 0, "right", "left")
+	}
+	return textAlign
+}
+
+// resolveCommentImagePosition validates a comment image_position, defaulting to "top".
+func resolveCommentImagePosition(diag *diagnosticLog, position string) string {
+	switch position {
+	case "", "top":
+		return "top"
+	case "bottom", "left", "right":
+		return position
+	}
+	diag.warnf("invalid_option", "unknown comment image_position '%s', using 'top'.", position)
+	return "top"
+}
+
+// commentImageCSS returns the inline style placing a comment image at position
+// (already resolved); left/right float the image beside the text.
+func commentImageCSS(position string) string {
+	switch position {
+	case "left":
+		return "max-width: 40%; height: auto; float: left; margin: 0 5px 5px 0;"
+	case "right":
+		return "max-width: 40%; height: auto; float: right; margin: 0 0 5px 5px;"
+	case "bottom":
+		return "max-width: 100%; height: auto; display: block; margin-top: 5px;"
+	}
+	return "max-width: 100%; height: auto; display: block; margin-bottom: 5px;"
+}
+
+// --- Coordinate Formatting ---
+
+const (
+	defaultCoordPrecision = 2 // Matches the historical %.2f output
+	maxCoordPrecision     = 8
+)
+
+// renderContext holds the output settings of one render. Every buffer of the render shares
+// it, so concurrent renders each write with their own settings; nil writes the defaults.
+type renderContext struct {
+	precision int               // Decimals written by coord (Layout.Precision)
+	colorMap  map[string]string // Color remapping of mapColor, keys lowercased (Layout.ColorMap)
+	jitter    float64           // Largest jitterPoint offset, 0 = none (Layout.Jitter)
+	seed      int64             // Seed of the jitterPoint offsets (Layout.Seed)
+
+	diagnostics *diagnosticLog    // Warnings of the render
+	images      map[string]string // Data URIs of the local images embedded so far, by path
+}
+
+// warnings returns the warning log of the render, nil without a render context.
+func (c *renderContext) warnings() *diagnosticLog {
+	if c == nil {
+		return nil
+	}
+	return c.diagnostics
+}
+
+// warnf raises a warning of the render.
+func (c *renderContext) warnf(code, format string, args ...any) {
+	c.warnings().warnf(code, format, args...)
+}
+
+// svgBuffer is an SVG output buffer together with the settings of the render it belongs to.
+type svgBuffer struct {
+	bytes.Buffer
+	*renderContext
+}
+
+// fcoord formats a coordinate or length for SVG output with precision decimals.
+func fcoord(v float64, precision int) string {
+	return strconv.FormatFloat(v, 'f', precision, 64)
+}
+
+// coord formats a coordinate or length with the render's precision.
+func (c *renderContext) coord(v float64) string {
+	if c == nil {
+		return fcoord(v, defaultCoordPrecision)
+	}
+	return fcoord(v, c.precision)
+}
+
+// jitterPoint moves a drawn point by up to ±jitter on each axis for a hand-drawn look.
+// The offset comes from an RNG seeded with the render's seed and the point itself, so output
+// is reproducible for a seed and a point shared by several elements (e.g. a junction) moves
+// the same way in each of them.
+func (c *renderContext) jitterPoint(x, y float64) (float64, float64) {
+	if c == nil || c.jitter <= 0 {
+		return x, y
+	}
+	hash := fnv.New64a()
+	fmt.Fprintf(hash, "%d:%s,%s", c.seed, fcoord(x, defaultCoordPrecision), fcoord(y, defaultCoordPrecision))
+	rng := rand.New(rand.NewSource(int64(hash.Sum64())))
+	return x + (rng.Float64()*2-1)*c.jitter, y + (rng.Float64()*2-1)*c.jitter
+}
+
+// lineJoinAttr returns the stroke-linejoin attribute of multi-segment connectors and center
+// line paths for lineJoin (Layout.LineJoin), or "" for the SVG default, miter.
+func lineJoinAttr(lineJoin string) string {
+	if lineJoin == "" {
+		return ""
+	}
+	return fmt.Sprintf(` stroke-linejoin="%s"`, lineJoin)
+}
+
+// minImportanceFactor keeps markers visible for negative importance values.
+const minImportanceFactor = 0.1
+
+// importanceFactor returns the size multiplier for an entry importance:
+// 1 + importance*scale, so importance 0 leaves sizes unchanged.
+func importanceFactor(importance, scale float64) float64 {
+	return math.Max(1+importance*scale, minImportanceFactor)
+}
+
+// --- Color Remapping ---
+
+// normalizeColorMap lowercases and trims the keys so lookups are case-insensitive.
+func normalizeColorMap(m map[string]string) map[string]string {
+	if len(m) == 0 {
+		return nil
+	}
+	normalized := make(map[string]string, len(m))
+	for from, to := range m {
+		normalized[strings.ToLower(strings.TrimSpace(from))] = to
+	}
+	return normalized
+}
+
+// isURLReference reports whether a color/fill value references a def, e.g. "url(#myGrad)".
+// Such values must reach the SVG verbatim.
+func isURLReference(value string) bool {
+	return strings.HasPrefix(strings.ToLower(strings.TrimSpace(value)), "url(")
+}
+
+// mapColor returns the replacement for color from colorMap (keys normalized by
+// normalizeColorMap), or color itself. url(#...) references are never remapped.
+func mapColor(colorMap map[string]string, color string) string {
+	if isURLReference(color) {
+		return color
+	}
+	if to, ok := colorMap[strings.ToLower(strings.TrimSpace(color))]; ok && color != "" {
+		return to
+	}
+	return color
+}
+
+// mapColor replaces color through the render's color map.
+func (c *renderContext) mapColor(color string) string {
+	if c == nil {
+		return color
+	}
+	return mapColor(c.colorMap, color)
+}
+
 // --- Text Dimension Estimation Helpers ---
 
+// defaultLineHeight is the line spacing multiplier assumed when none is configured.
+const defaultLineHeight = 1.2
+
+// getLineHeightFactor returns the configured line height multiplier, or defaultLineHeight.
+func getLineHeightFactor(lineHeight *float64) float64 {
+	if lineHeight != nil && *lineHeight > 0 {
+		return *lineHeight
+	}
+	return defaultLineHeight
+}
+
+// getEffectiveCaptionFont resolves the caption font against the global font,
+// falling back to a small default size.
+func getEffectiveCaptionFont(template Template) FontStyle {
+	font := getEffectiveFontStyle(template.GlobalFont, template.Layout.CaptionFont, nil)
+	if font.FontSize <= 0 {
+		font.FontSize = defaultCaptionFontSize
+	}
+	return font
+}
+
+// getEffectiveNumberStyle fills in the number badge defaults: a small bold global font,
+// white text and the default badge size. An empty fill keeps the junction marker color.
+func getEffectiveNumberStyle(template Template) NumberBadgeStyle {
+	style := template.Layout.NumberStyle
+	if style.Font.FontSize <= 0 {
+		style.Font.FontSize = defaultNumberFontSize
+	}
+	if style.Font.FontWeight == "" {
+		style.Font.FontWeight = "bold"
+	}
+	style.Font = getEffectiveFontStyle(template.GlobalFont, style.Font, nil)
+	if style.TextColor == "" {
+		style.TextColor = "#FFFFFF"
+	}
+	if style.Size <= 0 {
+		style.Size = defaultNumberBadgeSize
+	}
+	return style
+}
+
+// fontAscentFactor is the typical height of glyphs above the alphabetic baseline
+// (roughly the cap height of common sans-serif fonts) as a fraction of the font size.
+const fontAscentFactor = 0.7
+
+// baselineCompatOffset is how far below the intended vertical center the alphabetic
+// baseline must be placed for text to look centered: half the ascent.
+func baselineCompatOffset(fontSize int) float64 {
+	return float64(fontSize) * fontAscentFactor / 2.0
+}
+
 // getEstimatedHeight provides a rough estimate of text height based on font size.
 // SVG coordinates often need slight adjustments based on baseline, etc.
 func getEstimatedHeight(font FontStyle) float64 {
@@ -365,27 +924,93 @@ func getEstimatedHeight(font FontStyle) float64 {
 	if font.FontSize <= 0 {
 		return 15 // Default height if font size is invalid
 	}
-	return float64(font.FontSize) * 1.2
+	return float64(font.FontSize) * defaultLineHeight
 }
 
 // estimateTextSVGWidth provides a very rough estimate of text width.
-// Accurate SVG text width calculation is complex; this uses a simple heuristic.
+// Accurate SVG text width calculation is complex; this uses a simple per-character heuristic
+// that accounts for East-Asian wide characters and emoji being roughly twice as wide as Latin.
 func estimateTextSVGWidth(text string, font FontStyle) float64 {
 	if font.FontSize <= 0 || text == "" {
 		return 0
 	}
-	// Heuristic: average character width is roughly 0.6 * font size for proportional fonts
-	averageCharWidthFactor := 0.6
-	estimatedWidth := float64(len([]rune(text))) * float64(font.FontSize) * averageCharWidthFactor
-	return estimatedWidth
+	totalFactor := 0.0
+	for _, r := range text {
+		totalFactor += charWidthFactor(r)
+	}
+	return totalFactor * float64(font.FontSize)
+}
+
+// estimateBodyTextWidth returns the estimated width of the widest line of a comment body
+// laid out without wrapping. Markdown links count only their visible text.
+func estimateBodyTextWidth(body string, font FontStyle) float64 {
+	widest := 0.0
+	for _, line := range strings.Split(markdownLinkRegex.ReplaceAllString(body, "$1"), "\n") {
+		widest = math.Max(widest, estimateTextSVGWidth(line, font))
+	}
+	return widest
+}
+
+// estimateWrappedLineCount returns how many lines the comment body needs when wrapped at width.
+func estimateWrappedLineCount(body string, font FontStyle, width float64) int {
+	if body == "" {
+		return 0
+	}
+	count := 0
+	for _, line := range strings.Split(markdownLinkRegex.ReplaceAllString(body, "$1"), "\n") {
+		lineWidth := estimateTextSVGWidth(line, font)
+		if width <= 0 || lineWidth <= width {
+			count++
+			continue
+		}
+		count += int(math.Ceil(lineWidth / width))
+	}
+	return count
+}
+
+// charWidthFactor returns the approximate advance width of r as a fraction of the font size.
+func charWidthFactor(r rune) float64 {
+	const (
+		narrowFactor = 0.55 // Average proportional Latin/Greek/Cyrillic glyph
+		wideFactor   = 1.0  // CJK ideographs, kana, hangul, fullwidth forms and emoji
+	)
+	switch {
+	case r == 0x200D || (r >= 0xFE00 && r <= 0xFE0F) || unicode.Is(unicode.Mn, r):
+		return 0 // Zero-width joiners, variation selectors and combining marks
+	case isWideRune(r):
+		return wideFactor
+	default:
+		return narrowFactor
+	}
+}
+
+// isWideRune reports whether r is an East-Asian wide character or an emoji.
+func isWideRune(r rune) bool {
+	if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+		return true
+	}
+	switch {
+	case r >= 0x1100 && r <= 0x115F: // Hangul Jamo
+		return true
+	case r >= 0x2E80 && r <= 0x303E: // CJK radicals, symbols and punctuation
+		return true
+	case r >= 0xFF00 && r <= 0xFF60, r >= 0xFFE0 && r <= 0xFFE6: // Fullwidth forms
+		return true
+	case r >= 0x2600 && r <= 0x27BF: // Miscellaneous symbols and dingbats
+		return true
+	case r >= 0x1F000 && r <= 0x1FAFF: // Emoji, pictographs and related blocks
+		return true
+	}
+	return false
 }
 
 // --- Shape String Parsing ---
 
 // parsePadding parses a CSS-like padding string (e.g., "10", "10 20", "5 10 15 20")
 // into individual top, right, bottom, left float values.
-// Defaults to 0 if parsing fails or string is empty.
-func parsePadding(paddingStr string) (float64, float64, float64, float64) {
+// Defaults to 0 if parsing fails or string is empty; negative values are clamped to 0 and
+// values beyond the fourth are ignored, each with a warning.
+func parsePadding(diag *diagnosticLog, paddingStr string) (float64, float64, float64, float64) {
 	if paddingStr == "" {
 		return 0, 0, 0, 0
 	}
@@ -396,12 +1021,18 @@ func parsePadding(paddingStr string) (float64, float64, float64, float64) {
 	for _, part := range parts {
 		val, err := strconv.ParseFloat(part, 64)
 		if err != nil {
-			// log.Printf("Warning: Invalid padding value '%s', defaulting to 0: %v", part, err)
+			diag.warnf("invalid_option", "Invalid padding value '%s' in '%s', using 0", part, paddingStr)
 			values = append(values, 0) // Default invalid parts to 0
+		} else if val < 0 {
+			diag.warnf("invalid_option", "Negative padding value '%s' in '%s', using 0", part, paddingStr)
+			values = append(values, 0) // Negative padding would flip boxes inside out
 		} else {
 			values = append(values, val)
 		}
 	}
+	if len(values) > 4 {
+		diag.warnf("invalid_option", "Padding '%s' has more than 4 values, using the first 4", paddingStr)
+	}
 
 	switch len(values) {
 	case 1:
@@ -420,6 +1051,21 @@ func parsePadding(paddingStr string) (float64, float64, float64, float64) {
 	}
 }
 
+// resolveCanvasPadding returns the top, right, bottom and left canvas padding: the symmetric
+// padding, replaced by padding_string (parsePadding semantics) and then by any padding_top/
+// _right/_bottom/_left that is set. Negative values are treated as 0.
+func resolveCanvasPadding(diag *diagnosticLog, layout LayoutOptions, padding float64) (float64, float64, float64, float64) {
+	top, right, bottom, left := padding, padding, padding, padding
+	if strings.TrimSpace(layout.PaddingString) != "" {
+		top, right, bottom, left = parsePadding(diag, layout.PaddingString)
+	}
+	top = getFloat64(layout.PaddingTop, top)
+	right = getFloat64(layout.PaddingRight, right)
+	bottom = getFloat64(layout.PaddingBottom, bottom)
+	left = getFloat64(layout.PaddingLeft, left)
+	return math.Max(top, 0), math.Max(right, 0), math.Max(bottom, 0), math.Max(left, 0)
+}
+
 // parseShapeString extracts shape type and parameters from a string like "circle;r=10".
 // Returns shape type, a map of parameters, and an error if parsing fails.
 func parseShapeString(shapeStr string) (string, map[string]float64, error) { // NOSONAR
@@ -473,8 +1119,30 @@ func parseShapeString(shapeStr string) (string, map[string]float64, error) { //
 		// No parameters needed
 	default:
 		// Allow unknown shapes but don't validate params
-		// log.Printf("Warning: Unknown shape type '%s' encountered.", shapeType)
+		debugf("unknown shape type '%s' encountered.", shapeType)
 	}
 
 	return shapeType, params, nil
 }
+
+// defaultElementOrder is the draw order of an entry's parts when layout.element_order is unset:
+// each part is drawn over the ones before it.
+var defaultElementOrder = []string{"marker", "year", "comment"}
+
+// parseElementOrder parses a comma separated layout.element_order, which must name every
+// part of defaultElementOrder exactly once (case and spaces are ignored).
+func parseElementOrder(value string) ([]string, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != len(defaultElementOrder) {
+		return nil, false
+	}
+	order := make([]string, 0, len(parts))
+	for _, part := range parts {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if !slices.Contains(defaultElementOrder, part) || slices.Contains(order, part) {
+			return nil, false
+		}
+		order = append(order, part)
+	}
+	return order, true
+}
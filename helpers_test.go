@@ -0,0 +1,259 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestEstimateTextSVGWidthUnicode checks wide characters are weighted more than Latin text.
+func TestEstimateTextSVGWidthUnicode(t *testing.T) {
+	font := FontStyle{FontSize: 10}
+	tests := []struct {
+		name string
+		text string
+		want float64
+	}{
+		{"latin", "abcd", 4 * 0.55 * 10},
+		{"cjk", "中文字", 3 * 10},
+		{"kana", "かな", 2 * 10},
+		{"emoji", "🎉🚀", 2 * 10},
+		{"emoji with variation selector", "❤️", 10},
+		{"mixed", "ab中", 2*0.55*10 + 10},
+		{"empty", "", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := estimateTextSVGWidth(tt.text, font)
+			if math.Abs(got-tt.want) > 0.001 {
+				t.Errorf("estimateTextSVGWidth(%q) = %.2f, want %.2f", tt.text, got, tt.want)
+			}
+		})
+	}
+}
+
+// TestResolveCommentTextAlignAuto checks "auto" mirrors alignment by side only on vertical timelines.
+func TestResolveCommentTextAlignAuto(t *testing.T) {
+	cases := []struct {
+		align        string
+		isHorizontal bool
+		crossDir     float64
+		want         string
+	}{
+		{"auto", false, -1, "right"},
+		{"auto", false, 1, "left"},
+		{"auto", true, -1, "center"},
+		{"", false, -1, "center"},
+		{"left", false, -1, "left"},
+	}
+	for _, c := range cases {
+		if got := resolveCommentTextAlign(c.align, c.isHorizontal, c.crossDir); got != c.want {
+			t.Errorf("resolveCommentTextAlign(%q, %v, %.0f) = %q, want %q", c.align, c.isHorizontal, c.crossDir, got, c.want)
+		}
+	}
+}
+
+// TestFilterEntriesByTags checks include-any semantics and the error for an empty result.
+func TestFilterEntriesByTags(t *testing.T) {
+	entries := []TimelineEntry{
+		{Period: "2001", Tags: []string{"public"}},
+		{Period: "2002", Tags: []string{"internal"}},
+		{Period: "2003", Tags: []string{"internal", "press"}},
+		{Period: "2004"},
+	}
+
+	all, err := filterEntriesByTags(entries, nil)
+	if err != nil || len(all) != len(entries) {
+		t.Fatalf("No filter should keep all entries, got %d (%v)", len(all), err)
+	}
+
+	kept, err := filterEntriesByTags(entries, []string{"public", "press"})
+	if err != nil {
+		t.Fatalf("filterEntriesByTags returned error: %v", err)
+	}
+	if len(kept) != 2 || kept[0].Period != "2001" || kept[1].Period != "2003" {
+		t.Errorf("Expected entries 2001 and 2003, got %+v", kept)
+	}
+
+	if _, err := filterEntriesByTags(entries, []string{"nobody"}); err == nil {
+		t.Errorf("Expected an error when no entries match the filter")
+	}
+}
+
+// TestSelectEntry checks -entry keeps the entry with its context, rejects out-of-range
+// indexes and renders a smaller canvas than the full timeline.
+func TestSelectEntry(t *testing.T) {
+	entries := []TimelineEntry{
+		{Period: "2001", CommentText: "One"},
+		{Period: "2002", CommentText: "Two"},
+		{Period: "2003", CommentText: "Three"},
+		{Period: "2004", CommentText: "Four"},
+	}
+
+	single, offset, err := selectEntry(entries, 2, 0)
+	if err != nil || len(single) != 1 || single[0].Period != "2002" || offset != 1 {
+		t.Fatalf("Expected only entry 2002 after 1 entry, got %+v after %d (%v)", single, offset, err)
+	}
+	withContext, offset, err := selectEntry(entries, 1, 1)
+	if err != nil || len(withContext) != 2 || withContext[1].Period != "2002" || offset != 0 {
+		t.Errorf("Expected 2001 and its neighbor 2002, got %+v after %d (%v)", withContext, offset, err)
+	}
+	withHidden := append([]TimelineEntry{{Period: "2000", Hidden: true}}, entries...)
+	if picked, _, err := selectEntry(withHidden, 1, 0); err != nil || picked[0].Period != "2001" {
+		t.Errorf("Expected hidden entries not to be counted, got %+v (%v)", picked, err)
+	}
+	for _, n := range []int{-1, 5} {
+		if _, _, err := selectEntry(entries, n, 0); err == nil {
+			t.Errorf("Expected an error for entry %d", n)
+		}
+	}
+
+	template := loadTestTemplate(t)
+	canvasWidth := func(entries []TimelineEntry) float64 {
+		svg, err := GenerateSVG(template, entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG failed: %v", err)
+		}
+		var width, height float64
+		if _, err := fmt.Sscanf(svg, `<svg width="%g" height="%g"`, &width, &height); err != nil {
+			t.Fatalf("Could not read canvas size: %v", err)
+		}
+		return width
+	}
+	if full, cropped := canvasWidth(entries), canvasWidth(single); cropped >= full {
+		t.Errorf("Expected the single-entry canvas (%.0f) to be narrower than the full one (%.0f)", cropped, full)
+	}
+
+	// The second entry keeps the side it alternates to in the full timeline
+	commentAbove := func(template Template, entries []TimelineEntry, i int) bool {
+		_, layout, err := generateSVG(template, entries, nil)
+		if err != nil || layout.entryLayouts[i].Comment == nil {
+			t.Fatalf("generateSVG failed or drew no comment: %v", err)
+		}
+		return layout.entryLayouts[i].Comment.blockY < 0
+	}
+	callout := template
+	callout.Layout.entryOffset = 1
+	if full, alone := commentAbove(template, entries, 1), commentAbove(callout, single, 0); full != alone {
+		t.Errorf("Expected entry 2002 on the same side alone (above: %t) as in the full timeline (above: %t)", alone, full)
+	}
+}
+
+// TestResolveCommentFiles checks @file: references are read relative to the data directory,
+// inline text is untouched and a missing file is reported.
+func TestResolveCommentFiles(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "events"), 0o755); err != nil {
+		t.Fatalf("Could not create events dir: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "events", "1999.md"), []byte("**Big** [day](https://example.com)\n"), 0o644); err != nil {
+		t.Fatalf("Could not write markdown: %v", err)
+	}
+
+	entries := []TimelineEntry{
+		{Period: "1999", CommentText: "@file:events/1999.md"},
+		{Period: "2000", CommentText: "Inline text"},
+	}
+	resolved, err := resolveCommentFiles(entries, dir)
+	if err != nil {
+		t.Fatalf("resolveCommentFiles failed: %v", err)
+	}
+	if resolved[0].CommentText != "**Big** [day](https://example.com)" {
+		t.Errorf("Expected the file contents without the final newline, got %q", resolved[0].CommentText)
+	}
+	if resolved[1].CommentText != "Inline text" || entries[0].CommentText != "@file:events/1999.md" {
+		t.Errorf("Expected inline text kept and the input entries left unchanged")
+	}
+
+	_, err = resolveCommentFiles([]TimelineEntry{{Period: "2001", CommentText: "@file:events/missing.md"}}, dir)
+	if err == nil || !strings.Contains(err.Error(), "missing.md") {
+		t.Errorf("Expected an error naming the missing file, got %v", err)
+	}
+}
+
+// TestResolveDataFilesConfinesRemoteData checks data from a URL or stdin can't read local
+// files through @file: comments or image paths, while a local data file still can.
+func TestResolveDataFilesConfinesRemoteData(t *testing.T) {
+	for _, source := range []string{"https://example.com/data.json", "-"} {
+		for _, entry := range []TimelineEntry{
+			{Period: "2001", CommentText: "@file:/etc/passwd"},
+			{Period: "2001", CommentImage: "../../.ssh/id_rsa"},
+			{Period: "2001", ThumbnailImage: "/etc/hostname"},
+		} {
+			if _, err := resolveDataFiles([]TimelineEntry{entry}, source); !errors.Is(err, ErrLocalReference) {
+				t.Errorf("Expected ErrLocalReference for %+v from %s, got %v", entry, source, err)
+			}
+		}
+		safe := []TimelineEntry{{Period: "2001", CommentText: "Text", CommentImage: "https://example.com/a.png", ThumbnailImage: "data:image/png;base64,AA=="}}
+		if _, err := resolveDataFiles(safe, source); err != nil {
+			t.Errorf("Expected URLs and data URIs to be accepted from %s, got %v", source, err)
+		}
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "note.md"), []byte("Note"), 0o644); err != nil {
+		t.Fatalf("Could not write note: %v", err)
+	}
+	resolved, err := resolveDataFiles([]TimelineEntry{{Period: "2001", CommentText: "@file:note.md"}}, filepath.Join(dir, "data.json"))
+	if err != nil || resolved[0].CommentText != "Note" {
+		t.Errorf("Expected a local data file to resolve @file: next to it, got %v", err)
+	}
+}
+
+// TestApplyEntrySides checks explicit sides override parity, one side pushes the other
+// opposite, and both may share a side.
+func TestApplyEntrySides(t *testing.T) {
+	tests := []struct {
+		commentSide, yearSide string
+		wantComment, wantYear float64
+	}{
+		{"", "", 1, -1}, // Parity default passed in is kept
+		{"start", "", -1, 1},
+		{"", "end", -1, 1},
+		{"end", "end", 1, 1},
+		{"start", "start", -1, -1},
+		{"middle", "", 1, -1}, // Unknown values are ignored
+	}
+	for _, tt := range tests {
+		entry := TimelineEntry{Period: "2001", CommentSide: tt.commentSide, YearSide: tt.yearSide}
+		comment, year := applyEntrySides(nil, entry, 1, -1)
+		if comment != tt.wantComment || year != tt.wantYear {
+			t.Errorf("applyEntrySides(%q, %q) = %.0f, %.0f, want %.0f, %.0f", tt.commentSide, tt.yearSide, comment, year, tt.wantComment, tt.wantYear)
+		}
+	}
+}
+
+// TestParsePadding covers the CSS 1-4 value forms and the clamping of bad input.
+func TestParsePadding(t *testing.T) {
+	tests := []struct {
+		input                    string
+		top, right, bottom, left float64
+		warns                    int
+	}{
+		{"", 0, 0, 0, 0, 0},
+		{"10", 10, 10, 10, 10, 0},
+		{"10 20", 10, 20, 10, 20, 0},
+		{"10 20 30", 10, 20, 30, 20, 0},
+		{"10 20 30 40", 10, 20, 30, 40, 0},
+		{" 1.5\t2.5 ", 1.5, 2.5, 1.5, 2.5, 0},
+		{"10 20 30 40 50", 10, 20, 30, 40, 1},
+		{"-5", 0, 0, 0, 0, 1},
+		{"10 -5", 10, 0, 10, 0, 1},
+		{"abc 10", 0, 10, 0, 10, 1},
+	}
+	for _, tt := range tests {
+		diag := &diagnosticLog{}
+		top, right, bottom, left := parsePadding(diag, tt.input)
+		if top != tt.top || right != tt.right || bottom != tt.bottom || left != tt.left {
+			t.Errorf("parsePadding(%q) = %v %v %v %v, want %v %v %v %v",
+				tt.input, top, right, bottom, left, tt.top, tt.right, tt.bottom, tt.left)
+		}
+		if len(diag.warnings) != tt.warns {
+			t.Errorf("parsePadding(%q) produced %d warnings, want %d: %+v", tt.input, len(diag.warnings), tt.warns, diag.warnings)
+		}
+	}
+}
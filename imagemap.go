@@ -0,0 +1,82 @@
+// imagemap.go
+package main
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// imageMapNameUnsafe matches characters not kept in a <map> name derived from a file name.
+var imageMapNameUnsafe = regexp.MustCompile(`[^A-Za-z0-9_-]+`)
+
+// imageMapPath returns the sibling file the image map of imagePath is written to,
+// e.g. out/timeline.png -> out/timeline.map.html.
+func imageMapPath(imagePath string) string {
+	return strings.TrimSuffix(imagePath, filepath.Ext(imagePath)) + ".map.html"
+}
+
+// imageMapHTML returns an <img>/<map> snippet for imagePath with a clickable <area> per linked
+// year (link) and comment block (comment_link). layout is the one of the SVG the PNG/JPG was
+// rasterized from (with presetName and any measured body heights applied), so coordinates are
+// in pixels of that image.
+func imageMapHTML(template Template, layout timelineLayout, presetName, imagePath string) (string, error) {
+	template, screenshotScale, err := applyImagePreset(template, presetName)
+	if err != nil {
+		return "", err
+	}
+	if screenshotScale <= 0 {
+		screenshotScale = 1
+	}
+
+	mapName := imageMapNameUnsafe.ReplaceAllString(strings.TrimSuffix(filepath.Base(imagePath), filepath.Ext(imagePath)), "-")
+	if mapName == "" || mapName == "-" {
+		mapName = "timeline"
+	}
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "<img src=\"%s\" usemap=\"#%s\" width=\"%.0f\" height=\"%.0f\" alt=\"%s\">\n",
+		html.EscapeString(filepath.Base(imagePath)), mapName, layout.canvas.width*screenshotScale, layout.canvas.height*screenshotScale, htmlPageTitle(template))
+	fmt.Fprintf(&sb, "<map name=\"%s\">\n", mapName)
+	writeArea := func(minX, minY, maxX, maxY float64, href, alt string) {
+		x1, y1 := layout.canvas.toCanvas(minX, minY)
+		x2, y2 := layout.canvas.toCanvas(maxX, maxY)
+		fmt.Fprintf(&sb, "  <area shape=\"rect\" coords=\"%d,%d,%d,%d\" href=\"%s\" alt=\"%s\" target=\"_blank\">\n",
+			int(math.Floor(x1*screenshotScale)), int(math.Floor(y1*screenshotScale)),
+			int(math.Ceil(x2*screenshotScale)), int(math.Ceil(y2*screenshotScale)),
+			html.EscapeString(href), html.EscapeString(alt))
+	}
+	for i, entry := range layout.entries {
+		entryLayout := layout.entryLayouts[i]
+		if year := entryLayout.Year; entry.Link != "" && year.isSet {
+			writeArea(year.minX, year.minY, year.maxX, year.maxY, entry.Link, entry.Period)
+		}
+		// Like the SVG, a body with its own links keeps them instead of the block link
+		if block := entryLayout.Comment; entry.CommentLink != "" && block != nil && !containsLink(entry.CommentText) {
+			alt := entry.TitleText
+			if alt == "" {
+				alt = entry.Period
+			}
+			writeArea(block.blockX, block.blockY, block.blockX+block.visualBlockWidth, block.blockY+block.visualBlockHeight, entry.CommentLink, alt)
+		}
+	}
+	sb.WriteString("</map>\n")
+	return sb.String(), nil
+}
+
+// writeImageMap writes the image map of imagePath, rendered with layout, to its sibling
+// .map.html file.
+func writeImageMap(template Template, layout timelineLayout, presetName, imagePath string) (string, error) {
+	content, err := imageMapHTML(template, layout, presetName, imagePath)
+	if err != nil {
+		return "", err
+	}
+	mapPath := imageMapPath(imagePath)
+	if err := os.WriteFile(mapPath, []byte(content), 0o644); err != nil {
+		return "", fmt.Errorf("error writing image map '%s': %w", mapPath, err)
+	}
+	return mapPath, nil
+}
@@ -0,0 +1,80 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+// TestImageMapHasAreaPerLink checks every year link and comment link gets an <area> inside the canvas.
+func TestImageMapHasAreaPerLink(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{
+		{Period: "2001", Link: "https://example.com/2001", TitleText: "First", CommentText: "Body", CommentLink: "https://example.com/first"},
+		{Period: "2002", CommentText: "Not linked"},
+		{Period: "2003", Link: "https://example.com/2003", CommentText: "See [docs](https://example.com/docs)", CommentLink: "https://example.com/ignored"},
+	}
+
+	_, layout, err := generateSVG(template, entries, nil)
+	if err != nil {
+		t.Fatalf("generateSVG failed: %v", err)
+	}
+	mapHTML, err := imageMapHTML(template, layout, "", "out/timeline.png")
+	if err != nil {
+		t.Fatalf("imageMapHTML failed: %v", err)
+	}
+	if !strings.Contains(mapHTML, `<img src="timeline.png" usemap="#timeline"`) || !strings.Contains(mapHTML, `<map name="timeline">`) {
+		t.Errorf("Expected an image referencing the timeline map, got %s", mapHTML)
+	}
+	if got := strings.Count(mapHTML, "<area "); got != 3 {
+		t.Errorf("Expected 3 areas (two year links, one comment link), got %d:\n%s", got, mapHTML)
+	}
+	for _, href := range []string{"https://example.com/2001", "https://example.com/first", "https://example.com/2003"} {
+		if !strings.Contains(mapHTML, `href="`+href+`"`) {
+			t.Errorf("Expected an area linking to %s", href)
+		}
+	}
+	if strings.Contains(mapHTML, "ignored") {
+		t.Errorf("A comment_link on a body with its own links should not get an area")
+	}
+
+	svg, err := GenerateSVG(template, entries)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	var width, height int
+	if _, err := fmt.Sscanf(svg, `<svg width="%d" height="%d"`, &width, &height); err != nil {
+		t.Fatalf("Could not read canvas size: %v", err)
+	}
+	for _, line := range strings.Split(mapHTML, "\n") {
+		var x1, y1, x2, y2 int
+		if _, err := fmt.Sscanf(strings.TrimSpace(line), `<area shape="rect" coords="%d,%d,%d,%d"`, &x1, &y1, &x2, &y2); err != nil {
+			continue
+		}
+		if x1 < 0 || y1 < 0 || x2 > width || y2 > height || x1 >= x2 || y1 >= y2 {
+			t.Errorf("Area %d,%d,%d,%d is outside the %dx%d canvas", x1, y1, x2, y2, width, height)
+		}
+	}
+}
+
+// TestImageMapFollowsRenderedLayout checks the areas come from the layout the image was
+// rendered with, so measured body heights (precise_image_layout) move them too.
+func TestImageMapFollowsRenderedLayout(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", TitleText: "First", CommentText: "Body", CommentLink: "https://example.com/first"}}
+	commentArea := func(bodyHeights map[string]float64) string {
+		_, layout, err := generateSVG(template, entries, bodyHeights)
+		if err != nil {
+			t.Fatalf("generateSVG failed: %v", err)
+		}
+		mapHTML, err := imageMapHTML(template, layout, "", "timeline.png")
+		if err != nil {
+			t.Fatalf("imageMapHTML failed: %v", err)
+		}
+		_, area, _ := strings.Cut(mapHTML, "<area ")
+		return area
+	}
+	if estimated, measured := commentArea(nil), commentArea(map[string]float64{"entry-2001": 400}); estimated == measured {
+		t.Errorf("Expected a measured body height to change the comment area, got %s both times", estimated)
+	}
+}
@@ -2,15 +2,120 @@
 package main
 
 import (
-	"encoding/json"
+	"bytes"
 	"flag"
 	"fmt"
 	"io"
 	"log" // Needed for rounding rect dimensions
+	"net/http"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 )
 
+const remoteInputTimeout = 30 * time.Second // Time limit for fetching an http(s) template or data file
+const maxRemoteInputSize = 10 << 20         // Largest accepted remote template or data file (10 MiB)
+
+// remoteInputClient fetches http(s) template and data inputs.
+var remoteInputClient = &http.Client{Timeout: remoteInputTimeout}
+
+// stringListFlag collects the values of a flag that may be given several times.
+type stringListFlag []string
+
+func (l *stringListFlag) String() string { return strings.Join(*l, ",") }
+
+func (l *stringListFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+// layoutFlagOverrides holds the layout values given on the command line; nil means the flag was not set.
+type layoutFlagOverrides struct {
+	ConnectorLength *float64
+	EntrySpacing    *float64
+	Padding         *float64
+	ShapeRendering  *string
+	TextRendering   *string
+	Seed            *int64
+	DebugBoxes      bool // -debug-boxes
+}
+
+// applyLayoutFlagOverrides replaces template layout values with the ones set by flags.
+// Flags take precedence over the template (and the theme it is based on).
+func applyLayoutFlagOverrides(template Template, overrides layoutFlagOverrides) Template {
+	if overrides.ConnectorLength != nil {
+		template.Layout.ConnectorLength = *overrides.ConnectorLength
+	}
+	if overrides.EntrySpacing != nil {
+		template.Layout.EntrySpacing = *overrides.EntrySpacing
+	}
+	if overrides.Padding != nil {
+		// -padding sets every side, so the template's per-side padding mustn't win over it. The
+		// per-side fields carry it because they keep 0, where layout.padding 0 means the default.
+		padding := *overrides.Padding
+		template.Layout.Padding = padding
+		template.Layout.PaddingString = ""
+		template.Layout.PaddingTop, template.Layout.PaddingRight, template.Layout.PaddingBottom, template.Layout.PaddingLeft = &padding, &padding, &padding, &padding
+	}
+	if overrides.ShapeRendering != nil {
+		template.Layout.ShapeRendering = *overrides.ShapeRendering
+	}
+	if overrides.TextRendering != nil {
+		template.Layout.TextRendering = *overrides.TextRendering
+	}
+	if overrides.Seed != nil {
+		template.Layout.Seed = *overrides.Seed
+	}
+	if overrides.DebugBoxes {
+		template.Layout.debugBoxes = true
+	}
+	return template
+}
+
+// parseRenderHints parses the -render-hints value, a comma separated list such as
+// "crispEdges,optimizeLegibility" or "shape=crispEdges,text=geometricPrecision". A bare
+// value applies to every hint that allows it (geometricPrecision to both).
+func parseRenderHints(arg string) (layoutFlagOverrides, error) {
+	var overrides layoutFlagOverrides
+	for _, item := range strings.Split(arg, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		attributes := []string{"shape-rendering", "text-rendering"}
+		value := item
+		if name, v, found := strings.Cut(item, "="); found {
+			value = v
+			switch name {
+			case "shape":
+				attributes = []string{"shape-rendering"}
+			case "text":
+				attributes = []string{"text-rendering"}
+			default:
+				return overrides, fmt.Errorf("unknown render hint '%s' (use shape= or text=)", name)
+			}
+		}
+		matched := false
+		for _, attribute := range attributes {
+			if !slices.Contains(svgRenderingHints[attribute], value) {
+				continue
+			}
+			matched = true
+			if attribute == "shape-rendering" {
+				overrides.ShapeRendering = &value
+			} else {
+				overrides.TextRendering = &value
+			}
+		}
+		if !matched {
+			return overrides, fmt.Errorf("'%s' is not a valid %s value", value, strings.Join(attributes, " or "))
+		}
+	}
+	return overrides, nil
+}
+
 // --- Main Program Logic ---
 
 func main() { // NOSONAR
@@ -19,69 +124,210 @@ func main() { // NOSONAR
 
 	// --- Argument Parsing using flag package ---
 	outputFile := flag.String("o", "", "Output file path (default: stdout)")
+	themeName := flag.String("theme", "", "Built-in theme used as the base template ("+strings.Join(availableThemes(), ", ")+"); the template file overrides it")
+	asDataURI := flag.Bool("datauri", false, "Write the output as a base64 data URI (e.g. data:image/svg+xml;base64,...) instead of raw content")
+	presetName := flag.String("preset", "", "Image size preset for png/jpg output ("+strings.Join(availablePresets(), ", ")+")")
+	var filterTags stringListFlag
+	flag.Var(&filterTags, "filter-tag", "Only render entries with this tag (repeatable; an entry matching any tag is kept)")
+	entryIndex := flag.Int("entry", 0, "Render only this entry (1-based, after -filter-tag), cropped tightly for callouts")
+	entryContext := flag.Int("entry-context", 0, "With -entry, also render this many neighboring entries on each side")
+	checkImages := flag.Bool("check-images", false, "Before rendering, fail with the list of local comment/thumbnail images that don't exist")
+	checkFonts := flag.Bool("check-fonts", false, "Warn about template fonts that are not installed (image output would silently fall back)")
+	minify := flag.Bool("minify", false, "Minify SVG output (drop whitespace between tags, trim trailing zeros)")
+	connectorLength := flag.Float64("connector-length", 0, "Override layout.connector_length from the template")
+	entrySpacing := flag.Float64("entry-spacing", 0, "Override layout.entry_spacing from the template")
+	padding := flag.Float64("padding", 0, "Override layout.padding from the template (0 for no padding)")
+	seed := flag.Int64("seed", 0, "Override layout.seed, the seed of the hand-drawn layout.jitter (same seed, same drawing)")
+	stackGap := flag.Float64("stack-gap", defaultStackGap, "Vertical space between stacked timelines (several <template> <data> pairs)")
+	exportData := flag.String("export-data", "", "Write the parsed data file back out as normalized json or csv instead of rendering (takes only <data.json>)")
+	imageMap := flag.Bool("imagemap", false, "With png/jpg output to a file, also write an HTML image map (<name>.map.html) with clickable areas for linked entries")
+	renderHints := flag.String("render-hints", "", "SVG rendering hints on the root element, e.g. crispEdges,optimizeLegibility or shape=geometricPrecision (overrides layout.shape_rendering/text_rendering)")
+	debugBoxesFlag := flag.Bool("debug-boxes", false, "Overlay the bounding boxes of years, comment blocks and the content area on the SVG, to diagnose placement")
+	debug := flag.Bool("debug", false, "Log layout details (effective styles, element centers, bounds and canvas offsets) for troubleshooting placement")
+	diagnosticsPath := flag.String("diagnostics-json", "", "Also write every warning as JSON ({\"warnings\": [{\"code\", \"message\"}]}) to this file")
+	embedFonts := flag.String("embed-fonts", "", "Comma separated font files or directories ([Family=]path) embedded into png/jpg output, so Chrome doesn't need them installed")
+	selfTest := flag.Bool("selftest", false, "Render a built-in sample timeline as svg and png into a temporary directory and report whether each works (checks the Chrome setup)")
 	// Add other flags here if needed in the future
 	flag.Parse() // Parse the flags provided
+	debugLogging = *debug
+
+	// Only flags actually given on the command line override the template
+	layoutOverrides := layoutFlagOverrides{DebugBoxes: *debugBoxesFlag}
+	flag.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "connector-length":
+			layoutOverrides.ConnectorLength = connectorLength
+		case "entry-spacing":
+			layoutOverrides.EntrySpacing = entrySpacing
+		case "padding":
+			layoutOverrides.Padding = padding
+		case "seed":
+			layoutOverrides.Seed = seed
+		}
+	})
+	if *renderHints != "" {
+		hints, errHints := parseRenderHints(*renderHints)
+		if errHints != nil {
+			log.Fatalf("Invalid -render-hints: %v", errHints)
+		}
+		layoutOverrides.ShapeRendering, layoutOverrides.TextRendering = hints.ShapeRendering, hints.TextRendering
+	}
+
+	// --- Self-test mode: no arguments, renders the built-in sample ---
+	if *selfTest {
+		selfTestDir, errDir := os.MkdirTemp("", "timeline-selftest-")
+		if errDir != nil {
+			log.Fatalf("Error creating the self-test directory: %v", errDir)
+		}
+		log.Printf("Running self-test in: %s", selfTestDir)
+		if errSelfTest := runSelfTest(selfTestDir, selfTestFormats, os.Stdout); errSelfTest != nil {
+			log.Fatalf("Self-test failed: %v", errSelfTest)
+		}
+		log.Println("Self-test passed.")
+		return
+	}
 
 	// Get positional arguments (template, data, format) after flags
 	args := flag.Args()
+
+	// --- Data export mode: normalize the data file, no template and no rendering ---
+	if *exportData != "" {
+		if len(args) != 1 {
+			log.Fatalf("-export-data takes only the data file: %s -export-data %s [-o out] <data.json>", os.Args[0], *exportData)
+		}
+		if err := exportDataFile(args[0], strings.ToLower(*exportData), *outputFile); err != nil {
+			log.Fatalf("Error exporting data: %v", err)
+		}
+		return
+	}
+	// --- Stacked timelines: several template/data pairs, svg only ---
+	if len(args) >= 5 && len(args)%2 == 1 {
+		stackArgs := stackedTimelineArgs{
+			Pairs:       args[:len(args)-1],
+			Format:      args[len(args)-1],
+			Theme:       *themeName,
+			Overrides:   layoutOverrides,
+			Gap:         *stackGap,
+			OutputPath:  *outputFile,
+			AsDataURI:   *asDataURI,
+			Minify:      *minify,
+			FilterTags:  filterTags,
+			CheckImages: *checkImages,
+			CheckFonts:  *checkFonts,
+			Diagnostics: &diagnosticLog{},
+		}
+		var imageFlags []string
+		if *presetName != "" {
+			imageFlags = append(imageFlags, "-preset")
+		}
+		if *embedFonts != "" {
+			imageFlags = append(imageFlags, "-embed-fonts")
+		}
+		if *imageMap {
+			imageFlags = append(imageFlags, "-imagemap")
+		}
+		if len(imageFlags) > 0 {
+			log.Fatalf("Stacked timelines are svg only, so the png/jpg flags can't be used: %s", strings.Join(imageFlags, ", "))
+		}
+		if *entryIndex != 0 || *entryContext != 0 {
+			stackArgs.Diagnostics.warnf("ignored_flag", "-entry and -entry-context pick an entry of a single timeline; ignoring them for stacked timelines")
+		}
+		err := runStackedTimelines(stackArgs)
+		saveDiagnostics(*diagnosticsPath, stackArgs.Diagnostics)
+		if err != nil {
+			log.Fatalf("Error generating stacked timelines: %v", err)
+		}
+		return
+	}
 	if len(args) != 3 {
 		// Improved usage message
-		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <template.json> <data.json> <format>\n", os.Args[0])
+		fmt.Fprintf(os.Stderr, "Usage: %s [flags] <template.json> <data.json> [<template2.json> <data2.json> ...] <format>\n", os.Args[0])
 		fmt.Fprintln(os.Stderr, "\nArguments:")
-		fmt.Fprintln(os.Stderr, "  <template.json>   Path to the template definition file.")
-		fmt.Fprintln(os.Stderr, "  <data.json>       Path to the timeline data file.")
-		fmt.Fprintln(os.Stderr, "  <format>          Output format (svg, html, png, jpg/jpeg).")
+		fmt.Fprintln(os.Stderr, "  <template.json>   Path or http(s) URL of the template definition file (- for stdin).")
+		fmt.Fprintln(os.Stderr, "  <data.json>       Path or http(s) URL of the timeline data file (- for stdin).")
+		fmt.Fprintln(os.Stderr, "  <format>          Output format (svg, html, svg-html, png, jpg/jpeg), or a comma separated list like svg,png (requires -o).")
 		fmt.Fprintln(os.Stderr, "\nFlags:")
 		flag.PrintDefaults() // Print default flag values and descriptions
 		os.Exit(1)           // Exit with error code
 	}
 	templateFile := args[0]
 	dataFile := args[1]
-	exportFormat := strings.ToLower(args[2])
+	exportFormats, err := parseFormats(args[2])
+	if err != nil {
+		log.Fatalf("%v", err)
+	}
+	if len(exportFormats) > 1 && *outputFile == "" {
+		log.Fatalf("Multiple output formats (%s) need an output path (-o); each format is written next to it with its own extension", args[2])
+	}
+	hasImageFormat, hasVectorFormat := false, false
+	for _, format := range exportFormats {
+		if format == "png" || format == "jpg" || format == "jpeg" {
+			hasImageFormat = true
+		} else {
+			hasVectorFormat = true
+		}
+	}
 
 	// --- File Reading & Parsing ---
-	log.Printf("Reading template file: %s", templateFile)
-	templateBytes, err := os.ReadFile(templateFile)
-	if err != nil {
-		log.Fatalf("Error reading template file '%s': %v", templateFile, err)
+	if templateFile == "-" && dataFile == "-" {
+		log.Fatalf("Only one of the template and data files can be read from stdin (-)")
+	}
+	var templateBytes []byte // nil selects the built-in default template
+	if templateFile != defaultTemplateArg {
+		log.Printf("Reading template file: %s", templateFile)
+		if templateBytes, err = readInput(templateFile); err != nil {
+			log.Fatalf("Error reading template file '%s': %v", templateFile, err)
+		}
+	} else {
+		log.Println("Using the built-in default template.")
 	}
 	log.Printf("Reading data file: %s", dataFile)
-	dataBytes, err := os.ReadFile(dataFile)
+	dataBytes, err := readInput(dataFile)
 	if err != nil {
 		log.Fatalf("Error reading data file '%s': %v", dataFile, err)
 	}
 
-	var template Template
 	log.Println("Parsing template JSON...")
-	err = json.Unmarshal(templateBytes, &template)
+	if *themeName != "" {
+		log.Printf("Using built-in theme: %s", *themeName)
+	}
+	template, err := loadTemplate(templateBytes, *themeName)
 	if err != nil {
 		log.Fatalf("Error parsing template JSON '%s': %v", templateFile, err)
 	}
+	template = applyLayoutFlagOverrides(template, layoutOverrides)
 
-	var timelineData TimelineData
 	log.Println("Parsing data JSON...")
-	// Attempt parsing as {"entries": [...]} first
-	err = json.Unmarshal(dataBytes, &timelineData)
+	diag := &diagnosticLog{} // Every warning of the run, for -diagnostics-json
+	timelineData, err := parseTimelineData(diag, dataBytes)
 	if err != nil {
-		// Fallback: Try parsing directly as an array [...]
-		log.Printf("Warning: Failed to parse data as root object ('%v'), attempting direct array parsing.", err)
-		var entriesDirect []TimelineEntry
-		errDirect := json.Unmarshal(dataBytes, &entriesDirect)
-		if errDirect != nil {
-			// Report the *original* error, as it's more likely the intended format failed
-			log.Fatalf("Error parsing data JSON '%s': %v (also failed direct array parse: %v)", dataFile, err, errDirect)
-		}
-		timelineData.Entries = entriesDirect
-		log.Println("Successfully parsed data JSON as a direct array.")
-	} else {
-		log.Println("Successfully parsed data JSON with 'entries' root key.")
+		log.Fatalf("Error parsing data JSON '%s': %v", dataFile, err)
+	}
+
+	// @file: comments are relative to a local data file; remote and stdin data can't use them
+	timelineData.Entries, err = resolveDataFiles(timelineData.Entries, dataFile)
+	if err != nil {
+		log.Fatalf("Data error: %v", err)
 	}
 
 	// --- Input Validation ---
 	log.Println("Validating inputs...")
-	supportedFormats := map[string]bool{"html": true, "svg": true, "png": true, "jpg": true, "jpeg": true}
-	if !supportedFormats[exportFormat] {
-		log.Fatalf("Unsupported export format '%s'. Supported formats: html, svg, png, jpg/jpeg", exportFormat)
+	if *presetName != "" {
+		if _, _, errPreset := applyImagePreset(template, *presetName); errPreset != nil {
+			log.Fatalf("Invalid -preset: %v", errPreset)
+		}
+		if !hasImageFormat {
+			diag.warnf("ignored_flag", "-preset only applies to image formats; ignoring it for %s", args[2])
+		} else if hasVectorFormat {
+			diag.warnf("ignored_flag", "-preset only applies to image formats; the svg/html output keeps its natural size")
+		}
+	}
+	if *imageMap && (!hasImageFormat || *outputFile == "") {
+		diag.warnf("ignored_flag", "-imagemap needs png/jpg output written to a file (-o); ignoring it")
+		*imageMap = false
+	}
+	if *minify && !slices.Contains(exportFormats, "svg") {
+		diag.warnf("ignored_flag", "-minify only applies to svg output; ignoring it for %s", args[2])
 	}
 	if template.CenterLine.Orientation != "horizontal" && template.CenterLine.Orientation != "vertical" {
 		log.Fatalf("Template error: center_line.orientation must be 'horizontal' or 'vertical'")
@@ -89,87 +335,417 @@ func main() { // NOSONAR
 	if len(timelineData.Entries) == 0 {
 		log.Fatalf("Data error: No timeline entries found in '%s'", dataFile)
 	}
+	if len(filterTags) > 0 {
+		timelineData.Entries, err = filterEntriesByTags(timelineData.Entries, filterTags)
+		if err != nil {
+			log.Fatalf("Filter error: %v", err)
+		}
+		log.Printf("Kept %d entries matching tags: %s", len(timelineData.Entries), filterTags.String())
+	}
+	if *entryIndex != 0 {
+		timelineData.Entries, template.Layout.entryOffset, err = selectEntry(timelineData.Entries, *entryIndex, *entryContext)
+		if err != nil {
+			log.Fatalf("Invalid -entry: %v", err)
+		}
+		if layoutOverrides.Padding == nil {
+			// A callout is cropped tightly unless -padding says otherwise
+			calloutPadding := entryCalloutPadding
+			template = applyLayoutFlagOverrides(template, layoutFlagOverrides{Padding: &calloutPadding})
+		}
+		log.Printf("Rendering entry %d with %d entries of context", *entryIndex, len(timelineData.Entries)-1)
+	} else if *entryContext != 0 {
+		diag.warnf("ignored_flag", "-entry-context only applies together with -entry; ignoring it")
+	}
+	if *checkImages {
+		if errImages := ValidateData(timelineData.Entries); errImages != nil {
+			log.Fatalf("Data error: %v", errImages)
+		}
+		log.Println("All local images exist.")
+	}
 	log.Println("Inputs validated successfully.")
 
-	// --- Determine Output Writer ---
-	var outputWriter io.Writer = os.Stdout // Default to standard output
-	var outFile *os.File = nil             // Keep track of the file if opened
+	if *checkFonts {
+		checkTemplateFonts(diag, template, timelineData.Entries)
+	}
 
-	if *outputFile != "" {
-		log.Printf("Output directed to file: %s", *outputFile)
-		outFile, err = os.Create(*outputFile)
-		if err != nil {
-			log.Fatalf("Error creating output file '%s': %v", *outputFile, err)
+	var fontCSS string
+	if *embedFonts != "" {
+		if !hasImageFormat {
+			diag.warnf("ignored_flag", "-embed-fonts only applies to image formats; ignoring it for %s", args[2])
+		} else {
+			fontCSS, err = fontFaceCSS(strings.Split(*embedFonts, ","))
+			if err != nil {
+				log.Fatalf("Invalid -embed-fonts: %v", err)
+			}
 		}
-		// Use defer to ensure file is closed, even on panic (though we handle errors before)
-		defer func() {
-			if outFile != nil {
-				log.Printf("Closing output file: %s", *outputFile)
-				closeErr := outFile.Close()
-				if closeErr != nil {
-					// Log error but don't override fatal errors from generation
-					log.Printf("Error closing output file '%s': %v", *outputFile, closeErr)
+	}
+
+	// --- Generation ---
+	// The SVG is built once and shared by the svg output and, without a preset, the image formats
+	var svgContent string
+	var svgLayout timelineLayout
+	svgReady := false
+	buildSVG := func() (string, timelineLayout, error) {
+		if !svgReady {
+			content, layout, errSvg := generateSVG(template, timelineData.Entries, nil)
+			if errSvg != nil {
+				return "", timelineLayout{}, errSvg
+			}
+			svgContent, svgLayout, svgReady = content, layout, true
+		}
+		return svgContent, svgLayout, nil
+	}
+
+	for _, exportFormat := range exportFormats {
+		outputPath := *outputFile
+		if len(exportFormats) > 1 {
+			outputPath = outputPathForFormat(*outputFile, exportFormat)
+		}
+		log.Printf("Generating output for format: %s", exportFormat)
+		var renderedLayout timelineLayout // The image map needs the layout the image was rendered from
+		genErr := writeOutput(outputPath, exportFormat, *asDataURI, func(outputWriter io.Writer) error {
+			layout, errRender := renderFormat(exportFormat, template, timelineData.Entries, renderOptions{
+				PresetName: *presetName,
+				Minify:     *minify,
+				BuildSVG:   buildSVG,
+				FontCSS:    fontCSS,
+			}, outputWriter)
+			diag.add(layout.warnings)
+			renderedLayout = layout
+			return errRender
+		})
+
+		// --- Handle Generation Errors ---
+		if genErr != nil {
+			if outputPath != "" {
+				// Don't leave a partial or empty file behind
+				if removeErr := os.Remove(outputPath); removeErr != nil && !os.IsNotExist(removeErr) {
+					diag.warnf("output_cleanup", "Could not remove output file '%s' after error: %v", outputPath, removeErr)
 				}
 			}
-		}()
-		outputWriter = outFile // Set writer to the file
+			saveDiagnostics(*diagnosticsPath, diag) // Automation still gets the warnings that led up to the failure
+			log.Fatalf("Error generating %s: %v", exportFormat, genErr)
+		}
+		log.Printf("Successfully generated %s output.", strings.ToUpper(exportFormat))
+		if outputPath != "" {
+			log.Printf("Output saved to: %s", outputPath)
+		}
+		if *imageMap && (exportFormat == "png" || exportFormat == "jpg" || exportFormat == "jpeg") {
+			mapPath, errMap := writeImageMap(template, renderedLayout, *presetName, outputPath)
+			if errMap != nil {
+				saveDiagnostics(*diagnosticsPath, diag)
+				log.Fatalf("Error generating image map: %v", errMap)
+			}
+			log.Printf("Image map saved to: %s", mapPath)
+			*imageMap = false // png and jpg share the same map file
+		}
+	}
+	saveDiagnostics(*diagnosticsPath, diag)
+}
+
+// stackedTimelineArgs carries the command line of a stacked timelines run.
+type stackedTimelineArgs struct {
+	Pairs       []string // template, data, template, data, ...
+	Format      string
+	Theme       string
+	Overrides   layoutFlagOverrides
+	Gap         float64
+	OutputPath  string
+	AsDataURI   bool
+	Minify      bool
+	FilterTags  []string       // -filter-tag, applied to every data file
+	CheckImages bool           // -check-images, checked for every data file
+	CheckFonts  bool           // -check-fonts, checked for every timeline
+	Diagnostics *diagnosticLog // Collects the warnings of every timeline, for -diagnostics-json
+}
+
+// checkTemplateFonts warns about every font of the template and entries that is not
+// installed (-check-fonts).
+func checkTemplateFonts(diag *diagnosticLog, template Template, entries []TimelineEntry) {
+	log.Println("Checking template fonts...")
+	missing, errFonts := MissingFonts(template, entries)
+	if errFonts != nil {
+		diag.warnf("font_check_failed", "%v", errFonts)
+	} else if len(missing) > 0 {
+		for _, family := range missing {
+			diag.warnf("missing_font", "font '%s' is not installed; PNG/JPG output will fall back to another font", family)
+		}
 	} else {
-		log.Println("Output directed to stdout.")
+		log.Println("All template fonts are installed.")
 	}
+}
 
-	// --- Generation ---
-	log.Printf("Generating output for format: %s", exportFormat)
-	var genErr error
+// saveDiagnostics writes the warnings to path (-diagnostics-json), if one was given.
+func saveDiagnostics(path string, diag *diagnosticLog) {
+	if path == "" {
+		return
+	}
+	if errDiag := writeDiagnosticsJSON(path, diag.warnings); errDiag != nil {
+		log.Printf("Error: %v", errDiag)
+	} else {
+		log.Printf("Diagnostics (%d warnings) saved to: %s", len(diag.warnings), path)
+	}
+}
+
+// runStackedTimelines loads every template/data pair and writes them stacked in one SVG
+// (GenerateMultiSVG). The theme and layout flags apply to every template, the tag filter and
+// image and font checks to every data file. Warnings go to args.Diagnostics.
+func runStackedTimelines(args stackedTimelineArgs) error {
+	if args.Format != "svg" {
+		return fmt.Errorf("stacked timelines only support svg output, not '%s'", args.Format)
+	}
+	var timelines []TimelineSpec
+	for i := 0; i+1 < len(args.Pairs); i += 2 {
+		templateFile, dataFile := args.Pairs[i], args.Pairs[i+1]
+		log.Printf("Reading timeline %d: %s, %s", i/2+1, templateFile, dataFile)
+		var templateBytes []byte // nil selects the built-in default template
+		if templateFile != defaultTemplateArg {
+			var err error
+			if templateBytes, err = readInput(templateFile); err != nil {
+				return fmt.Errorf("reading template file '%s': %w", templateFile, err)
+			}
+		}
+		dataBytes, err := readInput(dataFile)
+		if err != nil {
+			return fmt.Errorf("reading data file '%s': %w", dataFile, err)
+		}
+		template, err := loadTemplate(templateBytes, args.Theme)
+		if err != nil {
+			return fmt.Errorf("parsing template JSON '%s': %w", templateFile, err)
+		}
+		timelineData, err := parseTimelineData(args.Diagnostics, dataBytes)
+		if err != nil {
+			return fmt.Errorf("parsing data JSON '%s': %w", dataFile, err)
+		}
+		if timelineData.Entries, err = resolveDataFiles(timelineData.Entries, dataFile); err != nil {
+			return fmt.Errorf("data file '%s': %w", dataFile, err)
+		}
+		if len(args.FilterTags) > 0 {
+			if timelineData.Entries, err = filterEntriesByTags(timelineData.Entries, args.FilterTags); err != nil {
+				return fmt.Errorf("data file '%s': %w", dataFile, err)
+			}
+		}
+		if args.CheckImages {
+			if err := ValidateData(timelineData.Entries); err != nil {
+				return fmt.Errorf("data file '%s': %w", dataFile, err)
+			}
+		}
+		template = applyLayoutFlagOverrides(template, args.Overrides)
+		if template.CenterLine.Orientation != "horizontal" && template.CenterLine.Orientation != "vertical" {
+			return fmt.Errorf("template '%s': center_line.orientation must be 'horizontal' or 'vertical'", templateFile)
+		}
+		if args.CheckFonts {
+			checkTemplateFonts(args.Diagnostics, template, timelineData.Entries)
+		}
+		timelines = append(timelines, TimelineSpec{Template: template, Entries: timelineData.Entries})
+	}
+	svgContent, warnings, err := generateMultiSVG(timelines, args.Gap)
+	args.Diagnostics.add(warnings)
+	if err != nil {
+		return err
+	}
+	if args.Minify {
+		svgContent = minifySVG(svgContent)
+	}
+	return writeOutput(args.OutputPath, args.Format, args.AsDataURI, func(w io.Writer) error {
+		_, errWrite := io.WriteString(w, svgContent)
+		return errWrite
+	})
+}
 
-	switch exportFormat {
+// exportDataFile parses the data file at source and writes it in format (json or csv) to
+// outputPath, or stdout when it is empty.
+func exportDataFile(source, format, outputPath string) error {
+	if !slices.Contains(dataExportFormats, format) {
+		return fmt.Errorf("unsupported format '%s' (use %s)", format, strings.Join(dataExportFormats, " or "))
+	}
+	dataBytes, err := readInput(source)
+	if err != nil {
+		return fmt.Errorf("reading data file '%s': %w", source, err)
+	}
+	timelineData, err := parseTimelineData(nil, dataBytes)
+	if err != nil {
+		return fmt.Errorf("parsing data JSON '%s': %w", source, err)
+	}
+	if err := writeOutput(outputPath, format, false, func(w io.Writer) error {
+		return exportTimelineData(nil, timelineData.Entries, format, w)
+	}); err != nil {
+		return err
+	}
+	log.Printf("Exported %d entries as %s.", len(timelineData.Entries), format)
+	return nil
+}
+
+// renderOptions carries the CLI settings that affect how a single format is rendered.
+type renderOptions struct {
+	PresetName string                                 // Image size preset (png/jpg only)
+	Minify     bool                                   // Minify svg output
+	BuildSVG   func() (string, timelineLayout, error) // Returns the (shared) timeline SVG and its layout
+	FontCSS    string                                 // @font-face rules embedded into image output only
+}
+
+// renderFormat writes the timeline in one output format to outputWriter and returns the
+// layout of the SVG the output was made from (for html, which has none, only its warnings).
+func renderFormat(format string, template Template, entries []TimelineEntry, opts renderOptions, outputWriter io.Writer) (timelineLayout, error) {
+	switch format {
 	case "svg":
-		svgContent, errSvg := GenerateSVG(template, timelineData.Entries)
+		svgContent, layout, errSvg := opts.BuildSVG()
 		if errSvg != nil {
-			genErr = fmt.Errorf("SVG generation failed: %w", errSvg)
-		} else {
-			_, genErr = io.WriteString(outputWriter, svgContent) // Write string directly
-			if genErr != nil {
-				genErr = fmt.Errorf("failed to write SVG output: %w", genErr)
-			}
+			return timelineLayout{}, errSvg
 		}
+		if opts.Minify {
+			svgContent = minifySVG(svgContent)
+		}
+		if _, err := io.WriteString(outputWriter, svgContent); err != nil { // Write string directly
+			return layout, fmt.Errorf("failed to write SVG output: %w", err)
+		}
+		return layout, nil
+	case "svg-html":
+		svgContent, layout, errSvg := opts.BuildSVG()
+		if errSvg != nil {
+			return timelineLayout{}, errSvg
+		}
+		if _, err := io.WriteString(outputWriter, svgPageHTML(template, svgContent)); err != nil {
+			return layout, fmt.Errorf("failed to write SVG HTML page: %w", err)
+		}
+		return layout, nil
 	case "html":
-		outputString, errHtml := generateHTML(template, timelineData.Entries)
+		outputString, warnings, errHtml := generateHTML(template, entries)
 		if errHtml != nil {
-			genErr = fmt.Errorf("HTML generation failed: %w", errHtml)
-		} else {
-			_, genErr = io.WriteString(outputWriter, outputString) // Write string directly
-			if genErr != nil {
-				genErr = fmt.Errorf("failed to write HTML output: %w", genErr)
-			}
+			return timelineLayout{warnings: warnings}, errHtml
+		}
+		if _, err := io.WriteString(outputWriter, outputString); err != nil { // Write string directly
+			return timelineLayout{warnings: warnings}, fmt.Errorf("failed to write HTML output: %w", err)
 		}
+		return timelineLayout{warnings: warnings}, nil
 	case "png", "jpg", "jpeg":
-		// Call the image generation function, passing the determined writer
-		genErr = generateImage(template, timelineData.Entries, exportFormat, outputWriter)
-		// Error wrapping happens within generateImage if needed
-	}
-
-	// --- Handle Generation Errors ---
-	if genErr != nil {
-		log.Fatalf("Error generating %s: %v", exportFormat, genErr)
-		// Note: Defer will close the file if it was opened.
-		// We could attempt to remove the potentially partial file here, but defer handles closure.
-		// If writing failed mid-stream, the file might be partial.
-		// If generation failed *before* writing, the file might be empty or non-existent.
-		if outFile != nil && *outputFile != "" {
-			// Attempt cleanup if error occurred and we were writing to a file
-			log.Printf("Attempting to remove potentially incomplete file: %s", *outputFile)
-			// Ensure file is closed *before* removing (defer will handle this, but being explicit can help reasoning)
-			// outFile.Close() // Defer handles this
-			removeErr := os.Remove(*outputFile)
-			if removeErr != nil {
-				log.Printf("Warning: Could not remove output file '%s' after error: %v", *outputFile, removeErr)
+		if opts.PresetName != "" || template.Layout.AnimateConnectors {
+			// Presets change the canvas and the shared SVG animates its connectors, so the image needs its own SVG
+			return generateImage(template, entries, format, opts.PresetName, opts.FontCSS, outputWriter)
+		}
+		if err := checkImageTransparency(template, format); err != nil {
+			return timelineLayout{}, err
+		}
+		svgContent, layout, errSvg := opts.BuildSVG()
+		if errSvg != nil {
+			return timelineLayout{}, errSvg
+		}
+		if template.Layout.PreciseImageLayout {
+			// The shared SVG keeps the estimates; only the image gets the measured layout
+			preciseSVG, preciseLayout, errPrecise := preciseImageSVG(template, entries, svgContent, opts.FontCSS, format)
+			if errPrecise != nil {
+				return layout, errPrecise
 			}
+			svgContent, layout = preciseSVG, preciseLayout
+		}
+		return layout, renderSVGToImage(embedFontFaces(svgContent, opts.FontCSS), format, 1, outputWriter)
+	default:
+		return timelineLayout{}, &GenerationError{Format: format, Err: ErrUnsupportedFormat}
+	}
+}
+
+// isRemoteInput reports whether source is an http(s) URL rather than a local path.
+func isRemoteInput(source string) bool {
+	lower := strings.ToLower(source)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// readInput returns the content of a template or data source: a local path, "-" for stdin,
+// or an http(s) URL fetched with a timeout. Remote responses must succeed, look like JSON or
+// text, and stay below maxRemoteInputSize.
+func readInput(source string) ([]byte, error) {
+	if source == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	if !isRemoteInput(source) {
+		return os.ReadFile(source)
+	}
+	resp, err := remoteInputClient.Get(source)
+	if err != nil {
+		return nil, fmt.Errorf("fetching '%s' failed: %w", source, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching '%s' failed: %s", source, resp.Status)
+	}
+	// Loose check: raw file hosts often serve JSON as text/plain or application/octet-stream
+	contentType := strings.ToLower(resp.Header.Get("Content-Type"))
+	if strings.HasPrefix(contentType, "text/html") || strings.HasPrefix(contentType, "image/") {
+		return nil, fmt.Errorf("'%s' returned %s, expected JSON", source, contentType)
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteInputSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("reading '%s' failed: %w", source, err)
+	}
+	if len(content) > maxRemoteInputSize {
+		return nil, fmt.Errorf("'%s' is larger than %d bytes", source, maxRemoteInputSize)
+	}
+	return content, nil
+}
+
+// writeOutput runs render against the output file (stdout when path is empty),
+// wrapping the result in a data URI when asDataURI is set.
+func writeOutput(path, format string, asDataURI bool, render func(io.Writer) error) error {
+	var outputWriter io.Writer = os.Stdout // Default to standard output
+	if path != "" {
+		log.Printf("Output directed to file: %s", path)
+		outFile, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("error creating output file '%s': %w", path, err)
 		}
-		// No need to os.Exit(1) here, log.Fatalf already does that.
+		defer func() {
+			log.Printf("Closing output file: %s", path)
+			if closeErr := outFile.Close(); closeErr != nil {
+				// Log error but don't override errors from generation
+				log.Printf("Error closing output file '%s': %v", path, closeErr)
+			}
+		}()
+		outputWriter = outFile
 	} else {
-		log.Printf("Successfully generated %s output.", strings.ToUpper(exportFormat))
-		if *outputFile != "" {
-			log.Printf("Output saved to: %s", *outputFile)
+		log.Println("Output directed to stdout.")
+	}
+
+	if !asDataURI {
+		return render(outputWriter)
+	}
+	// Buffer the raw output and encode it afterwards
+	var dataURIBuffer bytes.Buffer
+	if err := render(&dataURIBuffer); err != nil {
+		return err
+	}
+	dataURI, err := encodeDataURI(dataURIBuffer.Bytes(), format)
+	if err != nil {
+		return fmt.Errorf("data URI encoding failed: %w", err)
+	}
+	if _, err := io.WriteString(outputWriter, dataURI); err != nil {
+		return fmt.Errorf("failed to write data URI output: %w", err)
+	}
+	return nil
+}
+
+// parseFormats splits the <format> argument ("svg" or "svg,png") into validated, lowercase
+// formats. Duplicates are dropped; jpg and jpeg count as the same format.
+func parseFormats(arg string) ([]string, error) {
+	supportedFormats := map[string]bool{"html": true, "svg": true, "svg-html": true, "png": true, "jpg": true, "jpeg": true}
+	var formats []string
+	seen := make(map[string]bool)
+	for _, format := range strings.Split(arg, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if !supportedFormats[format] {
+			return nil, fmt.Errorf("%w '%s'. Supported formats: html, svg, svg-html, png, jpg/jpeg", ErrUnsupportedFormat, format)
+		}
+		key := ternary(format == "jpeg", "jpg", format)
+		if !seen[key] {
+			seen[key] = true
+			formats = append(formats, format)
 		}
 	}
+	return formats, nil
+}
+
+// outputPathForFormat swaps the extension of path for the format's, e.g. out.svg -> out.png.
+// svg-html pages get ".svg.html" so they don't clash with html output.
+func outputPathForFormat(path, format string) string {
+	extension := ternary(format == "svg-html", "svg.html", format)
+	return strings.TrimSuffix(path, filepath.Ext(path)) + "." + extension
 }
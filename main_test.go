@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -136,3 +139,193 @@ func findFirstDifference(s1, s2 string) diffResult {
 		GotContext:      s2[start:endS2],
 	}
 }
+
+// TestLayoutFlagOverridesReachLayoutConfig verifies command line layout flags win over the template.
+func TestLayoutFlagOverridesReachLayoutConfig(t *testing.T) {
+	template := loadTestTemplate(t)
+	connectorLength := 123.0
+	padding := 7.0
+
+	template = applyLayoutFlagOverrides(template, layoutFlagOverrides{ConnectorLength: &connectorLength, Padding: &padding})
+	config := initializeLayoutConfig(template)
+
+	if config.defaultConnectorLength != connectorLength {
+		t.Errorf("Expected connector length %.0f, got %.0f", connectorLength, config.defaultConnectorLength)
+	}
+	if config.layoutPadding != padding {
+		t.Errorf("Expected padding %.0f, got %.0f", padding, config.layoutPadding)
+	}
+	if config.defaultEntrySpacing != 260 {
+		t.Errorf("Expected the unset entry spacing to keep the template value 260, got %.0f", config.defaultEntrySpacing)
+	}
+
+	// -padding also wins over padding_string and the per-side padding of the template
+	sided := loadTestTemplate(t)
+	top := 80.0
+	sided.Layout.PaddingString = "10 20 30 40"
+	sided.Layout.PaddingTop = &top
+	config = initializeLayoutConfig(applyLayoutFlagOverrides(sided, layoutFlagOverrides{Padding: &padding}))
+	if config.paddingTop != padding || config.paddingRight != padding || config.paddingBottom != padding || config.paddingLeft != padding {
+		t.Errorf("Expected -padding %.0f on every side, got %.0f %.0f %.0f %.0f", padding, config.paddingTop, config.paddingRight, config.paddingBottom, config.paddingLeft)
+	}
+
+	// -padding 0 is honored, not replaced by the default padding
+	zero := 0.0
+	config = initializeLayoutConfig(applyLayoutFlagOverrides(loadTestTemplate(t), layoutFlagOverrides{Padding: &zero}))
+	if config.paddingTop != 0 || config.paddingRight != 0 || config.paddingBottom != 0 || config.paddingLeft != 0 {
+		t.Errorf("Expected -padding 0 on every side, got %.0f %.0f %.0f %.0f", config.paddingTop, config.paddingRight, config.paddingBottom, config.paddingLeft)
+	}
+}
+
+// TestParseFormatsAndOutputPaths verifies comma separated formats and their derived file names.
+func TestParseFormatsAndOutputPaths(t *testing.T) {
+	formats, err := parseFormats("svg, PNG,jpeg,jpg")
+	if err != nil {
+		t.Fatalf("parseFormats failed: %v", err)
+	}
+	if strings.Join(formats, ",") != "svg,png,jpeg" {
+		t.Errorf("Expected formats svg,png,jpeg, got %v", formats)
+	}
+	if _, err := parseFormats("svg,gif"); err == nil {
+		t.Errorf("Expected an error for an unsupported format")
+	}
+	if got := outputPathForFormat("out/timeline.svg", "png"); got != "out/timeline.png" {
+		t.Errorf("Expected out/timeline.png, got %s", got)
+	}
+	if got := outputPathForFormat("out/timeline.svg", "svg-html"); got != "out/timeline.svg.html" {
+		t.Errorf("Expected svg-html pages to get a .svg.html extension, got %s", got)
+	}
+	if got := outputPathForFormat("timeline", "svg"); got != "timeline.svg" {
+		t.Errorf("Expected timeline.svg, got %s", got)
+	}
+}
+
+// TestReadInputFetchesRemoteTemplate checks http(s) inputs are downloaded and failed or
+// non-JSON responses are rejected.
+func TestReadInputFetchesRemoteTemplate(t *testing.T) {
+	templateBytes, err := os.ReadFile(filepath.Join("testdata", "test1.tmpl.json"))
+	if err != nil {
+		t.Fatalf("Could not read test template: %v", err)
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/template.json":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write(templateBytes)
+		case "/login":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Write([]byte("<html></html>"))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	content, err := readInput(server.URL + "/template.json")
+	if err != nil {
+		t.Fatalf("readInput failed: %v", err)
+	}
+	var template Template
+	if err := json.Unmarshal(content, &template); err != nil || template.CenterLine.Orientation != "horizontal" {
+		t.Errorf("Expected the served template, got %+v (%v)", template.CenterLine, err)
+	}
+	if _, err := readInput(server.URL + "/missing.json"); err == nil || !strings.Contains(err.Error(), "404") {
+		t.Errorf("Expected a 404 error, got %v", err)
+	}
+	if _, err := readInput(server.URL + "/login"); err == nil {
+		t.Errorf("Expected an HTML response to be rejected")
+	}
+}
+
+// TestRenderHintsReachRootSVG checks -render-hints values end up on the root <svg> and
+// invalid values are rejected.
+func TestRenderHintsReachRootSVG(t *testing.T) {
+	hints, err := parseRenderHints("crispEdges,text=geometricPrecision")
+	if err != nil {
+		t.Fatalf("parseRenderHints failed: %v", err)
+	}
+	template := applyLayoutFlagOverrides(loadTestTemplate(t), hints)
+	svg, err := GenerateSVG(template, []TimelineEntry{{Period: "2001"}, {Period: "2002"}})
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	root := svg[:strings.Index(svg, ">")]
+	if !strings.Contains(root, `shape-rendering="crispEdges"`) || !strings.Contains(root, `text-rendering="geometricPrecision"`) {
+		t.Errorf("Expected both rendering hints on the root element, got %s", root)
+	}
+	if _, err := parseRenderHints("shape=optimizeLegibility"); err == nil {
+		t.Errorf("Expected optimizeLegibility to be rejected for shape-rendering")
+	}
+}
+
+// TestStackedTimelinesApplyEntryFlags checks -filter-tag and -check-images reach every data
+// file of a stacked run.
+func TestStackedTimelinesApplyEntryFlags(t *testing.T) {
+	dir := t.TempDir()
+	first := filepath.Join(dir, "first.json")
+	second := filepath.Join(dir, "second.json")
+	if err := os.WriteFile(first, []byte(`{"entries": [{"period": "2001", "tags": ["public"]}, {"period": "2002", "tags": ["internal"]}]}`), 0o644); err != nil {
+		t.Fatalf("Could not write data: %v", err)
+	}
+	if err := os.WriteFile(second, []byte(`{"entries": [{"period": "2010", "tags": ["public"]}, {"period": "2011"}]}`), 0o644); err != nil {
+		t.Fatalf("Could not write data: %v", err)
+	}
+	args := stackedTimelineArgs{
+		Pairs:      []string{defaultTemplateArg, first, defaultTemplateArg, second},
+		Format:     "svg",
+		OutputPath: filepath.Join(dir, "stacked.svg"),
+		FilterTags: []string{"public"},
+	}
+	if err := runStackedTimelines(args); err != nil {
+		t.Fatalf("runStackedTimelines failed: %v", err)
+	}
+	svg, err := os.ReadFile(args.OutputPath)
+	if err != nil {
+		t.Fatalf("Could not read output: %v", err)
+	}
+	for period, kept := range map[string]bool{"2001": true, "2002": false, "2010": true, "2011": false} {
+		if got := strings.Contains(string(svg), ">"+period+"<"); got != kept {
+			t.Errorf("Expected period %s kept: %t, got %t", period, kept, got)
+		}
+	}
+
+	if err := os.WriteFile(second, []byte(`{"entries": [{"period": "2010", "comment_image": "missing.png"}]}`), 0o644); err != nil {
+		t.Fatalf("Could not write data: %v", err)
+	}
+	args.FilterTags, args.CheckImages = nil, true
+	if err := runStackedTimelines(args); err == nil || !strings.Contains(err.Error(), "missing.png") {
+		t.Errorf("Expected -check-images to report the missing image of the second timeline, got %v", err)
+	}
+}
+
+// TestStackedTimelinesRecordWarnings checks a stacked run validates every template's
+// orientation and records its warnings for -diagnostics-json.
+func TestStackedTimelinesRecordWarnings(t *testing.T) {
+	dir := t.TempDir()
+	data := filepath.Join(dir, "data.json")
+	if err := os.WriteFile(data, []byte(`{"entries": [{"period": "2001"}]}`), 0o644); err != nil {
+		t.Fatalf("Could not write data: %v", err)
+	}
+	args := stackedTimelineArgs{
+		Pairs:       []string{defaultTemplateArg, data, defaultTemplateArg, data},
+		Format:      "svg",
+		Gap:         -5,
+		OutputPath:  filepath.Join(dir, "stacked.svg"),
+		Diagnostics: &diagnosticLog{},
+	}
+	if err := runStackedTimelines(args); err != nil {
+		t.Fatalf("runStackedTimelines failed: %v", err)
+	}
+	if !slices.ContainsFunc(args.Diagnostics.warnings, func(d Diagnostic) bool { return d.Code == "invalid_option" }) {
+		t.Errorf("Expected the negative gap warning to be recorded, got %v", args.Diagnostics.warnings)
+	}
+
+	tmpl := filepath.Join(dir, "diagonal.json")
+	if err := os.WriteFile(tmpl, []byte(`{"center_line": {"orientation": "diagonal"}}`), 0o644); err != nil {
+		t.Fatalf("Could not write template: %v", err)
+	}
+	args.Pairs = []string{defaultTemplateArg, data, tmpl, data}
+	if err := runStackedTimelines(args); err == nil || !strings.Contains(err.Error(), "orientation") {
+		t.Errorf("Expected the invalid orientation of the second template to be rejected, got %v", err)
+	}
+}
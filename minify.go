@@ -0,0 +1,100 @@
+// minify.go
+package main
+
+import (
+	"regexp"
+	"strings"
+)
+
+var (
+	svgTagRegex        = regexp.MustCompile(`<[^>]*>`)
+	svgAttributeRegex  = regexp.MustCompile(`([\w:-]+)="([^"]*)"`)
+	svgDecimalRegex    = regexp.MustCompile(`\d+\.\d+`)
+	svgEmptyStyleRegex = regexp.MustCompile(`<style>\s*</style>`)
+)
+
+// minifyPreservedElements keep their inner whitespace, which is rendered text there.
+var minifyPreservedElements = map[string]bool{"text": true, "foreignObject": true, "style": true}
+
+// minifyKeepAttributes hold URLs or names, so their numbers are left untouched.
+var minifyKeepAttributes = map[string]bool{"href": true, "xlink:href": true, "src": true, "id": true, "class": true, "alt": true, "xmlns": true, "xmlns:xlink": true}
+
+// minifySVG shrinks generated SVG without changing how it renders: whitespace-only
+// text between tags is dropped (except inside text, foreignObject and style elements),
+// trailing zeros are trimmed from decimal numbers in attributes and an empty <style> is removed.
+func minifySVG(svg string) string {
+	svg = svgEmptyStyleRegex.ReplaceAllString(svg, "")
+
+	var out strings.Builder
+	out.Grow(len(svg))
+	preserveDepth := 0 // Nesting depth inside elements whose whitespace matters
+	last := 0
+	for _, loc := range svgTagRegex.FindAllStringIndex(svg, -1) {
+		between := svg[last:loc[0]]
+		if preserveDepth > 0 || strings.TrimSpace(between) != "" {
+			out.WriteString(between)
+		}
+		tag := svg[loc[0]:loc[1]]
+		name := svgTagName(tag)
+		switch {
+		case strings.HasPrefix(tag, "</"):
+			if preserveDepth > 0 {
+				preserveDepth-- // Every element opened inside a preserved one is counted
+			}
+		case strings.HasPrefix(tag, "<!") || strings.HasPrefix(tag, "<?") || strings.HasSuffix(tag, "/>"):
+			// Comments, declarations and self-closing tags don't open an element
+		default:
+			if minifyPreservedElements[name] || preserveDepth > 0 {
+				preserveDepth++
+			}
+		}
+		if preserveDepth == 0 || minifyPreservedElements[name] {
+			tag = minifyTagAttributes(tag)
+		}
+		out.WriteString(tag)
+		last = loc[1]
+	}
+	if tail := svg[last:]; strings.TrimSpace(tail) != "" {
+		out.WriteString(tail)
+	}
+	return out.String()
+}
+
+// svgTagName returns the element name of a tag such as `<rect x="1"/>` or `</g>`.
+func svgTagName(tag string) string {
+	name := strings.TrimLeft(tag, "</")
+	if end := strings.IndexAny(name, " \t\n/>"); end >= 0 {
+		name = name[:end]
+	}
+	return name
+}
+
+// minifyTagAttributes trims trailing zeros of decimals in the tag's numeric attributes.
+func minifyTagAttributes(tag string) string {
+	return svgAttributeRegex.ReplaceAllStringFunc(tag, func(attr string) string {
+		parts := svgAttributeRegex.FindStringSubmatch(attr)
+		if minifyKeepAttributes[parts[1]] || strings.Contains(parts[2], "url(") {
+			return attr // url(#id) references must keep their exact id
+		}
+		return parts[1] + `="` + trimDecimalZeros(parts[2]) + `"`
+	})
+}
+
+// trimDecimalZeros rewrites numbers like "12.50" to "12.5" and "3.00" to "3".
+// Dotted sequences such as version numbers are left alone.
+func trimDecimalZeros(value string) string {
+	var out strings.Builder
+	last := 0
+	for _, loc := range svgDecimalRegex.FindAllStringIndex(value, -1) {
+		if (loc[0] > 0 && value[loc[0]-1] == '.') || (loc[1] < len(value) && value[loc[1]] == '.') {
+			continue
+		}
+		number := strings.TrimRight(value[loc[0]:loc[1]], "0")
+		number = strings.TrimSuffix(number, ".")
+		out.WriteString(value[last:loc[0]])
+		out.WriteString(number)
+		last = loc[1]
+	}
+	out.WriteString(value[last:])
+	return out.String()
+}
@@ -0,0 +1,46 @@
+package main
+
+import (
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+)
+
+// TestMinifySVGShrinksAndParses checks a known input shrinks, still parses and keeps text content intact.
+func TestMinifySVGShrinksAndParses(t *testing.T) {
+	input := `<svg width="200" height="100" xmlns="http://www.w3.org/2000/svg">
+  <style>
+  </style>
+<g transform="translate(20.00, 35.50)">
+  <line x1="0.00" y1="0.00" x2="120.25" y2="0.00" stroke="#000" stroke-width="2.00" />
+  <text x="10.00" y="5.00"><tspan x="10.00" y="5.00">Year  1</tspan> <tspan dy="12.00">v1.10.0</tspan></text>
+  <a xlink:href="https://example.com/v2.00/">
+    <rect x="1.50" y="2.00" width="3.00" height="4.00" />
+  </a>
+</g>
+</svg>`
+	want := `<svg width="200" height="100" xmlns="http://www.w3.org/2000/svg">` +
+		`<g transform="translate(20, 35.5)">` +
+		`<line x1="0" y1="0" x2="120.25" y2="0" stroke="#000" stroke-width="2" />` +
+		`<text x="10" y="5"><tspan x="10.00" y="5.00">Year  1</tspan> <tspan dy="12.00">v1.10.0</tspan></text>` +
+		`<a xlink:href="https://example.com/v2.00/"><rect x="1.5" y="2" width="3" height="4" /></a>` +
+		`</g></svg>`
+
+	got := minifySVG(input)
+	if got != want {
+		t.Errorf("minifySVG mismatch:\ngot:  %s\nwant: %s", got, want)
+	}
+	if len(got) >= len(input) {
+		t.Errorf("Expected minified output to be smaller (%d >= %d)", len(got), len(input))
+	}
+
+	decoder := xml.NewDecoder(strings.NewReader(got))
+	for {
+		if _, err := decoder.Token(); err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatalf("Minified SVG does not parse: %v", err)
+		}
+	}
+}
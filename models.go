@@ -4,27 +4,93 @@ package main
 
 // Added: Global layout configurations
 type LayoutOptions struct {
-	Padding         float64 `json:"padding"`          // Overall padding around the timeline content
-	EntrySpacing    float64 `json:"entry_spacing"`    // Default spacing between entry centers
-	ConnectorLength float64 `json:"connector_length"` // Default connector length
+	Padding              float64           `json:"padding"`                  // Overall padding around the timeline content
+	PaddingString        string            `json:"padding_string,omitempty"` // CSS-like per-side padding: "10", "10 20", "10 20 30" or "10 20 30 40" (top right bottom left)
+	PaddingTop           *float64          `json:"padding_top,omitempty"`    // Per-side padding, overriding padding and padding_string for that side
+	PaddingRight         *float64          `json:"padding_right,omitempty"`
+	PaddingBottom        *float64          `json:"padding_bottom,omitempty"`
+	PaddingLeft          *float64          `json:"padding_left,omitempty"`
+	EntrySpacing         float64           `json:"entry_spacing"`                    // Default spacing between entry centers
+	ConnectorLength      float64           `json:"connector_length"`                 // Default connector length
+	MinWidth             float64           `json:"min_width,omitempty"`              // Optional minimum canvas width; smaller timelines are centered in it
+	MinHeight            float64           `json:"min_height,omitempty"`             // Optional minimum canvas height
+	MaxWidth             float64           `json:"max_width,omitempty"`              // Optional maximum canvas width; larger content is scaled down to fit
+	MaxHeight            float64           `json:"max_height,omitempty"`             // Optional maximum canvas height
+	AxisLength           float64           `json:"axis_length,omitempty"`            // Optional total axis length; entries are spread evenly over it
+	WarnOverlap          bool              `json:"warn_overlap,omitempty"`           // Log a warning when comment boxes on the same side overlap
+	Transparent          bool              `json:"transparent,omitempty"`            // Omit the white background rect
+	StrokeScale          float64           `json:"stroke_scale,omitempty"`           // Multiplier for all stroke widths (default 1.0)
+	Precision            *int              `json:"precision,omitempty"`              // Decimals written for coordinates (default 2)
+	Title                string            `json:"title,omitempty"`                  // Page title of html and svg-html output
+	Caption              string            `json:"caption,omitempty"`                // Footer text, e.g. source attribution; supports [markdown](links)
+	CaptionFont          FontStyle         `json:"caption_font,omitempty"`           // Caption font (falls back to global_font)
+	ColorMap             map[string]string `json:"color_map,omitempty"`              // Replace colors at render time, e.g. {"#FFFFFF": "#121212"}
+	ImportanceScale      float64           `json:"importance_scale,omitempty"`       // Marker growth per importance point (default 0.25: importance 4 doubles the size)
+	ImportanceScalesYear bool              `json:"importance_scales_year,omitempty"` // Also scale the year font size by importance
+	CustomDefs           string            `json:"custom_defs,omitempty"`            // Raw SVG markup (gradients, filters...) inserted verbatim into <defs>
+	Interactive          bool              `json:"interactive,omitempty"`            // HTML only: add a search box that highlights matching entries
+	AccordionSections    bool              `json:"accordion_sections,omitempty"`     // HTML only: group entries by section into collapsible <details> blocks
+	BaselineCompat       bool              `json:"baseline_compat,omitempty"`        // Center year text without dominant-baseline (librsvg, Inkscape)
+	SpacingScale         string            `json:"spacing_scale,omitempty"`          // "linear" or "log": space entries by the time between their dates
+	FocusColor           string            `json:"focus_color,omitempty"`            // Color of the focus ring around entries with focus: true (default #FF9800)
+	NumberEntries        bool              `json:"number_entries,omitempty"`         // Draw the 1-based entry number as a badge on each junction marker
+	NumberStyle          NumberBadgeStyle  `json:"number_style,omitempty"`           // Look of the number badges
+	MinimalMode          bool              `json:"minimal_mode,omitempty"`           // Spine only: skip comment blocks and their connectors
+	FitComments          bool              `json:"fit_comments,omitempty"`           // Narrow comment blocks to the space beside their entry (crowded sides get narrower boxes)
+	NoForeignObject      bool              `json:"no_foreign_object,omitempty"`      // Comment bodies as wrapped <text> and <image>, for renderers without foreignObject
+	PreciseImageLayout   bool              `json:"precise_image_layout,omitempty"`   // PNG/JPG: measure comment bodies in Chrome and re-layout with their real heights
+	ShapeRendering       string            `json:"shape_rendering,omitempty"`        // SVG shape-rendering hint on the root element (e.g. "crispEdges")
+	TextRendering        string            `json:"text_rendering,omitempty"`         // SVG text-rendering hint on the root element (e.g. "optimizeLegibility")
+	Jitter               float64           `json:"jitter,omitempty"`                 // Hand-drawn look: move line ends and boxes by up to +-jitter px (0 = off)
+	Seed                 int64             `json:"seed,omitempty"`                   // Seed of the jitter, the same seed gives the same drawing
+	LineJoin             string            `json:"line_join,omitempty"`              // "miter" (default), "round" or "bevel" corners of orthogonal connectors and center line paths
+	AnimateConnectors    bool              `json:"animate_connectors,omitempty"`     // SVG/HTML display: connectors draw themselves in, one entry after the other
+	Bucket               string            `json:"bucket,omitempty"`                 // "none" (default), "year", "quarter", "month" or "week": group entries by the bucket of their date
+	BucketMerge          bool              `json:"bucket_merge,omitempty"`           // Merge the entries of a bucket into one instead of stacking their comments
+	ElementOrder         string            `json:"element_order,omitempty"`          // Draw order within an entry, e.g. "comment,year,marker" (default "marker,year,comment", later parts on top)
+
+	debugBoxes  bool // Overlay the bounding boxes of years, comment blocks and the content area (-debug-boxes)
+	entryOffset int  // Visible entries before the first one rendered (-entry), so sides still alternate and numbers count as in the full timeline
 	// Add other global layout defaults here if needed
 }
 
+// NumberBadgeStyle defines the numbered badges drawn with layout.number_entries
+type NumberBadgeStyle struct {
+	Font        FontStyle `json:"font,omitempty"`         // Number font (default: global font, size 10, bold)
+	TextColor   string    `json:"text_color,omitempty"`   // Number color (default #FFFFFF)
+	FillColor   string    `json:"fill_color,omitempty"`   // Badge background (default: the junction marker color)
+	BorderColor string    `json:"border_color,omitempty"` // Outline color (empty = no outline)
+	BorderWidth float64   `json:"border_width,omitempty"` // Outline width (0 = no outline)
+	Size        float64   `json:"size,omitempty"`         // Badge height, and width for single digits (default 18)
+}
+
 // JunctionMarkerStyle defines the marker between timeline segments
 type JunctionMarkerStyle struct {
 	Shape string  `json:"shape"` // "diamond", "arrow", "none"
 	Size  float64 `json:"size"`  // Size of the marker (e.g., width/height)
 	// Color is typically derived from the next segment, but can be overridden
-	Color *string `json:"color,omitempty"`
+	Color       *string `json:"color,omitempty"`
+	BorderColor string  `json:"border_color,omitempty"` // Outline color (empty = no outline)
+	BorderWidth float64 `json:"border_width,omitempty"` // Outline width (0 = no outline)
+}
+
+// DividerStyle defines the era divider drawn across the timeline with TimelineEntry.Divider
+type DividerStyle struct {
+	Color         string  `json:"color,omitempty"`          // Line color (default: the junction marker color)
+	Width         float64 `json:"width,omitempty"`          // Line width (default 1)
+	LineType      string  `json:"line_type,omitempty"`      // "dashed" (default), "dotted" or "solid"
+	Extent        float64 `json:"extent,omitempty"`         // Reach past the axis on each side (0 = cover the entry's comment and year)
+	ReplaceMarker bool    `json:"replace_marker,omitempty"` // Draw the divider instead of the junction marker
 }
 
 // TitleLineStyle defines the decorative line above comment titles
 type TitleLineStyle struct {
-	Visible bool    `json:"visible"` // Default false? Or based on width/length? Let's default true if width/length > 0
-	Color   string  `json:"color"`   // Defaults to segment/connector color
-	Width   float64 `json:"width"`   // Thickness
-	Length  float64 `json:"length"`  // Length
-	Margin  float64 `json:"margin"`  // Space below the line, above the title
+	Visible  bool    `json:"visible"`             // Default false? Or based on width/length? Let's default true if width/length > 0
+	Color    string  `json:"color"`               // Defaults to segment/connector color
+	Width    float64 `json:"width"`               // Thickness
+	Length   float64 `json:"length"`              // Length
+	Margin   float64 `json:"margin"`              // Space below the line, above the title
+	LineType string  `json:"line_type,omitempty"` // "solid" (default), "dotted", "dashed", "double"
 }
 
 // FontStyle defines common font properties
@@ -43,12 +109,24 @@ type Template struct {
 }
 
 type CenterLine struct {
-	Width       int      `json:"width"`
-	Type        string   `json:"type"`
-	Orientation string   `json:"orientation"`
-	Angle       *float64 `json:"angle,omitempty"` // Added: Optional angle in degrees
-	Color       string   `json:"color"`
-	RoundedCaps bool     `json:"rounded_caps"` // Added for rounded ends
+	Width       int           `json:"width"`
+	Type        string        `json:"type"`
+	Orientation string        `json:"orientation"`
+	Angle       *float64      `json:"angle,omitempty"` // Added: Optional angle in degrees
+	Color       string        `json:"color"`
+	RoundedCaps bool          `json:"rounded_caps"`          // Added for rounded ends
+	Smooth      bool          `json:"smooth,omitempty"`      // Draw the axis as a smooth curve through the entry points
+	DrawOnTop   bool          `json:"draw_on_top,omitempty"` // Draw the center line after (over) the entries
+	Gradient    *[]string     `json:"gradient,omitempty"`    // Stop colors faded along the whole axis, replacing the segment colors
+	StartCap    *AxisCapStyle `json:"start_cap,omitempty"`   // Origin marker at the start of the axis (nil = none)
+	EndCap      *AxisCapStyle `json:"end_cap,omitempty"`     // Marker at the end of the axis (nil = none)
+}
+
+// AxisCapStyle defines a marker drawn at one end of the center line
+type AxisCapStyle struct {
+	Shape string  `json:"shape"`           // "circle", "diamond", "arrow", "flag" or "none"
+	Size  float64 `json:"size,omitempty"`  // Marker size (default 12)
+	Color string  `json:"color,omitempty"` // Fill color (default: the center line color)
 }
 
 type PeriodStyle struct {
@@ -67,52 +145,71 @@ type YearTextStyle struct {
 	Font            FontStyle `json:"font,omitempty"`
 	Shape           string    `json:"shape,omitempty"`
 	FillColor       string    `json:"fill_color,omitempty"`
+	FillOpacity     *float64  `json:"fill_opacity,omitempty"` // Shape fill opacity 0-1 (nil = opaque)
 	BorderColor     string    `json:"border_color,omitempty"`
 	BorderWidth     float64   `json:"border_width,omitempty"`
+	LineHeight      *float64  `json:"line_height,omitempty"`   // Line spacing multiplier for multi-line periods (default 1.2)
+	Rotate          *float64  `json:"rotate,omitempty"`        // Rotation in degrees of the text and shape around the center (nil = upright)
+	SubtitleFont    FontStyle `json:"subtitle_font,omitempty"` // Font of the entry subtitle (default: year font, smaller and normal weight)
+	AutoFitText     bool      `json:"auto_fit_text,omitempty"` // Shrink the font until the period fits the width of a fixed-size shape
 }
 
 type ConnectorStyle struct {
-	DrawToPeriod  *bool    `json:"draw_to_period,omitempty"`
-	DrawToComment *bool    `json:"draw_to_comment,omitempty"`
-	Width         int      `json:"width,omitempty"`
-	Color         string   `json:"color,omitempty"`
-	LineType      string   `json:"line_type,omitempty"`
-	Side          string   `json:"side,omitempty"` // Added
-	Dot           DotStyle `json:"dot,omitempty"`
+	DrawToPeriod      *bool    `json:"draw_to_period,omitempty"`
+	DrawToComment     *bool    `json:"draw_to_comment,omitempty"`
+	Width             int      `json:"width,omitempty"`
+	Color             string   `json:"color,omitempty"`
+	LineType          string   `json:"line_type,omitempty"`
+	Side              string   `json:"side,omitempty"`    // Added
+	Routing           string   `json:"routing,omitempty"` // "direct" (default) or "orthogonal"
+	Dot               DotStyle `json:"dot,omitempty"`
+	GradientToElement bool     `json:"gradient_to_element,omitempty"` // Fade the line from the segment color to the element's color
 }
 
 type DotStyle struct {
-	Size        int    `json:"size"` // diameter
-	Color       string `json:"color"`
-	Shape       string `json:"shape"` // "circle", "arrow", "square", "none"
-	Visible     bool   `json:"visible"`
-	OffsetMain  int    `json:"offset_main"`  // Offset along the connector line
-	OffsetCross int    `json:"offset_cross"` // Offset perpendicular to the connector line
-	StopAtDot   bool   `json:"stop_at_dot"`  // Added: Control if line stops at dot
+	Size        int     `json:"size"` // diameter
+	Color       string  `json:"color"`
+	Shape       string  `json:"shape"` // "circle", "arrow", "square", "none"
+	Visible     bool    `json:"visible"`
+	OffsetMain  int     `json:"offset_main"`           // Offset along the connector line
+	OffsetCross int     `json:"offset_cross"`          // Offset perpendicular to the connector line
+	StopAtDot   bool    `json:"stop_at_dot"`           // Added: Control if line stops at dot
+	ElbowAt     string  `json:"elbow_at,omitempty"`    // Dogleg bend: "element" (default, leg from the element runs across the axis) or "axis"
+	ArrowRatio  float64 `json:"arrow_ratio,omitempty"` // Arrow tip length relative to half the dot size (default 1.2)
 }
 
 type CommentTextStyle struct {
-	Position        string         `json:"position"`
+	Position        string         `json:"position"`                   // "inline" centers the block on the axis point
 	MainAxisOffset  float64        `json:"main_axis_offset,omitempty"` // Added back
 	CrossAxisOffset float64        `json:"cross_axis_offset,omitempty"`
-	Font            FontStyle      `json:"font"`        // Font for the body text
-	TitleFont       FontStyle      `json:"title_font"`  // Added: Specific font style for the title
-	TitleLine       TitleLineStyle `json:"title_line"`  // Added: Decorative line above title
-	TitleColor      string         `json:"title_color"` // Added: Specific color for the title text
-	Shape           string         `json:"shape"`       // "rectangle", "none" - determines background/border for body
+	Font            FontStyle      `json:"font"`                     // Font for the body text
+	TitleFont       FontStyle      `json:"title_font"`               // Added: Specific font style for the title
+	TitleLine       TitleLineStyle `json:"title_line"`               // Added: Decorative line above title
+	TitleColor      string         `json:"title_color"`              // Added: Specific color for the title text
+	TitlePosition   string         `json:"title_position,omitempty"` // "above" (default) or "below" the body
+	Shape           string         `json:"shape"`                    // "rectangle", "none" - determines background/border for body
 	FillColor       string         `json:"fill_color"`
-	TextColor       string         `json:"text_color"`            // Color for the body text
-	Padding         string         `json:"padding"`               // Changed: Padding string (e.g., "10", "10 20", "10 20 30 40")
-	BlockWidth      *float64       `json:"block_width,omitempty"` // Added: Optional fixed width
+	FillOpacity     *float64       `json:"fill_opacity,omitempty"`    // Background opacity 0-1 (nil = opaque, attribute omitted)
+	TextColor       string         `json:"text_color"`                // Color for the body text
+	Padding         string         `json:"padding"`                   // Changed: Padding string (e.g., "10", "10 20", "10 20 30 40")
+	BlockWidth      *float64       `json:"block_width,omitempty"`     // Added: Optional fixed width
+	MaxBlockWidth   *float64       `json:"max_block_width,omitempty"` // Shrink to the content width but wrap the body at this width
+	BlockHeight     *float64       `json:"block_height,omitempty"`    // Optional fixed height; overflowing body content is clipped
 	BorderColor     string         `json:"border_color"`
 	BorderWidth     int            `json:"border_width"`
 	BorderStyle     string         `json:"border_style"`
-	TextAlign       string         `json:"text_align"` // Added: Alignment for text within comment block ('left', 'center', 'right')
+	TextAlign       string         `json:"text_align"`               // Added: Alignment for text within comment block ('left', 'center', 'right', 'auto')
+	LineHeight      *float64       `json:"line_height,omitempty"`    // Line spacing multiplier for the body text (default: renderer default)
+	ImagePosition   string         `json:"image_position,omitempty"` // Where comment_image goes relative to the text: "top" (default), "bottom", "left", "right"
+	Tail            bool           `json:"tail,omitempty"`           // Speech-bubble pointer from the box toward the axis
+	TailSize        float64        `json:"tail_size,omitempty"`      // Tail length and base width (default 10)
+	EdgeAnchor      *float64       `json:"edge_anchor,omitempty"`    // Where the connector meets the facing edge, 0-1 from its left/top end (default 0.5)
 }
 
 // Added: Style for the segment on the main center line corresponding to a period
 type CenterlineProjectionStyle struct {
-	Color string `json:"color"`
+	Color string   `json:"color"`
+	Width *float64 `json:"width,omitempty"` // Width of the segment leading to the entry (default: center_line.width)
 	// Percentage float64 `json:"percentage"` // Deferring variable length percentage, assume equal spacing for now
 }
 
@@ -123,19 +220,38 @@ type TimelineData struct {
 }
 
 type TimelineEntry struct {
+	ID                           string                     `json:"id,omitempty"`           // Optional anchor id for the entry group (defaults to a slug of the period)
 	Period                       string                     `json:"period"`                 // Used as year text if no shape, or inside shape
+	PeriodEnd                    string                     `json:"period_end,omitempty"`   // End date of a range entry, drawn as a bar along the axis
+	Subtitle                     string                     `json:"subtitle,omitempty"`     // Small secondary label below the period, e.g. a precise timestamp
 	TitleText                    string                     `json:"title_text,omitempty"`   // Optional Title for comment section
 	CommentText                  string                     `json:"comment_text,omitempty"` // Body text for comment section
 	CommentImage                 string                     `json:"comment_image,omitempty"`
-	Link                         string                     `json:"link,omitempty"` // Applied to Period/Year element
+	ImageAlt                     string                     `json:"image_alt,omitempty"`              // Alt text for CommentImage
+	Link                         string                     `json:"link,omitempty"`                   // Applied to Period/Year element
+	CommentLink                  string                     `json:"comment_link,omitempty"`           // Applied to the whole comment block
+	Hidden                       bool                       `json:"hidden,omitempty"`                 // Skip this entry entirely (spacing closes up)
+	Section                      string                     `json:"section,omitempty"`                // Group name for layout.accordion_sections (HTML); unset = the default group
+	Tags                         []string                   `json:"tags,omitempty"`                   // Categories used by the -filter-tag flag
+	Importance                   *float64                   `json:"importance,omitempty"`             // Enlarges the junction marker (see layout.importance_scale)
+	ThumbnailImage               string                     `json:"thumbnail_image,omitempty"`        // Small circular photo drawn on the junction marker (path, URL or data URI)
+	ThumbnailSize                float64                    `json:"thumbnail_size,omitempty"`         // Thumbnail diameter (default 32)
+	ThumbnailBorderColor         string                     `json:"thumbnail_border_color,omitempty"` // Ring color (default: the marker color)
+	ThumbnailBorderWidth         float64                    `json:"thumbnail_border_width,omitempty"` // Ring width (0 = no ring)
+	Focus                        bool                       `json:"focus,omitempty"`                  // Draw a dashed focus ring around the whole entry ("you are here")
+	CommentSide                  string                     `json:"comment_side,omitempty"`           // Force the comment to "start" (top/left) or "end" (bottom/right) instead of alternating
+	YearSide                     string                     `json:"year_side,omitempty"`              // Force the year to "start" or "end"; with only one side set the other goes opposite
 	EntrySpacingOverride         *float64                   `json:"entry_spacing_override,omitempty"`
 	OrientationOverride          *string                    `json:"orientation_override,omitempty"` // Added
 	AngleOverride                *float64                   `json:"angle_override,omitempty"`       // Added: Optional angle override in degrees
 	ConnectorOverride            *ConnectorStyleOverride    `json:"connector_override,omitempty"`
 	CommentTextOverride          *CommentTextStyleOverride  `json:"comment_text_override,omitempty"`
 	YearTextOverride             *YearTextStyleOverride     `json:"year_text_override,omitempty"`
-	CenterlineProjectionOverride *CenterlineProjectionStyle `json:"centerline_projection_override,omitempty"`
+	CenterlineProjectionOverride *CenterlineProjectionStyle `json:"centerline_projection_override,omitempty"` // Styles the segment leading to this entry
+	SegmentColorAfter            *string                    `json:"segment_color_after,omitempty"`            // Color of the segment leaving this entry (leading to the next one)
 	JunctionMarkerOverride       *JunctionMarkerOverride    `json:"junction_marker_override,omitempty"`
+	Divider                      bool                       `json:"divider,omitempty"`       // Draw a dashed era divider across the timeline at this entry's junction
+	DividerStyle                 *DividerStyle              `json:"divider_style,omitempty"` // Divider look (default: dashed, 1px, the marker color)
 }
 
 // FontStyleOverride allows overriding individual font properties
@@ -152,10 +268,15 @@ type YearTextStyleOverride struct {
 	CrossAxisOffset *float64           `json:"cross_axis_offset,omitempty"` // Added back
 	Font            *FontStyleOverride `json:"font,omitempty"`
 	TextColor       *string            `json:"text_color,omitempty"`
-	Shape           *string            `json:"shape,omitempty"`        // Added
-	FillColor       *string            `json:"fill_color,omitempty"`   // Added
+	Shape           *string            `json:"shape,omitempty"`      // Added
+	FillColor       *string            `json:"fill_color,omitempty"` // Added
+	FillOpacity     *float64           `json:"fill_opacity,omitempty"`
 	BorderColor     *string            `json:"border_color,omitempty"` // Added
 	BorderWidth     *float64           `json:"border_width,omitempty"` // Added
+	LineHeight      *float64           `json:"line_height,omitempty"`
+	Rotate          *float64           `json:"rotate,omitempty"`
+	SubtitleFont    *FontStyleOverride `json:"subtitle_font,omitempty"`
+	AutoFitText     *bool              `json:"auto_fit_text,omitempty"`
 }
 
 type CommentTextStyleOverride struct {
@@ -166,48 +287,64 @@ type CommentTextStyleOverride struct {
 	TitleFont       *FontStyleOverride      `json:"title_font,omitempty"`  // Title font override
 	TitleLine       *TitleLineStyleOverride `json:"title_line,omitempty"`  // Title line override
 	TitleColor      *string                 `json:"title_color,omitempty"` // Title text color override
+	TitlePosition   *string                 `json:"title_position,omitempty"`
 	Shape           *string                 `json:"shape,omitempty"`
 	FillColor       *string                 `json:"fill_color,omitempty"`
+	FillOpacity     *float64                `json:"fill_opacity,omitempty"`
 	TextColor       *string                 `json:"text_color,omitempty"`  // Body text color
 	Padding         *string                 `json:"padding,omitempty"`     // Changed: Padding string override
 	BlockWidth      *float64                `json:"block_width,omitempty"` // Added
+	MaxBlockWidth   *float64                `json:"max_block_width,omitempty"`
+	BlockHeight     *float64                `json:"block_height,omitempty"`
 	BorderColor     *string                 `json:"border_color,omitempty"`
 	BorderWidth     *int                    `json:"border_width,omitempty"`
 	BorderStyle     *string                 `json:"border_style,omitempty"`
 	TextAlign       *string                 `json:"text_align,omitempty"` // Added
+	LineHeight      *float64                `json:"line_height,omitempty"`
+	ImagePosition   *string                 `json:"image_position,omitempty"`
+	Tail            *bool                   `json:"tail,omitempty"`
+	TailSize        *float64                `json:"tail_size,omitempty"`
+	EdgeAnchor      *float64                `json:"edge_anchor,omitempty"`
 }
 
 type JunctionMarkerOverride struct { // New Override Struct
-	Shape *string  `json:"shape,omitempty"`
-	Size  *float64 `json:"size,omitempty"`
-	Color *string  `json:"color,omitempty"`
+	Shape       *string  `json:"shape,omitempty"`
+	Size        *float64 `json:"size,omitempty"`
+	Color       *string  `json:"color,omitempty"`
+	BorderColor *string  `json:"border_color,omitempty"`
+	BorderWidth *float64 `json:"border_width,omitempty"`
 }
 
 type TitleLineStyleOverride struct { // New Override Struct
-	Visible *bool    `json:"visible,omitempty"`
-	Color   *string  `json:"color,omitempty"`
-	Width   *float64 `json:"width,omitempty"`
-	Length  *float64 `json:"length,omitempty"`
-	Margin  *float64 `json:"margin,omitempty"`
+	Visible  *bool    `json:"visible,omitempty"`
+	Color    *string  `json:"color,omitempty"`
+	Width    *float64 `json:"width,omitempty"`
+	Length   *float64 `json:"length,omitempty"`
+	Margin   *float64 `json:"margin,omitempty"`
+	LineType *string  `json:"line_type,omitempty"`
 }
 
 // Added: Override struct for ConnectorStyle to handle pointers
 type ConnectorStyleOverride struct {
-	Color         *string           `json:"color,omitempty"`
-	LineType      *string           `json:"line_type,omitempty"`
-	Width         *int              `json:"width,omitempty"`
-	DrawToPeriod  *bool             `json:"draw_to_period,omitempty"`
-	DrawToComment *bool             `json:"draw_to_comment,omitempty"`
-	Dot           *DotStyleOverride `json:"dot,omitempty"` // Added missing Dot field
+	Color             *string           `json:"color,omitempty"`
+	LineType          *string           `json:"line_type,omitempty"`
+	Width             *int              `json:"width,omitempty"`
+	DrawToPeriod      *bool             `json:"draw_to_period,omitempty"`
+	DrawToComment     *bool             `json:"draw_to_comment,omitempty"`
+	Routing           *string           `json:"routing,omitempty"`
+	Dot               *DotStyleOverride `json:"dot,omitempty"` // Added missing Dot field
+	GradientToElement *bool             `json:"gradient_to_element,omitempty"`
 }
 
 // Added: Override struct for DotStyle
 type DotStyleOverride struct {
-	Size        *int    `json:"size,omitempty"`
-	Color       *string `json:"color,omitempty"`
-	Shape       *string `json:"shape,omitempty"`
-	Visible     *bool   `json:"visible,omitempty"`
-	OffsetMain  *int    `json:"offset_main,omitempty"`
-	OffsetCross *int    `json:"offset_cross,omitempty"`
-	StopAtDot   *bool   `json:"stop_at_dot,omitempty"` // Added override
+	Size        *int     `json:"size,omitempty"`
+	Color       *string  `json:"color,omitempty"`
+	Shape       *string  `json:"shape,omitempty"`
+	Visible     *bool    `json:"visible,omitempty"`
+	OffsetMain  *int     `json:"offset_main,omitempty"`
+	OffsetCross *int     `json:"offset_cross,omitempty"`
+	StopAtDot   *bool    `json:"stop_at_dot,omitempty"` // Added override
+	ElbowAt     *string  `json:"elbow_at,omitempty"`
+	ArrowRatio  *float64 `json:"arrow_ratio,omitempty"`
 }
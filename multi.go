@@ -0,0 +1,102 @@
+// multi.go
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// defaultStackGap is the vertical space between stacked timelines when -stack-gap is unset.
+const defaultStackGap = 40.0
+
+// TimelineSpec is one timeline of a stacked GenerateMultiSVG output.
+type TimelineSpec struct {
+	Template Template
+	Entries  []TimelineEntry
+}
+
+var (
+	svgRootSizeRegex = regexp.MustCompile(`^<svg width="([0-9.]+)" height="([0-9.]+)"`)
+	svgIDRefRegex    = regexp.MustCompile(`( id="|url\(#|href="#)`)
+)
+
+// GenerateMultiSVG renders every timeline with GenerateSVG and stacks them top to bottom,
+// gap px apart, in one SVG as wide as the widest timeline. Each timeline keeps its own
+// styling and canvas (narrower ones are centered) and is nested as its own <svg>, with its
+// ids prefixed by "t<n>-" so the defs and deep links of different timelines don't collide.
+func GenerateMultiSVG(timelines []TimelineSpec, gap float64) (string, error) {
+	svg, _, err := generateMultiSVG(timelines, gap)
+	return svg, err
+}
+
+// generateMultiSVG is GenerateMultiSVG, also returning the warnings of every timeline.
+func generateMultiSVG(timelines []TimelineSpec, gap float64) (string, []Diagnostic, error) {
+	if len(timelines) == 0 {
+		return "", nil, &GenerationError{Format: "svg", Err: ErrNoEntries}
+	}
+	diag := &diagnosticLog{}
+	if gap < 0 {
+		diag.warnf("invalid_option", "stack gap %g is negative, using 0.", gap)
+		gap = 0
+	}
+	parts := make([]string, len(timelines))
+	widths := make([]float64, len(timelines))
+	heights := make([]float64, len(timelines))
+	totalWidth, totalHeight := 0.0, 0.0
+	for i, timeline := range timelines {
+		svg, layout, err := generateSVG(timeline.Template, timeline.Entries, nil)
+		if err != nil {
+			return "", diag.warnings, fmt.Errorf("timeline %d: %w", i+1, err)
+		}
+		diag.warnings = append(diag.warnings, layout.warnings...) // Timelines can repeat each other's warnings
+		size := svgRootSizeRegex.FindStringSubmatch(svg)
+		if size == nil {
+			return "", diag.warnings, fmt.Errorf("timeline %d: unexpected SVG root element", i+1)
+		}
+		fmt.Sscanf(size[1]+" "+size[2], "%g %g", &widths[i], &heights[i])
+		parts[i] = prefixSVGIDs(svg, fmt.Sprintf("t%d-", i+1))
+		totalWidth = max(totalWidth, widths[i])
+		totalHeight += heights[i]
+	}
+	totalHeight += gap * float64(len(timelines)-1)
+
+	var out bytes.Buffer
+	fmt.Fprintf(&out, `<svg width="%.0f" height="%.0f" xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink">`, totalWidth, totalHeight)
+	out.WriteString("\n")
+	if !timelines[0].Template.Layout.Transparent {
+		// The gaps and the sides of narrower timelines get the first timeline's background
+		background := mapColor(normalizeColorMap(timelines[0].Template.Layout.ColorMap), "#FFFFFF")
+		fmt.Fprintf(&out, "  <rect width=\"%.0f\" height=\"%.0f\" fill=\"%s\" />\n", totalWidth, totalHeight, background)
+	}
+	top := 0.0
+	for i, part := range parts {
+		// The nested root keeps its size and namespaces, it only gets a position
+		fmt.Fprintf(&out, `<svg x="%s" y="%s" class="timeline-stack-item"`, fcoord((totalWidth-widths[i])/2.0, defaultCoordPrecision), fcoord(top, defaultCoordPrecision))
+		out.WriteString(strings.TrimPrefix(part, "<svg"))
+		out.WriteString("\n")
+		top += heights[i] + gap
+	}
+	out.WriteString("</svg>")
+	return out.String(), diag.warnings, nil
+}
+
+// prefixSVGIDs prefixes every id in svg, and the url(#...) and href="#..." references to
+// them, with prefix. Only the attributes of the SVG elements change: text and the HTML
+// comment bodies inside foreignObject are left as written.
+func prefixSVGIDs(svg, prefix string) string {
+	inForeignObject := false
+	return svgTagRegex.ReplaceAllStringFunc(svg, func(tag string) string {
+		switch {
+		case strings.HasPrefix(tag, "</foreignObject"):
+			inForeignObject = false
+			return tag
+		case inForeignObject:
+			return tag
+		case strings.HasPrefix(tag, "<foreignObject"):
+			inForeignObject = !strings.HasSuffix(tag, "/>")
+		}
+		return svgIDRefRegex.ReplaceAllString(tag, "${1}"+prefix)
+	})
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// TestGenerateMultiSVGStacksTimelines checks two timelines are stacked with the gap, the
+// canvas is as wide as the widest and as high as both plus the gap, and ids don't collide.
+func TestGenerateMultiSVGStacksTimelines(t *testing.T) {
+	template := loadTestTemplate(t)
+	first := []TimelineEntry{{Period: "2001", CommentText: "A"}, {Period: "2002", CommentText: "B"}, {Period: "2003", CommentText: "C"}}
+	second := []TimelineEntry{{Period: "1990", CommentText: "X"}}
+
+	sizeOf := func(svg string) (w, h float64) {
+		fmt.Sscanf(svg, `<svg width="%g" height="%g"`, &w, &h)
+		return w, h
+	}
+	single1, err := GenerateSVG(template, first)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	single2, err := GenerateSVG(template, second)
+	if err != nil {
+		t.Fatalf("GenerateSVG failed: %v", err)
+	}
+	w1, h1 := sizeOf(single1)
+	w2, h2 := sizeOf(single2)
+
+	stacked, err := GenerateMultiSVG([]TimelineSpec{{Template: template, Entries: first}, {Template: template, Entries: second}}, 30)
+	if err != nil {
+		t.Fatalf("GenerateMultiSVG failed: %v", err)
+	}
+	w, h := sizeOf(stacked)
+	if w != max(w1, w2) || h != h1+h2+30 {
+		t.Errorf("Expected a %.0fx%.0f canvas, got %.0fx%.0f", max(w1, w2), h1+h2+30, w, h)
+	}
+	items := regexp.MustCompile(`<svg x="([0-9.]+)" y="([0-9.]+)" class="timeline-stack-item"`).FindAllStringSubmatch(stacked, -1)
+	if len(items) != 2 {
+		t.Fatalf("Expected two nested timelines, got %d", len(items))
+	}
+	if items[0][2] != "0.00" || items[1][2] != fcoord(h1+30, defaultCoordPrecision) {
+		t.Errorf("Expected the second timeline at y=%.2f, got y=%s", h1+30, items[1][2])
+	}
+	if items[1][1] != fcoord((w1-w2)/2, defaultCoordPrecision) {
+		t.Errorf("Expected the narrower timeline centered at x=%.2f, got x=%s", (w1-w2)/2, items[1][1])
+	}
+	if !strings.Contains(stacked, `id="t1-entry-2001"`) || !strings.Contains(stacked, `id="t2-entry-1990"`) || strings.Contains(stacked, `id="entry-`) {
+		t.Errorf("Expected every entry id prefixed with its timeline")
+	}
+}
+
+// TestPrefixSVGIDsLeavesCommentBodies checks only the SVG element attributes get the
+// timeline prefix, not a link written inside a foreignObject comment body.
+func TestPrefixSVGIDsLeavesCommentBodies(t *testing.T) {
+	template := loadTestTemplate(t)
+	entries := []TimelineEntry{{Period: "2001", CommentText: `See <a href="#x">the note</a>`}}
+	stacked, err := GenerateMultiSVG([]TimelineSpec{{Template: template, Entries: entries}}, 0)
+	if err != nil {
+		t.Fatalf("GenerateMultiSVG failed: %v", err)
+	}
+	if !strings.Contains(stacked, `<a href="#x">`) || strings.Contains(stacked, `href="#t1-x"`) {
+		t.Errorf("Expected the comment body link to stay href=\"#x\"")
+	}
+	if !strings.Contains(stacked, `id="t1-entry-2001"`) {
+		t.Errorf("Expected the entry id to be prefixed")
+	}
+}
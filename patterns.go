@@ -0,0 +1,57 @@
+// patterns.go
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// patternFillPrefix marks a fill_color that refers to a built-in pattern, e.g. "pattern:hatch".
+const patternFillPrefix = "pattern:"
+
+// builtinPatterns holds the <pattern> content of each built-in fill pattern, keyed by name.
+// Patterns use a black ink on a transparent tile so they print well in black and white.
+var builtinPatterns = map[string]string{
+	"hatch":      `<path d="M -2 2 L 2 -2 M 0 8 L 8 0 M 6 10 L 10 6" stroke="#000000" stroke-width="1" />`,
+	"crosshatch": `<path d="M -2 2 L 2 -2 M 0 8 L 8 0 M 6 10 L 10 6 M -2 6 L 2 10 M 0 0 L 8 8 M 6 -2 L 10 2" stroke="#000000" stroke-width="1" />`,
+	"dots":       `<circle cx="4" cy="4" r="1.5" fill="#000000" />`,
+	"horizontal": `<path d="M 0 4 L 8 4" stroke="#000000" stroke-width="1" />`,
+	"vertical":   `<path d="M 4 0 L 4 8" stroke="#000000" stroke-width="1" />`,
+}
+
+// builtinPatternOrder fixes the order pattern defs are emitted in, keeping the output reproducible.
+var builtinPatternOrder = []string{"hatch", "crosshatch", "dots", "horizontal", "vertical"}
+
+// patternID returns the deterministic element id of a built-in pattern.
+func patternID(name string) string {
+	return "pattern-" + name
+}
+
+// resolveFill turns a "pattern:<name>" fill into a url() reference to its pattern def.
+// Other values (plain colors, "none") only go through the render's color map.
+func (c *renderContext) resolveFill(fill string) string {
+	name, isPattern := strings.CutPrefix(fill, patternFillPrefix)
+	if !isPattern {
+		return c.mapColor(fill)
+	}
+	if _, ok := builtinPatterns[name]; !ok {
+		c.warnf("unknown_pattern", "unknown fill pattern '%s' (available: %s), using no fill.", name, strings.Join(builtinPatternOrder, ", "))
+		return "none"
+	}
+	return "url(#" + patternID(name) + ")"
+}
+
+// patternDefs returns the <pattern> elements for the patterns referenced in svgBody,
+// or "" when no pattern is used.
+func patternDefs(svgBody []byte) string {
+	var defs strings.Builder
+	for _, name := range builtinPatternOrder {
+		if !bytes.Contains(svgBody, []byte("url(#"+patternID(name)+")")) {
+			continue
+		}
+		defs.WriteString(`    <pattern id="` + patternID(name) + `" width="8" height="8" patternUnits="userSpaceOnUse">`)
+		defs.WriteString(builtinPatterns[name])
+		defs.WriteString("</pattern>\n")
+	}
+	return defs.String()
+}
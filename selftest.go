@@ -0,0 +1,65 @@
+// selftest.go
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// selfTestFormats are the formats -selftest renders: svg covers the layout pipeline, png the
+// headless Chrome rasterization.
+var selfTestFormats = []string{"svg", "png"}
+
+// selfTestEntries is the sample timeline rendered by -selftest with DefaultTemplate.
+var selfTestEntries = []TimelineEntry{
+	{Period: "2001", TitleText: "Start", CommentText: "The first entry"},
+	{Period: "2005", TitleText: "Middle", CommentText: "With a **bold** word"},
+	{Period: "2010", CommentText: "The last entry"},
+}
+
+// chromeNotFoundHint tells the user how to fix a missing browser for image output.
+const chromeNotFoundHint = "Chrome/Chromium was not found. Install Google Chrome or Chromium (or put its binary on the PATH) for png/jpg output; svg and html output work without it."
+
+// runSelfTest renders the sample timeline (DefaultTemplate and selfTestEntries) in every
+// format into dir, reporting each format's result to report. The error lists the formats
+// that failed.
+func runSelfTest(dir string, formats []string, report io.Writer) error {
+	template := DefaultTemplate("horizontal")
+	buildSVG := func() (string, timelineLayout, error) { return generateSVG(template, selfTestEntries, nil) }
+	var failed []string
+	for _, format := range formats {
+		outputPath := filepath.Join(dir, "selftest."+format)
+		err := writeOutput(outputPath, format, false, func(outputWriter io.Writer) error {
+			_, errRender := renderFormat(format, template, selfTestEntries, renderOptions{BuildSVG: buildSVG}, outputWriter)
+			return errRender
+		})
+		if err == nil {
+			if info, errStat := os.Stat(outputPath); errStat != nil || info.Size() == 0 {
+				err = fmt.Errorf("no output was written to '%s'", outputPath)
+			}
+		}
+		if err != nil {
+			failed = append(failed, format)
+			fmt.Fprintf(report, "%-4s FAILED: %v\n", format, err)
+			if isChromeNotFound(err) {
+				fmt.Fprintf(report, "     %s\n", chromeNotFoundHint)
+			}
+			continue
+		}
+		fmt.Fprintf(report, "%-4s ok: %s\n", format, outputPath)
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d formats failed: %s", len(failed), len(formats), strings.Join(failed, ", "))
+	}
+	return nil
+}
+
+// isChromeNotFound reports whether err comes from chromedp failing to find a browser binary.
+func isChromeNotFound(err error) bool {
+	return errors.Is(err, exec.ErrNotFound)
+}
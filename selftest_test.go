@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// TestSelfTestRendersSampleTimeline checks the self-test writes and reports the svg sample,
+// and the png one when Chrome is installed.
+func TestSelfTestRendersSampleTimeline(t *testing.T) {
+	dir := t.TempDir()
+	var report bytes.Buffer
+	if err := runSelfTest(dir, []string{"svg"}, &report); err != nil {
+		t.Fatalf("svg self-test failed: %v\n%s", err, report.String())
+	}
+	svg, err := os.ReadFile(filepath.Join(dir, "selftest.svg"))
+	if err != nil || !strings.HasPrefix(string(svg), "<svg") {
+		t.Fatalf("Expected the sample SVG to be written, got %v", err)
+	}
+	if !strings.Contains(report.String(), "svg  ok") {
+		t.Errorf("Expected svg reported as ok, got %q", report.String())
+	}
+
+	t.Run("png", func(t *testing.T) {
+		requireChrome(t)
+		report.Reset()
+		if err := runSelfTest(dir, []string{"png"}, &report); err != nil {
+			t.Fatalf("png self-test failed: %v\n%s", err, report.String())
+		}
+	})
+}
+
+// TestSelfTestExplainsMissingChrome checks a browser missing from the PATH is recognized
+// for the guidance, also inside a GenerationError.
+func TestSelfTestExplainsMissingChrome(t *testing.T) {
+	unrelated := &GenerationError{Format: "png", Err: fmt.Errorf("chromedp execution failed: %w", os.ErrNotExist)}
+	if isChromeNotFound(unrelated) {
+		t.Errorf("Expected an unrelated error not to count as a missing Chrome")
+	}
+	t.Setenv("PATH", t.TempDir())
+	var report bytes.Buffer
+	if err := runSelfTest(t.TempDir(), []string{"png"}, &report); err == nil {
+		t.Skip("Chrome was found outside the PATH")
+	}
+	if !strings.Contains(report.String(), chromeNotFoundHint) {
+		t.Errorf("Expected the Chrome install hint, got %q", report.String())
+	}
+}
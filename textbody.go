@@ -0,0 +1,170 @@
+// textbody.go
+package main
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+// Comment bodies without foreignObject (layout.no_foreign_object): the body becomes wrapped
+// SVG <text>, markdown links become <a>-wrapped tspans and the image an <image> element.
+
+const textBodyImageGap = 5.0 // Space between a comment image and the text lines in text mode
+
+var (
+	htmlBreakRegex = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTagRegex   = regexp.MustCompile(`<[^>]+>`)
+)
+
+// bodyWord is one word of a text mode comment body, with the URL of the markdown link it belongs to.
+type bodyWord struct {
+	text string
+	link string
+}
+
+// wrapCommentBody splits body into lines of words no wider than width (0 = no wrapping).
+// HTML tags are dropped (<br> breaks the line), since the words are written as plain SVG
+// text; entities render as in the foreignObject body (they are decoded before escaping).
+func wrapCommentBody(body string, font FontStyle, width float64) [][]bodyWord {
+	if body == "" {
+		return nil
+	}
+	body = htmlBreakRegex.ReplaceAllString(body, "\n")
+	body = htmlTagRegex.ReplaceAllString(body, "")
+	var lines [][]bodyWord
+	for _, paragraph := range strings.Split(body, "\n") {
+		var words []bodyWord
+		addWords := func(text, link string) {
+			for _, field := range strings.Fields(text) {
+				words = append(words, bodyWord{text: field, link: link})
+			}
+		}
+		last := 0
+		for _, m := range markdownLinkRegex.FindAllStringSubmatchIndex(paragraph, -1) {
+			addWords(paragraph[last:m[0]], "")
+			addWords(paragraph[m[2]:m[3]], paragraph[m[4]:m[5]])
+			last = m[1]
+		}
+		addWords(paragraph[last:], "")
+
+		// Greedy wrapping; a single word wider than the line gets a line of its own
+		var line []bodyWord
+		lineText := ""
+		for _, word := range words {
+			candidate := strings.TrimSpace(lineText + " " + word.text)
+			if len(line) > 0 && width > 0 && estimateTextSVGWidth(candidate, font) > width {
+				lines = append(lines, line)
+				line, candidate = nil, word.text
+			}
+			line = append(line, word)
+			lineText = candidate
+		}
+		lines = append(lines, line) // An empty paragraph keeps its blank line
+	}
+	return lines
+}
+
+// commentTextBodyHeight returns the height of a text mode comment body: its wrapped lines
+// plus the image above or below them.
+func commentTextBodyHeight(params CommentParams, width float64) float64 {
+	height := float64(len(wrapCommentBody(params.BodyText, params.Style.Font, width))) * commentLineHeight(params.Style)
+	if params.ImageURL != "" {
+		height += imagePlaceholderHeight
+		if params.BodyText != "" {
+			height += textBodyImageGap
+		}
+	}
+	return height
+}
+
+// drawCommentBodyText draws the comment body as wrapped <text> lines and the comment image
+// as an <image> element, for renderers without foreignObject support.
+func drawCommentBodyText(svg *svgBuffer, bounds *bounds, params CommentBodyParams) {
+	layout := params.Layout
+	contentWidth := layout.contentWidth
+	bounds.updateRect(layout.bodyAbsX, layout.bodyAbsY, contentWidth, layout.foHeight)
+	clipAttr := writeCommentBodyClip(svg, params)
+	if clipAttr != "" {
+		fmt.Fprintf(svg, `    <g%s>`, clipAttr)
+		svg.WriteString("\n")
+		defer svg.WriteString("    </g>\n")
+	}
+
+	lines := wrapCommentBody(params.Params.BodyText, params.BodyFont, contentWidth)
+	lineHeight := commentLineHeight(params.Params.Style)
+	textTop := layout.bodyAbsY
+
+	// Side images can't float beside SVG text, they go above it
+	imagePosition := resolveCommentImagePosition(svg.warnings(), params.Params.Style.ImagePosition)
+	if params.Params.ImageURL != "" {
+		if imgSrc := svg.embedImageSource(params.Params.ImageURL); imgSrc != "" {
+			imgAlt := params.Params.ImageAlt
+			if imgAlt == "" {
+				imgAlt = defaultImageAlt
+			}
+			imageWidth := contentWidth
+			if imageWidth <= 0 {
+				imageWidth = imagePlaceholderHeight
+			}
+			imageY := layout.bodyAbsY
+			if imagePosition == "bottom" {
+				imageY = layout.bodyAbsY + layout.foHeight - imagePlaceholderHeight
+			} else {
+				textTop += imagePlaceholderHeight + textBodyImageGap
+			}
+			fmt.Fprintf(svg, `    <image href="%s" x="%s" y="%s" width="%s" height="%s" preserveAspectRatio="xMidYMid meet"><title>%s</title></image>`,
+				escapeXML(imgSrc), svg.coord(layout.bodyAbsX), svg.coord(imageY), svg.coord(imageWidth), svg.coord(imagePlaceholderHeight), escapeXML(imgAlt))
+			svg.WriteString("\n")
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+
+	// text-align maps to the anchor of every line; justify has no SVG equivalent
+	lineX, anchor := layout.contentCenterX, "middle"
+	switch resolveCommentTextAlign(params.Params.Style.TextAlign, params.Params.IsHorizontal, params.Params.CrossAxisDir) {
+	case "left", "justify", "start":
+		lineX, anchor = layout.bodyAbsX, "start"
+	case "right", "end":
+		lineX, anchor = layout.bodyAbsX+contentWidth, "end"
+	}
+	fmt.Fprintf(svg, `    <text class="comment-text-content" x="%s" y="%s" font-family="%s" font-size="%d" font-weight="%s" font-style="%s" fill="%s" text-anchor="%s" dominant-baseline="hanging">`,
+		svg.coord(lineX), svg.coord(textTop), cssFontFamily(params.BodyFont.FontFamily), params.BodyFont.FontSize,
+		escapeXML(params.BodyFont.FontWeight), escapeXML(params.BodyFont.FontStyle), svg.mapColor(params.TextColor), anchor)
+	for i, line := range lines {
+		dy := lineHeight
+		if i == 0 {
+			dy = 0
+		}
+		fmt.Fprintf(svg, `<tspan x="%s" dy="%s">`, svg.coord(lineX), svg.coord(dy))
+		if len(line) == 0 {
+			svg.WriteString("&#160;") // Keeps a blank line from collapsing
+		}
+		for j := 0; j < len(line); {
+			// Consecutive words of one link share an <a>
+			k := j + 1
+			for k < len(line) && line[k].link == line[j].link {
+				k++
+			}
+			texts := make([]string, 0, k-j)
+			for _, word := range line[j:k] {
+				texts = append(texts, word.text)
+			}
+			text := escapeXML(html.UnescapeString(strings.Join(texts, " ")))
+			if j > 0 {
+				svg.WriteString(" ")
+			}
+			if line[j].link != "" {
+				fmt.Fprintf(svg, `<a xlink:href="%s" target="_blank"><tspan text-decoration="underline">%s</tspan></a>`, escapeXML(line[j].link), text)
+			} else {
+				svg.WriteString(text)
+			}
+			j = k
+		}
+		svg.WriteString(`</tspan>`)
+	}
+	svg.WriteString("</text>\n")
+}
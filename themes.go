@@ -0,0 +1,105 @@
+// themes.go
+package main
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+)
+
+// Built-in template presets selectable with the -theme flag.
+//
+//go:embed themes/*.json
+var themeFiles embed.FS
+
+// availableThemes lists the names of the built-in themes.
+func availableThemes() []string {
+	dirEntries, err := themeFiles.ReadDir("themes")
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		names = append(names, strings.TrimSuffix(dirEntry.Name(), ".json"))
+	}
+	sort.Strings(names)
+	return names
+}
+
+// loadTemplate parses a template, optionally layered on top of a built-in theme.
+// Precedence is theme < template file (< per-entry overrides at render time): the template JSON
+// is decoded over the already-populated theme, so only the fields present in the file replace
+// the theme's values. Nested objects merge field by field; arrays are replaced. nil
+// templateBytes (the "default" template argument) start from DefaultTemplate instead, with
+// the theme decoded over it.
+func loadTemplate(templateBytes []byte, themeName string) (Template, error) {
+	var template Template
+	if templateBytes == nil {
+		template = DefaultTemplate("horizontal")
+	}
+	if themeName != "" {
+		themeBytes, err := themeFiles.ReadFile("themes/" + themeName + ".json")
+		if err != nil {
+			return template, fmt.Errorf("unknown theme '%s' (available: %s)", themeName, strings.Join(availableThemes(), ", "))
+		}
+		if err := json.Unmarshal(themeBytes, &template); err != nil {
+			return template, fmt.Errorf("failed to parse built-in theme '%s': %w", themeName, err)
+		}
+	}
+	if templateBytes == nil {
+		return template, nil
+	}
+	if err := json.Unmarshal(templateBytes, &template); err != nil {
+		return template, err
+	}
+	return template, nil
+}
+
+// defaultTemplateArg is the template argument that selects DefaultTemplate instead of a file.
+const defaultTemplateArg = "default"
+
+// DefaultTemplate returns a plain template for orientation ("horizontal" or "vertical") that
+// renders entries without any other configuration: black axis and markers, bold periods and
+// outlined comment boxes on alternating sides, with the spacing and lengths GenerateSVG falls
+// back to when a template leaves them unset.
+func DefaultTemplate(orientation string) Template {
+	if orientation != "horizontal" && orientation != "vertical" {
+		log.Printf("Warning: unknown orientation '%s' (use 'horizontal' or 'vertical'), using horizontal.", orientation)
+		orientation = "horizontal"
+	}
+	drawLines := true
+	markerColor := "#000000"
+	blockWidth := 150.0
+	return Template{
+		CenterLine: CenterLine{Width: 2, Type: "solid", Orientation: orientation, Color: defaultCenterLineColor},
+		Layout:     LayoutOptions{Padding: defaultLayoutPadding, EntrySpacing: defaultLayoutEntrySpacing, ConnectorLength: defaultLayoutConnectorLength},
+		GlobalFont: &FontStyle{FontFamily: defaultFont, FontSize: int(defaultFontSize), FontWeight: "normal", FontStyle: "normal"},
+		PeriodDefaults: PeriodStyle{
+			YearText: YearTextStyle{
+				Position:  "alternate-end-start",
+				TextColor: "#000000",
+				Font:      FontStyle{FontSize: 14, FontWeight: "bold"},
+				Shape:     "none",
+			},
+			Connector: ConnectorStyle{DrawToPeriod: &drawLines, DrawToComment: &drawLines, Width: 1, Color: "#757575", LineType: "solid"},
+			CommentText: CommentTextStyle{
+				Position:    "alternate-start-end",
+				TitleFont:   FontStyle{FontWeight: "bold"},
+				TitleColor:  "#000000",
+				Shape:       "rectangle",
+				FillColor:   "#FFFFFF",
+				TextColor:   "#424242",
+				Padding:     "8",
+				BlockWidth:  &blockWidth,
+				BorderColor: "#BDBDBD",
+				BorderWidth: 1,
+				BorderStyle: "solid",
+				TextAlign:   "center",
+			},
+			JunctionMarker: JunctionMarkerStyle{Shape: "circle", Size: 8, Color: &markerColor},
+		},
+	}
+}
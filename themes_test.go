@@ -0,0 +1,55 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// TestThemeIsOverriddenByTemplate checks every theme parses and that template fields win over the theme.
+func TestThemeIsOverriddenByTemplate(t *testing.T) {
+	themes := availableThemes()
+	if len(themes) == 0 {
+		t.Fatalf("No built-in themes found")
+	}
+	for _, theme := range themes {
+		template, err := loadTemplate([]byte(`{"center_line": {"color": "#123456"}}`), theme)
+		if err != nil {
+			t.Fatalf("loadTemplate with theme %s failed: %v", theme, err)
+		}
+		if template.CenterLine.Color != "#123456" {
+			t.Errorf("Theme %s: template color not applied, got %q", theme, template.CenterLine.Color)
+		}
+		if template.CenterLine.Orientation == "" {
+			t.Errorf("Theme %s: fields not set by the template should keep the theme value", theme)
+		}
+	}
+	if _, err := loadTemplate([]byte(`{}`), "no-such-theme"); err == nil {
+		t.Errorf("Expected an error for an unknown theme")
+	}
+}
+
+// TestDefaultTemplateRendersWithoutConfig checks GenerateSVG draws the periods and comments
+// with the default template and that a theme decoded over it (the "default" argument) wins.
+func TestDefaultTemplateRendersWithoutConfig(t *testing.T) {
+	entries := []TimelineEntry{{Period: "2001", CommentText: "Founded"}, {Period: "2005"}}
+	for _, orientation := range []string{"horizontal", "vertical"} {
+		svg, err := GenerateSVG(DefaultTemplate(orientation), entries)
+		if err != nil {
+			t.Fatalf("GenerateSVG with the %s default template failed: %v", orientation, err)
+		}
+		for _, want := range []string{">2001<", ">2005<", "Founded", `font-weight="bold"`} {
+			if !strings.Contains(svg, want) {
+				t.Errorf("%s default template: expected SVG to contain %q", orientation, want)
+			}
+		}
+	}
+
+	template, err := loadTemplate(nil, "dark")
+	if err != nil {
+		t.Fatalf("loadTemplate of the default template with a theme failed: %v", err)
+	}
+	dark, _ := loadTemplate([]byte(`{}`), "dark")
+	if template.CenterLine.Color != dark.CenterLine.Color {
+		t.Errorf("Expected the theme to override the default template, got center line %q", template.CenterLine.Color)
+	}
+}
@@ -0,0 +1,38 @@
+// validate.go
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// ValidateData checks up front that every local image the visible entries reference
+// (comment_image, thumbnail_image) exists, so a long render fails fast instead of skipping
+// images one by one. Remote URLs and data URIs are not checked. The error wraps
+// ErrMissingImages and lists all missing files.
+func ValidateData(entries []TimelineEntry) error {
+	var missing []string
+	for i, entry := range visibleEntries(entries) {
+		for _, image := range []struct{ field, src string }{
+			{"comment_image", entry.CommentImage},
+			{"thumbnail_image", entry.ThumbnailImage},
+		} {
+			if image.src == "" || isRemoteImage(image.src) {
+				continue
+			}
+			if _, err := os.Stat(image.src); err != nil {
+				missing = append(missing, fmt.Sprintf("entry %d (%s) %s '%s'", i, entry.Period, image.field, image.src))
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: %s", ErrMissingImages, strings.Join(missing, "; "))
+	}
+	return nil
+}
+
+// isRemoteImage reports whether src is loaded by the renderer itself rather than read from disk.
+func isRemoteImage(src string) bool {
+	return strings.HasPrefix(src, "http://") || strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "data:")
+}